@@ -1,17 +1,20 @@
 package main
 
 import (
-	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"os"
 	"strings"
 	"time"
 
-	"github.com/kardianos/service"
 	"github.com/spf13/cobra"
+	"github.com/zerowidth/gh-shorthand/pkg/alfred"
 	"github.com/zerowidth/gh-shorthand/pkg/completion"
 	"github.com/zerowidth/gh-shorthand/pkg/config"
+	"github.com/zerowidth/gh-shorthand/pkg/launcher"
+	"github.com/zerowidth/gh-shorthand/pkg/opener"
+	"github.com/zerowidth/gh-shorthand/pkg/picker"
 	"github.com/zerowidth/gh-shorthand/pkg/rpc"
 	"github.com/zerowidth/gh-shorthand/pkg/server"
 	"github.com/zerowidth/gh-shorthand/pkg/snippets"
@@ -25,6 +28,13 @@ var rootCmd = &cobra.Command{
 }
 
 var includeRPC bool
+var completeFormat string
+var completeCache bool
+
+// rpcWait bounds how long complete waits for RPC results when writing for a
+// launcher that can't re-run it the way Alfred does.
+const rpcWait = 2 * time.Second
+
 var completeCommand = &cobra.Command{
 	Use: "complete 'input string'",
 	Run: func(cmd *cobra.Command, args []string) {
@@ -39,18 +49,33 @@ var completeCommand = &cobra.Command{
 
 		result := completion.Complete(cfg, env)
 
+		// other launchers only run this once per input, so wait for RPC results
+		// here instead of asking to be re-run
+		if completeFormat != "alfred" {
+			deadline := time.Now().Add(rpcWait)
+			for result.Rerun > 0 && time.Now().Before(deadline) {
+				time.Sleep(time.Duration(result.Rerun * float64(time.Second)))
+				result = completion.Complete(cfg, env)
+			}
+		}
+
 		// only include config loading error result if there was any input
 		if cfgErr != nil && len(env.Query) > 0 {
 			result.AppendItems(completion.ErrorItem(fmt.Sprintf("Could not load config from %s", config.Filename), cfgErr.Error()))
 		}
 
-		if err := json.NewEncoder(os.Stdout).Encode(result); err != nil {
-			fmt.Fprintf(os.Stderr, "could not generate JSON: %s\n", err)
+		if err := launcher.Write(os.Stdout, completeFormat, result); err != nil {
+			fmt.Fprintf(os.Stderr, "could not generate output: %s\n", err)
+			os.Exit(1)
 		}
 	},
 }
 
 var markdownDescription bool
+var markdownFormat string
+var markdownTemplate string
+var markdownShort bool
+var markdownContext string
 var markdownCommand = &cobra.Command{
 	Use:   "markdown-link",
 	Short: "Generate a markdown link from the given input",
@@ -66,24 +91,202 @@ will generate a markdown link:
 
 If --description is set and RPC is configured, the markdown link will include a
 description from the issue or PR's title.
+
+With --format, the link is generated for something other than markdown, e.g.
+html, slack, org, rst or asciidoc.
+
+Markdown links use the "link" and "link_description" link templates if they're
+configured, and --template uses any other configured link template instead. A
+template decides the format of the link itself, so it can't be combined with
+--format.
+
+With --short, the link text is shortened: issues in the context repo, from
+--context-repo or the default repo, are just #123, and repos with shorthand
+use it, e.g. gs#12. Templates have the repo shorthand as {{.Short}} instead.
 `,
 	Aliases: []string{"ml"},
+	Args: func(cmd *cobra.Command, args []string) error {
+		if len(markdownTemplate) > 0 && cmd.Flags().Changed("format") {
+			return errors.New("--format can't be used with --template")
+		}
+		if len(markdownTemplate) > 0 && markdownShort {
+			return errors.New("--short can't be used with --template, use {{.Short}} in the template instead")
+		}
+		return nil
+	},
 	Run: func(cmd *cobra.Command, args []string) {
 		cfg, err := config.LoadFromDefault()
 		input := strings.Join(args, " ")
 		if err != nil {
 			fmt.Fprintf(os.Stdout, "%s (error: %s)", input, err.Error())
 		}
-		rpcClient := rpc.NewClient(cfg.SocketPath)
-		link := snippets.MarkdownLink(rpcClient, input, markdownDescription)
+		format, err := snippets.LookupFormat(markdownFormat)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			os.Exit(1)
+		}
+		rpcClient := rpc.NewClient(cfg)
+
+		name := markdownTemplate
+		if len(name) == 0 && format.Name == snippets.Markdown.Name {
+			name = configuredTemplate(cfg, snippets.LinkTemplate)
+			if markdownDescription {
+				name = configuredTemplate(cfg, snippets.DescriptionLinkTemplate)
+			}
+		}
+		if markdownShort {
+			shortener := snippets.NewShortener(cfg, markdownContext)
+			fmt.Fprint(os.Stdout, snippets.ShortLink(rpcClient, input, format, markdownDescription, shortener))
+			return
+		}
+		if len(name) == 0 {
+			fmt.Fprint(os.Stdout, snippets.Link(rpcClient, input, format, markdownDescription))
+			return
+		}
+
+		templates, err := snippets.NewTemplates(cfg)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			os.Exit(1)
+		}
+		link, err := snippets.TemplateLink(rpcClient, input, templates, name, markdownDescription)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			os.Exit(1)
+		}
 		fmt.Fprint(os.Stdout, link)
 	},
 }
 
+// configuredTemplate returns the name of a link template if it's configured,
+// so the built-in formats are used otherwise.
+func configuredTemplate(cfg config.Config, name string) string {
+	if _, ok := cfg.LinkTemplates[name]; ok {
+		return name
+	}
+	return ""
+}
+
+var linkifyDescription bool
+var linkifyCommand = &cobra.Command{
+	Use:   "linkify",
+	Short: "Convert GitHub URLs and references in text to markdown links",
+	Long: `Reads text or markdown from stdin and writes it to stdout with GitHub issue,
+PR, discussion and repo URLs, and owner/repo#123 references, converted to
+markdown links. Code blocks, inline code and existing links are left alone.
+
+If --description is set and RPC is configured, the links include the titles of
+issues and PRs and the descriptions of repos.
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg, err := config.LoadFromDefault()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "could not load config: %s\n", err)
+		}
+		rpcClient := rpc.NewClient(cfg)
+		if err := snippets.Linkify(rpcClient, os.Stdin, os.Stdout, linkifyDescription); err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			os.Exit(1)
+		}
+	},
+}
+
+var changelogCommand = &cobra.Command{
+	Use:   "changelog <pr>... | <range>",
+	Short: "Generate release notes from PRs",
+	Long: `Generates release notes in markdown from a list of PRs, given as URLs, as
+references such as owner/name#123, or as shorthand. A range of commits such as
+v1.0...v1.1 adds the PRs merged between them. The range is in the default repo,
+unless a repo is given first, e.g. gs@v1.0...v1.1.
+
+The titles, authors and labels of the PRs are looked up over RPC. PRs are
+grouped by label into the sections in the changelog_sections config, and each
+is rendered with the "changelog" link template.
+`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg, err := config.LoadFromDefault()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "could not load config: %s\n", err)
+		}
+		rpcClient := rpc.NewClient(cfg)
+		if err := snippets.Changelog(rpcClient, cfg, args, os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			os.Exit(1)
+		}
+	},
+}
+
+var urlOpen bool
+var urlCommand = &cobra.Command{
+	Use:   "url <shorthand>",
+	Short: "Expand shorthand into a GitHub URL",
+	Long: `Expands shorthand for a repo, issue, commit or path into its GitHub URL, using
+the repo and user shorthand and default repo from the config. For example, with
+"gs" as shorthand for zerowidth/gh-shorthand:
+
+	gs 12       https://github.com/zerowidth/gh-shorthand/issues/12
+	gs@abc1234  https://github.com/zerowidth/gh-shorthand/commit/abc1234
+	gs /pulls   https://github.com/zerowidth/gh-shorthand/pulls
+
+With --open, the URL is opened instead of printed.
+`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg, err := config.LoadFromDefault()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "could not load config: %s\n", err)
+		}
+		input := strings.Join(args, " ")
+		url, ok := snippets.URL(cfg, input)
+		if !ok {
+			fmt.Fprintf(os.Stderr, "could not expand %q into a URL\n", input)
+			os.Exit(1)
+		}
+		if !urlOpen {
+			fmt.Println(url)
+			return
+		}
+		if err := opener.Open(url); err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			os.Exit(1)
+		}
+	},
+}
+
+var referenceShort bool
+var referenceContext string
 var issueReferenceCommand = &cobra.Command{
-	Use: "issue-reference",
+	Use:   "issue-reference",
+	Short: "Generate an issue reference from an issue or PR URL",
+	Long: `Generates an issue reference, e.g. zerowidth/gh-shorthand#1, from an issue or
+PR URL, using the "reference" link template if it's configured.
+
+With --short, issues in the context repo, from --context-repo or the default
+repo, are just #123. Repo shorthand isn't used, as GitHub wouldn't link it.
+`,
 	Run: func(cmd *cobra.Command, args []string) {
-		ref := snippets.IssueReference(strings.Join(args, " "))
+		input := strings.Join(args, " ")
+		cfg, _ := config.LoadFromDefault()
+		if referenceShort {
+			fmt.Fprint(os.Stdout, snippets.ShortIssueReference(input, snippets.NewShortener(cfg, referenceContext)))
+			return
+		}
+		if len(configuredTemplate(cfg, snippets.ReferenceTemplate)) == 0 {
+			fmt.Fprint(os.Stdout, snippets.IssueReference(input))
+			return
+		}
+
+		templates, err := snippets.NewTemplates(cfg)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			os.Exit(1)
+		}
+		ref, err := snippets.TemplateLink(rpc.NewClient(cfg), input, templates, snippets.ReferenceTemplate, false)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			os.Exit(1)
+		}
 		fmt.Fprint(os.Stdout, ref)
 	},
 }
@@ -111,9 +314,7 @@ var serverInstall = &cobra.Command{
 	Short: "Install the gh-shorthand server",
 	Run: func(cmd *cobra.Command, args []string) {
 		cfg := config.MustLoadFromDefault()
-		svc := server.Service(cfg)
-		err := service.Control(svc, "install")
-		if err != nil {
+		if err := server.Control(cfg, "install"); err != nil {
 			log.Fatal(err)
 		}
 	},
@@ -123,9 +324,7 @@ var serverRemove = &cobra.Command{
 	Short: "Remove the gh-shorthand server",
 	Run: func(cmd *cobra.Command, args []string) {
 		cfg := config.MustLoadFromDefault()
-		svc := server.Service(cfg)
-		err := service.Control(svc, "uninstall")
-		if err != nil {
+		if err := server.Control(cfg, "uninstall"); err != nil {
 			log.Fatal(err)
 		}
 	},
@@ -135,9 +334,7 @@ var serverStart = &cobra.Command{
 	Short: "Start the gh-shorthand server in the background",
 	Run: func(cmd *cobra.Command, args []string) {
 		cfg := config.MustLoadFromDefault()
-		svc := server.Service(cfg)
-		err := service.Control(svc, "start")
-		if err != nil {
+		if err := server.Control(cfg, "start"); err != nil {
 			log.Fatal(err)
 		}
 	},
@@ -148,9 +345,7 @@ var serverStop = &cobra.Command{
 	Short: "Stop the gh-shorthand server",
 	Run: func(cmd *cobra.Command, args []string) {
 		cfg := config.MustLoadFromDefault()
-		svc := server.Service(cfg)
-		err := service.Control(svc, "stop")
-		if err != nil {
+		if err := server.Control(cfg, "stop"); err != nil {
 			log.Fatal(err)
 		}
 	},
@@ -161,11 +356,142 @@ var serverRestart = &cobra.Command{
 	Short: "Restart the gh-shorthand server",
 	Run: func(cmd *cobra.Command, args []string) {
 		cfg := config.MustLoadFromDefault()
-		svc := server.Service(cfg)
-		err := service.Control(svc, "restart")
+		if err := server.Control(cfg, "restart"); err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+var serverStatus = &cobra.Command{
+	Use:   "status",
+	Short: "Show the status of the gh-shorthand server",
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg := config.MustLoadFromDefault()
+		status, err := server.Status(cfg)
 		if err != nil {
 			log.Fatal(err)
 		}
+		fmt.Println(status)
+
+		if !cfg.RPCEnabled() {
+			return
+		}
+		v, err := rpc.NewClient(cfg).Version()
+		switch {
+		case err != nil:
+			fmt.Printf("protocol: unknown, %s\n", err)
+		case v.Outdated():
+			fmt.Printf("protocol: version %d, older than this client's version %d: run `gh-shorthand server restart`\n",
+				v.Protocol, rpc.ProtocolVersion)
+		default:
+			fmt.Printf("protocol: version %d, supports %s\n", v.Protocol, strings.Join(v.Capabilities, ", "))
+		}
+	},
+}
+
+var projectsCommand = &cobra.Command{
+	Use:   "projects",
+	Short: "Manage the project directory index",
+}
+
+var projectsReindex = &cobra.Command{
+	Use:   "reindex",
+	Short: "Rebuild the project directory index",
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg := config.MustLoadFromDefault()
+		count, err := completion.ReindexProjects(cfg)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("indexed %d project directories\n", count)
+	},
+}
+
+var cloneRoot string
+var cloneURL string
+var cloneCommand = &cobra.Command{
+	Use:   "clone <repo>",
+	Short: "Clone a repository into a project directory and print its path",
+	Long: `Clones a GitHub repository into a project directory and prints the path to
+the checkout, for the editor action to open.
+
+The repository is given as owner/name or configured shorthand. If it's already
+checked out in one of the project directories, the existing checkout is printed
+instead. It's cloned into the first project directory unless --root is given,
+using the configured clone_protocol unless --url is given.
+`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg := config.MustLoadFromDefault()
+		path, err := completion.CloneProject(cfg, args[0], cloneRoot, cloneURL)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			os.Exit(1)
+		}
+		fmt.Println(path)
+	},
+}
+
+var worktreeCommand = &cobra.Command{
+	Use:   "worktree",
+	Short: "Manage git worktrees of project directories",
+}
+
+var worktreeAdd = &cobra.Command{
+	Use:   "add <dir> <branch>",
+	Short: "Create a worktree for a branch and print its path",
+	Long: `Creates a git worktree for a branch of the checkout in dir, alongside the
+checkout, and prints its path for the editor or terminal action to open. If the
+branch is already checked out, its existing path is printed instead.
+`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		path, err := completion.AddWorktree(args[0], args[1])
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			os.Exit(1)
+		}
+		fmt.Println(path)
+	},
+}
+
+var pickPrint bool
+var pickCommand = &cobra.Command{
+	Use:   "pick ['input string']",
+	Short: "Interactively complete input in the terminal",
+	Long: `Runs the completion interactively in the terminal, for use outside of Alfred.
+
+Enter acts on the selected item, alt-enter chooses its alt mod, ctrl-o its cmd
+mod, ctrl-t its ctrl mod, ctrl-y its shift mod, and ctrl-f its fn mod. Tab
+autocompletes, and escape quits. URLs open in the browser, text to paste is
+copied to the clipboard, and editors and shells run in the terminal. With
+--print, the chosen item's argument is printed instead.
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg, cfgErr := config.LoadFromDefault()
+		if cfgErr != nil {
+			fmt.Fprintf(os.Stderr, "could not load config from %s: %s\n", config.Filename, cfgErr)
+		}
+
+		complete := func(env completion.Environment) alfred.FilterResult {
+			return completion.Complete(cfg, env)
+		}
+		choice, err := picker.Run(complete, strings.Join(args, " "))
+		if err != nil {
+			log.Fatal(err)
+		}
+		if choice == nil {
+			os.Exit(1)
+		}
+
+		if pickPrint {
+			fmt.Println(choice.Arg)
+			return
+		}
+		if err := choice.Perform(cfg, os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			os.Exit(1)
+		}
 	},
 }
 
@@ -188,17 +514,79 @@ func init() {
 		&markdownDescription,
 		"description", "d", false,
 		"include description of the issue or PR. Requires RPC.")
+	markdownCommand.PersistentFlags().StringVarP(
+		&markdownFormat,
+		"format", "f", "markdown",
+		"link format: "+strings.Join(snippets.Formats(), ", "))
+	markdownCommand.PersistentFlags().StringVarP(
+		&markdownTemplate,
+		"template", "t", "",
+		"name of a configured link template to use instead")
+	markdownCommand.PersistentFlags().BoolVarP(
+		&markdownShort,
+		"short", "s", false,
+		"shorten the link text with repo shorthand and the context repo")
+	markdownCommand.PersistentFlags().StringVar(
+		&markdownContext,
+		"context-repo", "",
+		"the repo the link is for, instead of the default repo (with --short)")
+
+	issueReferenceCommand.PersistentFlags().BoolVarP(
+		&referenceShort,
+		"short", "s", false,
+		"shorten references to issues in the context repo")
+	issueReferenceCommand.PersistentFlags().StringVar(
+		&referenceContext,
+		"context-repo", "",
+		"the repo the reference is for, instead of the default repo (with --short)")
+	linkifyCommand.PersistentFlags().BoolVarP(
+		&linkifyDescription,
+		"description", "d", false,
+		"include titles and descriptions. Requires RPC.")
+
+	urlCommand.PersistentFlags().BoolVarP(
+		&urlOpen,
+		"open", "o", false,
+		"open the URL instead of printing it")
 
 	completeCommand.PersistentFlags().BoolVarP(
 		&includeRPC,
 		"include-rpc", "r", false,
 		"force an RPC request for the input (used for debugging)")
+	completeCommand.PersistentFlags().BoolVar(
+		&completeCache,
+		"cache", false,
+		"no longer needed, results are cached whenever they can be")
+	_ = completeCommand.PersistentFlags().MarkDeprecated("cache", "results are cached whenever they can be")
+	completeCommand.PersistentFlags().StringVarP(
+		&completeFormat,
+		"format", "f", "alfred",
+		"output format: "+strings.Join(launcher.Formats(), ", "))
+
+	cloneCommand.PersistentFlags().StringVar(
+		&cloneRoot, "root", "",
+		"project directory to clone into")
+	cloneCommand.PersistentFlags().StringVar(
+		&cloneURL, "url", "",
+		"URL to clone from instead of GitHub")
+
+	pickCommand.PersistentFlags().BoolVarP(
+		&pickPrint,
+		"print", "p", false,
+		"print the chosen item's argument instead of acting on it")
 
 	rootCmd.AddCommand(completeCommand)
 	rootCmd.AddCommand(serverCommand)
 	rootCmd.AddCommand(markdownCommand)
 	rootCmd.AddCommand(issueReferenceCommand)
+	rootCmd.AddCommand(linkifyCommand)
+	rootCmd.AddCommand(urlCommand)
+	rootCmd.AddCommand(changelogCommand)
 	rootCmd.AddCommand(editorScriptCommand)
+	rootCmd.AddCommand(projectsCommand)
+	rootCmd.AddCommand(cloneCommand)
+	rootCmd.AddCommand(worktreeCommand)
+	rootCmd.AddCommand(pickCommand)
 
 	serverCommand.AddCommand(serverRun)
 	serverCommand.AddCommand(serverInstall)
@@ -206,6 +594,11 @@ func init() {
 	serverCommand.AddCommand(serverStart)
 	serverCommand.AddCommand(serverStop)
 	serverCommand.AddCommand(serverRestart)
+	serverCommand.AddCommand(serverStatus)
+
+	projectsCommand.AddCommand(projectsReindex)
+
+	worktreeCommand.AddCommand(worktreeAdd)
 }
 
 func main() {
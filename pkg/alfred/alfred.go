@@ -1,13 +1,25 @@
 package alfred
 
-import "fmt"
+import (
+	"encoding/json"
+	"fmt"
+)
 
 // FilterResult is the final result of an Alfred script filter,
 // to be rendered as JSON.
 type FilterResult struct {
-	Items     Items      `json:"items"`
-	Rerun     float64    `json:"rerun,omitempty"`
-	Variables *Variables `json:"variables,omitempty"`
+	Items         Items      `json:"items"`
+	Rerun         float64    `json:"rerun,omitempty"`
+	Variables     *Variables `json:"variables,omitempty"`
+	Cache         *Cache     `json:"cache,omitempty"`         // optional caching of the results by alfred
+	SkipKnowledge bool       `json:"skipknowledge,omitempty"` // keep the order of the items, ignoring alfred's knowledge of past selections
+}
+
+// Cache tells alfred to reuse the results of a script filter rather than run
+// it again for a while
+type Cache struct {
+	Seconds     int  `json:"seconds"`               // how long to cache for, from 5 to 86400
+	LooseReload bool `json:"loosereload,omitempty"` // show stale results while reloading in the background
 }
 
 // NewFilterResult provides an initialized FilterResult that contains the
@@ -44,10 +56,19 @@ type Item struct {
 	Variables    Variables `json:"variables,omitempty"`    // item-level variables
 	Mods         *Mods     `json:"mods,omitempty"`         // optional modifier keys arguments
 	Text         *Text     `json:"text,omitempty"`         // optional text if copied to clipboard or displayed as large text
-	// Type string // "default", "file", "file:skipcheck" to treat the result as a file
-	// Quicklook string // optional url for quicklook
+	Type         string    `json:"type,omitempty"`         // optional, TypeFile or TypeFileSkipCheck to treat the result as a file
+	QuicklookURL string    `json:"quicklookurl,omitempty"` // optional url or path shown with quicklook
+	Match        string    `json:"match,omitempty"`        // optional text for alfred to filter against instead of the title
+	Action       *Action   `json:"action,omitempty"`       // optional values for universal actions
 }
 
+// Item types, which default to TypeDefault
+const (
+	TypeDefault       = "default"
+	TypeFile          = "file"           // the arg is a file path, checked for existence
+	TypeFileSkipCheck = "file:skipcheck" // the arg is a file path, not checked
+)
+
 // AppendItems is shorthand for adding more items to a FilterResult's Items list
 func (result *FilterResult) AppendItems(items ...Item) {
 	result.Items = append(result.Items, items...)
@@ -74,6 +95,15 @@ type Text struct {
 	LargeType string `json:"largetype,omitempty"`
 }
 
+// Action defines the values passed to alfred's universal actions for an item,
+// by type. If none is given, the arg is used.
+type Action struct {
+	Text []string `json:"text,omitempty"`
+	URL  []string `json:"url,omitempty"`
+	File []string `json:"file,omitempty"`
+	Auto []string `json:"auto,omitempty"` // alfred decides what type these are
+}
+
 // ModItem defines an alternate action for an item
 type ModItem struct {
 	Valid     bool      `json:"valid"`
@@ -83,11 +113,42 @@ type ModItem struct {
 	Variables Variables `json:"variables,omitempty"`
 }
 
-// Mods define alternate actions for an item, with alt or cmd held down
+// Mods define alternate actions for an item, with any of the modifier keys
+// alfred supports held down. They're rendered by MarshalJSON, keyed by the
+// name of the key, e.g. "shift".
 type Mods struct {
-	Alt  *ModItem `json:"alt,omitempty"`
-	Cmd  *ModItem `json:"cmd,omitempty"`
-	Ctrl *ModItem `json:"ctrl,omitempty"`
+	Alt   *ModItem
+	Cmd   *ModItem
+	Ctrl  *ModItem
+	Shift *ModItem
+	Fn    *ModItem
+
+	// Combined mods for more than one modifier key held down, keyed by the keys
+	// joined with a +, e.g. "cmd+shift"
+	Combined map[string]*ModItem
+}
+
+// MarshalJSON renders the single and combined modifier keys together, as
+// alfred expects.
+func (m Mods) MarshalJSON() ([]byte, error) {
+	all := map[string]*ModItem{}
+	for keys, item := range m.Combined {
+		if item != nil {
+			all[keys] = item
+		}
+	}
+	for keys, item := range map[string]*ModItem{
+		"alt":   m.Alt,
+		"cmd":   m.Cmd,
+		"ctrl":  m.Ctrl,
+		"shift": m.Shift,
+		"fn":    m.Fn,
+	} {
+		if item != nil {
+			all[keys] = item
+		}
+	}
+	return json.Marshal(all)
 }
 
 func (t *Text) String() string {
@@ -0,0 +1,64 @@
+package alfred
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestModsMarshalJSON(t *testing.T) {
+	mods := &Mods{
+		Cmd:   &ModItem{Valid: true, Arg: "cmd"},
+		Shift: &ModItem{Valid: true, Arg: "shift"},
+		Fn:    &ModItem{Valid: true, Arg: "fn"},
+		Combined: map[string]*ModItem{
+			"cmd+shift": {Valid: true, Arg: "both"},
+			"alt+ctrl":  nil,
+		},
+	}
+
+	data, err := json.Marshal(Item{Title: "item", Mods: mods})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"title": "item",
+		"valid": false,
+		"mods": {
+			"cmd": {"valid": true, "arg": "cmd"},
+			"shift": {"valid": true, "arg": "shift"},
+			"fn": {"valid": true, "arg": "fn"},
+			"cmd+shift": {"valid": true, "arg": "both"}
+		}
+	}`, string(data))
+}
+
+func TestFilterResultMarshalJSON(t *testing.T) {
+	result := NewFilterResult()
+	result.Cache = &Cache{Seconds: 60, LooseReload: true}
+	result.SkipKnowledge = true
+	result.AppendItems(Item{
+		Title:        "file",
+		Arg:          "/tmp/file",
+		Type:         TypeFileSkipCheck,
+		QuicklookURL: "https://example.com",
+		Match:        "match",
+		Action:       &Action{Text: []string{"one", "two"}, URL: []string{"https://example.com"}},
+	})
+
+	data, err := json.Marshal(result)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"items": [{
+			"title": "file",
+			"arg": "/tmp/file",
+			"valid": false,
+			"type": "file:skipcheck",
+			"quicklookurl": "https://example.com",
+			"match": "match",
+			"action": {"text": ["one", "two"], "url": ["https://example.com"]}
+		}],
+		"cache": {"seconds": 60, "loosereload": true},
+		"skipknowledge": true
+	}`, string(data))
+}
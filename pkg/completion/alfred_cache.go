@@ -0,0 +1,82 @@
+package completion
+
+import (
+	"os"
+	"strings"
+	"time"
+
+	"github.com/zerowidth/gh-shorthand/pkg/alfred"
+	"github.com/zerowidth/gh-shorthand/pkg/config"
+)
+
+const (
+	// bounds for how long alfred caches results, in seconds. Alfred itself
+	// won't cache for less than 5 seconds.
+	minCacheSeconds = 5
+	maxCacheSeconds = 60
+)
+
+// setCache lets alfred cache results that only depend on the input, the config
+// and the project directories, so it doesn't have to run this on every
+// keystroke. Results that made or are waiting on an RPC request are never
+// cached.
+//
+// How long they're cached for is keyed on when the config file or the project
+// index last changed. Right after either changes, results are only cached for
+// the minimum time, and then for longer the more settled things are.
+func (c *completion) setCache() {
+	if c.retry || c.rpcUsed {
+		return
+	}
+
+	changed := c.cfg.ModTime
+	if modTime := projectIndexModTime(c.cfg); modTime.After(changed) {
+		changed = modTime
+	}
+
+	c.result.Cache = &alfred.Cache{
+		Seconds:     cacheSeconds(changed, time.Now()),
+		LooseReload: true,
+	}
+}
+
+// cacheSeconds picks how long to cache results for given when what they depend
+// on last changed: as long as it's been unchanged, within the bounds.
+func cacheSeconds(changed, now time.Time) int {
+	seconds := int(now.Sub(changed).Seconds())
+	if changed.IsZero() || seconds > maxCacheSeconds {
+		return maxCacheSeconds
+	}
+	if seconds < minCacheSeconds {
+		return minCacheSeconds
+	}
+	return seconds
+}
+
+// projectIndexModTime is when the project index was last written, which is
+// whenever a project root is rescanned. Without an index, every run scans the
+// project roots, so it's as if it just changed.
+func projectIndexModTime(cfg config.Config) time.Time {
+	if len(cfg.ProjectDirs) == 0 {
+		return time.Time{}
+	}
+	path, err := cfg.CachePath(projectIndexFile)
+	if err != nil {
+		return time.Now()
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Now()
+	}
+	return info.ModTime()
+}
+
+// projectMatchText is the text alfred matches against for a project directory,
+// when it filters the results itself. Alfred matches the starts of words, so
+// each part of the path is split out.
+func projectMatchText(short string) string {
+	words := strings.FieldsFunc(short, func(r rune) bool {
+		return strings.ContainsRune("/~-_. ", r)
+	})
+	return short + " " + strings.Join(words, " ")
+}
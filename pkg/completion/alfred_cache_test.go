@@ -0,0 +1,68 @@
+package completion
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zerowidth/gh-shorthand/pkg/config"
+)
+
+func TestSetCache(t *testing.T) {
+	cfg := config.Config{
+		ProjectDirs: []string{"testdata/work"},
+		CacheDir:    t.TempDir(),
+	}
+	complete := func(query string) *int {
+		result := Complete(cfg, Environment{Query: query, Start: time.Now()})
+		if result.Cache == nil {
+			return nil
+		}
+		assert.True(t, result.Cache.LooseReload)
+		return &result.Cache.Seconds
+	}
+
+	for _, query := range []string{"", "n ", "e ", "e work", "t foo"} {
+		if seconds := complete(query); assert.NotNil(t, seconds, query) {
+			assert.Equal(t, minCacheSeconds, *seconds, "without an index the roots are scanned every time")
+		}
+	}
+
+	_, err := ReindexProjects(cfg)
+	require.NoError(t, err)
+	if seconds := complete("e work"); assert.NotNil(t, seconds) {
+		assert.Equal(t, minCacheSeconds, *seconds, "the project index just changed")
+	}
+
+	index, err := cfg.CachePath(projectIndexFile)
+	require.NoError(t, err)
+	settled := time.Now().Add(-time.Hour)
+	require.NoError(t, os.Chtimes(index, settled, settled))
+	if seconds := complete("e work"); assert.NotNil(t, seconds) {
+		assert.Equal(t, maxCacheSeconds, *seconds, "nothing's changed in a while")
+	}
+
+	cfg.ModTime = time.Now()
+	if seconds := complete("e work"); assert.NotNil(t, seconds) {
+		assert.Equal(t, minCacheSeconds, *seconds, "the config just changed")
+	}
+
+	cfg.APIToken = "token"
+	cfg.DefaultRepo = "zerowidth/default"
+	assert.Nil(t, complete(" "), "waiting on RPC")
+	assert.Nil(t, complete(" 123"), "waiting on RPC")
+}
+
+func TestCacheSeconds(t *testing.T) {
+	now := time.Now()
+	assert.Equal(t, maxCacheSeconds, cacheSeconds(time.Time{}, now), "never changed")
+	assert.Equal(t, minCacheSeconds, cacheSeconds(now, now))
+	assert.Equal(t, 30, cacheSeconds(now.Add(-30*time.Second), now))
+	assert.Equal(t, maxCacheSeconds, cacheSeconds(now.Add(-time.Hour), now))
+}
+
+func TestProjectMatchText(t *testing.T) {
+	assert.Equal(t, "~/code/gh-shorthand code gh shorthand", projectMatchText("~/code/gh-shorthand"))
+}
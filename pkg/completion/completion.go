@@ -10,6 +10,7 @@ import (
 	"github.com/zerowidth/gh-shorthand/pkg/config"
 	"github.com/zerowidth/gh-shorthand/pkg/parser"
 	"github.com/zerowidth/gh-shorthand/pkg/rpc"
+	"github.com/zerowidth/gh-shorthand/pkg/snippets"
 )
 
 const (
@@ -33,9 +34,15 @@ type completion struct {
 	input     string        // the input string from the user (minus mode)
 	rpcClient rpc.Client
 
+	links links // renders links for mods
+
 	// output
-	result alfred.FilterResult // the final assembled result
-	retry  bool                // should this script be re-invoked? (for RPC)
+	result  alfred.FilterResult // the final assembled result
+	retry   bool                // should this script be re-invoked? (for RPC)
+	rpcUsed bool                // whether the result includes anything from RPC
+
+	// the protocol version of an RPC server older than this binary, if any
+	outdatedServer *int
 }
 
 // Complete runs the main completion code
@@ -51,10 +58,26 @@ func Complete(cfg config.Config, env Environment) alfred.FilterResult {
 		env:       env,
 		result:    alfred.NewFilterResult(),
 		input:     input,
-		rpcClient: rpc.NewClient(cfg.SocketPath),
+		rpcClient: rpc.NewClient(cfg),
 	}
+	formats, formatErr := linkFormats(cfg)
+	templates, templateErr := snippets.NewTemplates(cfg)
+	c.links = links{templates: templates, formats: formats}
+
 	c.appendParsedItems(mode)
+	if len(env.Query) > 0 {
+		if formatErr != nil {
+			// one item for each, since subtitles are a single line
+			for _, err := range splitErrors(formatErr) {
+				c.result.AppendItems(ErrorItem("Invalid link format", err.Error()))
+			}
+		}
+		if templateErr != nil {
+			c.result.AppendItems(ErrorItem("Invalid link template", templateErr.Error()))
+		}
+	}
 	c.finalizeResult()
+	c.setCache()
 
 	return c.result
 }
@@ -99,10 +122,10 @@ func (c *completion) appendParsedItems(mode string) {
 		result := parser.Parse(c.input)
 
 		if result.HasRepo() {
-			item := openRepoItem(result)
+			item := openRepoItem(result, c.links)
 			if result.HasIssue() {
 				c.retrieveIssue(result.Repo(), result.Issue, &item)
-			} else {
+			} else if !result.HasCommit() {
 				c.retrieveRepo(result.Repo(), &item)
 			}
 			c.result.AppendItems(item)
@@ -192,11 +215,15 @@ func (c *completion) appendParsedItems(mode string) {
 
 	case "e":
 		c.result.AppendItems(
-			projectDirItems(c.cfg.ProjectDirs, c.input, modeEdit)...)
+			projectDirItems(c.cfg, c.input, modeEdit)...)
 
 	case "t":
 		c.result.AppendItems(
-			projectDirItems(c.cfg.ProjectDirs, c.input, modeTerm)...)
+			projectDirItems(c.cfg, c.input, modeTerm)...)
+
+	case "c":
+		c.result.AppendItems(
+			cloneItems(c.cfg, c.input)...)
 
 	case "s":
 		searchItem := globalIssueSearchItem(c.input)
@@ -206,7 +233,7 @@ func (c *completion) appendParsedItems(mode string) {
 	}
 }
 
-func openRepoItem(parsed *parser.Result) alfred.Item {
+func openRepoItem(parsed *parser.Result, l links) alfred.Item {
 	uid := "gh:" + parsed.Repo()
 	title := "Open " + parsed.Repo()
 	arg := "https://github.com/" + parsed.Repo()
@@ -218,7 +245,24 @@ func openRepoItem(parsed *parser.Result) alfred.Item {
 		title += "#" + parsed.Issue
 		arg += "/issues/" + parsed.Issue
 		icon = issueIcon
-		mods = issueMods(parsed.Repo(), parsed.Issue, "")
+		mods = issueMods(snippets.LinkData{
+			Repo:   parsed.Repo(),
+			Number: parsed.Issue,
+			URL:    arg,
+		}, l)
+	}
+
+	if parsed.HasCommit() {
+		uid += "@" + parsed.Commit
+		title += "@" + parsed.Commit
+		arg += "/commit/" + parsed.Commit
+		icon = commitIcon
+		mods = &alfred.Mods{Shift: copyURLMod(arg, parsed.Repo()+"@"+parsed.Commit)}
+	}
+
+	quicklook := ""
+	if (parsed.HasIssue() || parsed.HasCommit()) && !parsed.HasPath() {
+		quicklook = arg
 	}
 
 	if parsed.HasPath() {
@@ -228,20 +272,21 @@ func openRepoItem(parsed *parser.Result) alfred.Item {
 		icon = pathIcon
 	}
 
-	if !parsed.HasIssue() && !parsed.HasPath() {
-		mods = repoMods(parsed.Repo())
+	if !parsed.HasIssue() && !parsed.HasCommit() && !parsed.HasPath() {
+		mods = repoMods(parsed.Repo(), l)
 	}
 
 	title += parsed.Annotation()
 
 	return alfred.Item{
-		UID:       uid,
-		Title:     title,
-		Arg:       arg,
-		Valid:     true,
-		Icon:      icon,
-		Variables: alfred.Variables{"action": "open"},
-		Mods:      mods,
+		UID:          uid,
+		Title:        title,
+		Arg:          arg,
+		Valid:        true,
+		Icon:         icon,
+		Variables:    alfred.Variables{"action": "open"},
+		Mods:         mods,
+		QuicklookURL: quicklook,
 	}
 }
 
@@ -552,17 +597,46 @@ func autocompleteUserItems(cfg config.Config, input string,
 	return
 }
 
+// splitErrors returns the errors joined together in an error, or the error
+// itself if it isn't a joined error.
+func splitErrors(err error) []error {
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		return joined.Unwrap()
+	}
+	return []error{err}
+}
+
 func (c *completion) rpcRequest(path, query string, delay float64) rpc.Result {
+	return c.rpcResult(delay, func() rpc.Result {
+		return c.rpcClient.Query(path, query)
+	})
+}
+
+// rpcLookup looks up a single repo, issue or project through the batch
+// endpoint, which shares its cache with the linkify and snippet lookups.
+func (c *completion) rpcLookup(action, query string, delay float64) rpc.Result {
+	key := rpc.BatchKey(action, query)
+	return c.rpcResult(delay, func() rpc.Result {
+		return c.rpcClient.Batch([]string{key})[key]
+	})
+}
+
+func (c *completion) rpcResult(delay float64, request func() rpc.Result) rpc.Result {
 	if !c.cfg.RPCEnabled() {
 		panic("rpc not enabled") // should be exercised by tests only, FIXME remove
 	}
+	c.rpcUsed = true
 	if c.env.Duration().Seconds() < delay {
 		c.retry = true
 		return rpc.Result{Complete: false}
 	}
 
-	res := c.rpcClient.Query(path, query)
+	res := request()
 
+	if res.FromOutdatedServer() {
+		version := res.Version
+		c.outdatedServer = &version
+	}
 	if !res.Complete && len(res.Error) == 0 {
 		c.retry = true
 	}
@@ -580,7 +654,7 @@ func (c *completion) retrieveRepo(repo string, item *alfred.Item) {
 	if !c.cfg.RPCEnabled() {
 		return
 	}
-	res := c.rpcRequest("/repo", repo, delay)
+	res := c.rpcLookup("repo", repo, delay)
 	if len(res.Error) > 0 {
 		item.Subtitle = res.Error
 		return
@@ -597,15 +671,13 @@ func (c *completion) retrieveRepo(repo string, item *alfred.Item) {
 	item.Subtitle = res.Repos[0].Description
 
 	if item.Mods != nil {
-		item.Mods.Ctrl = &alfred.ModItem{
-			Valid: true,
-			Arg: fmt.Sprintf("[%s: %s](https://github.com/%s)",
-				repo, res.Repos[0].Description, repo),
-			Subtitle: fmt.Sprintf("Insert Markdown link with description to %s",
-				repo),
-			Variables: alfred.Variables{"action": "paste"},
-			Icon:      markdownIcon,
+		data := snippets.LinkData{
+			Repo:  repo,
+			Title: res.Repos[0].Description,
+			URL:   "https://github.com/" + repo,
 		}
+		item.Mods.Ctrl = c.links.mod(snippets.DescriptionLinkTemplate, data,
+			fmt.Sprintf("Insert Markdown link with description to %s", repo), markdownIcon)
 	}
 }
 
@@ -614,7 +686,7 @@ func (c *completion) retrieveIssue(repo, issuenum string, item *alfred.Item) {
 	if !c.cfg.RPCEnabled() {
 		return
 	}
-	res := c.rpcRequest("/issue", repo+"#"+issuenum, delay)
+	res := c.rpcLookup("issue", repo+"#"+issuenum, delay)
 	switch {
 	case len(res.Error) > 0:
 		item.Subtitle = res.Error
@@ -633,15 +705,12 @@ func (c *completion) retrieveIssue(repo, issuenum string, item *alfred.Item) {
 	item.Title = issue.Title
 	item.Icon = issueStateIcon(issue.Type, issue.State)
 	if item.Mods != nil {
-		item.Mods.Ctrl = &alfred.ModItem{
-			Valid: true,
-			Arg: fmt.Sprintf("[%s#%s: %s](https://github.com/%s/issues/%s)",
-				repo, issuenum, issue.Title, repo, issuenum),
-			Subtitle: fmt.Sprintf("Insert Markdown link with description to %s#%s",
-				repo, issuenum),
-			Variables: alfred.Variables{"action": "paste"},
-			Icon:      markdownIcon,
+		data := snippets.LinkData{
+			Repo:   repo,
+			Number: issuenum,
+			URL:    fmt.Sprintf("https://github.com/%s/issues/%s", repo, issuenum),
 		}
+		item.Mods = issueMods(data.WithIssue(issue), c.links)
 	}
 }
 
@@ -657,7 +726,7 @@ func (c *completion) retrieveProject(item *alfred.Item, query string) {
 	if !c.cfg.RPCEnabled() {
 		return
 	}
-	res := c.rpcRequest("/project", query, delay)
+	res := c.rpcLookup("project", query, delay)
 	switch {
 	case len(res.Error) > 0:
 		item.Subtitle = res.Error
@@ -752,11 +821,11 @@ func (c *completion) searchIssues(item *alfred.Item, query string, includeRepo b
 		return items
 	}
 
-	items = append(items, issueItemsFromIssues(res.Issues, includeRepo)...)
+	items = append(items, issueItemsFromIssues(res.Issues, includeRepo, c.links)...)
 	return items
 }
 
-func issueItemsFromIssues(issues []rpc.Issue, includeRepo bool) alfred.Items {
+func issueItemsFromIssues(issues []rpc.Issue, includeRepo bool, l links) alfred.Items {
 	var items alfred.Items
 
 	for _, issue := range issues {
@@ -773,60 +842,66 @@ func issueItemsFromIssues(issues []rpc.Issue, includeRepo bool) alfred.Items {
 
 		// no UID so alfred doesn't remember these
 		items = append(items, alfred.Item{
-			Title:     itemTitle,
-			Subtitle:  fmt.Sprintf("Open %s#%s", issue.Repo, issue.Number),
-			Valid:     true,
-			Arg:       arg,
-			Icon:      issueStateIcon(issue.Type, issue.State),
-			Variables: alfred.Variables{"action": "open"},
-			Mods:      issueMods(issue.Repo, issue.Number, issue.Title),
+			Title:        itemTitle,
+			Subtitle:     fmt.Sprintf("Open %s#%s", issue.Repo, issue.Number),
+			Valid:        true,
+			Arg:          arg,
+			Icon:         issueStateIcon(issue.Type, issue.State),
+			Variables:    alfred.Variables{"action": "open"},
+			Mods:         issueMods(issueLinkData(issue), l),
+			QuicklookURL: arg,
 		})
 	}
 
 	return items
 }
 
-func repoMods(repo string) *alfred.Mods {
-	return &alfred.Mods{
-		Cmd: &alfred.ModItem{
-			Valid:     true,
-			Arg:       fmt.Sprintf("[%s](https://github.com/%s)", repo, repo),
-			Subtitle:  fmt.Sprintf("Insert Markdown link to %s", repo),
-			Icon:      markdownIcon,
-			Variables: alfred.Variables{"action": "paste"},
-		},
+func repoMods(repo string, l links) *alfred.Mods {
+	data := snippets.LinkData{Repo: repo, URL: "https://github.com/" + repo}
+	mods := &alfred.Mods{
+		Cmd:   l.mod(snippets.LinkTemplate, data, fmt.Sprintf("Insert Markdown link to %s", repo), markdownIcon),
+		Shift: copyURLMod(data.URL, repo),
 	}
+	l.addFormatMods(mods, data)
+	return mods
 }
 
-func issueMods(repo, number, title string) *alfred.Mods {
+func issueMods(data snippets.LinkData, l links) *alfred.Mods {
+	ref := data.Ref()
 	mods := &alfred.Mods{
-		Cmd: &alfred.ModItem{
-			Valid:     true,
-			Arg:       fmt.Sprintf("[%s#%s](https://github.com/%s/issues/%s)", repo, number, repo, number),
-			Subtitle:  fmt.Sprintf("Insert Markdown link to %s#%s", repo, number),
-			Variables: alfred.Variables{"action": "paste"},
-			Icon:      markdownIcon,
-		},
-		Alt: &alfred.ModItem{
-			Valid:     true,
-			Arg:       fmt.Sprintf("%s#%s", repo, number),
-			Subtitle:  fmt.Sprintf("Insert issue reference to %s#%s", repo, number),
-			Variables: alfred.Variables{"action": "paste"},
-			Icon:      issueIcon,
-		},
-	}
-	if len(title) > 0 {
-		mods.Ctrl = &alfred.ModItem{
-			Valid:     true,
-			Arg:       fmt.Sprintf("[%s#%s: %s](https://github.com/%s/issues/%s)", repo, number, title, repo, number),
-			Subtitle:  fmt.Sprintf("Insert Markdown link with description to %s#%s", repo, number),
-			Variables: alfred.Variables{"action": "paste"},
-			Icon:      markdownIcon,
-		}
+		Cmd:   l.mod(snippets.LinkTemplate, data, fmt.Sprintf("Insert Markdown link to %s", ref), markdownIcon),
+		Alt:   l.mod(snippets.ReferenceTemplate, data, fmt.Sprintf("Insert issue reference to %s", ref), issueIcon),
+		Shift: copyURLMod(data.URL, ref),
+	}
+	if len(data.Title) > 0 {
+		mods.Ctrl = l.mod(snippets.DescriptionLinkTemplate, data,
+			fmt.Sprintf("Insert Markdown link with description to %s", ref), markdownIcon)
 	}
+	l.addFormatMods(mods, data)
 	return mods
 }
 
+// issueLinkData is the link data for an issue or PR from RPC, linking to it as
+// an issue
+func issueLinkData(issue rpc.Issue) snippets.LinkData {
+	data := snippets.LinkData{
+		Repo:   issue.Repo,
+		Number: issue.Number,
+		URL:    fmt.Sprintf("https://github.com/%s/issues/%s", issue.Repo, issue.Number),
+	}
+	return data.WithIssue(issue)
+}
+
+func copyURLMod(url, name string) *alfred.ModItem {
+	return &alfred.ModItem{
+		Valid:     true,
+		Arg:       url,
+		Subtitle:  "Copy URL for " + name,
+		Icon:      pathIcon,
+		Variables: alfred.Variables{"action": "copy"},
+	}
+}
+
 // ErrorItem returns an error message entry to display in alfred
 func ErrorItem(title, subtitle string) alfred.Item {
 	return alfred.Item{
@@ -837,7 +912,20 @@ func ErrorItem(title, subtitle string) alfred.Item {
 	}
 }
 
+// outdatedServerItem warns that the RPC server is older than this binary, as
+// happens after an upgrade until the server is restarted, which can leave
+// results missing their details.
+func outdatedServerItem(version int) alfred.Item {
+	return ErrorItem("The gh-shorthand server is out of date",
+		fmt.Sprintf("It speaks RPC protocol version %d, not %d: run `gh-shorthand server restart`",
+			version, rpc.ProtocolVersion))
+}
+
 func (c *completion) finalizeResult() {
+	if c.outdatedServer != nil {
+		c.result.AppendItems(outdatedServerItem(*c.outdatedServer))
+	}
+
 	// automatically set "open <url>" urls to copy/large text
 	for i, item := range c.result.Items {
 		if item.Text == nil && item.Variables != nil {
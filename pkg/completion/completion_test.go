@@ -1,6 +1,7 @@
 package completion
 
 import (
+	"errors"
 	"fmt"
 	"path/filepath"
 	"strings"
@@ -10,6 +11,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/zerowidth/gh-shorthand/pkg/alfred"
 	"github.com/zerowidth/gh-shorthand/pkg/config"
+	"github.com/zerowidth/gh-shorthand/pkg/rpc"
 )
 
 var defaultCfg = &config.Config{
@@ -62,6 +64,10 @@ type completeTestCase struct {
 	cmdModAction string         // cmd modifier action, if applicable
 	altModArg    string         // alt modifier argument, if applicable
 	altModAction string         // alt modifier action, if applicable
+	shiftModArg  string         // shift modifier argument, if applicable
+	shiftModAct  string         // shift modifier action, if applicable
+	quicklook    string         // the quicklook URL, if applicable
+	itemType     string         // the item type, if applicable
 }
 
 func (tc *completeTestCase) testItem(t *testing.T) {
@@ -145,6 +151,20 @@ func (tc *completeTestCase) testItem(t *testing.T) {
 		}
 	}
 
+	if len(tc.shiftModArg) > 0 && assert.NotNil(t, item.Mods, "item.Mods in\n#%v", item) &&
+		assert.NotNil(t, item.Mods.Shift, "item.Mods.Shift") {
+		assert.Equal(t, tc.shiftModArg, item.Mods.Shift.Arg, "item.Mods.Shift.Arg")
+		assert.Equal(t, tc.shiftModAct, item.Mods.Shift.Variables["action"], "item.Mods.Shift.Variables['action']")
+	}
+
+	if len(tc.quicklook) > 0 {
+		assert.Equal(t, tc.quicklook, item.QuicklookURL, "item.QuicklookURL in\n%#v", item)
+	}
+
+	if len(tc.itemType) > 0 {
+		assert.Equal(t, tc.itemType, item.Type, "item.Type in\n%#v", item)
+	}
+
 	assert.NotContains(t, item.Subtitle, "rpc error")
 }
 
@@ -461,6 +481,35 @@ func TestCompleteItems(t *testing.T) {
 			arg:    "https://github.com/zerowidth/default/issues/123",
 			copy:   "https://github.com/zerowidth/default/issues/123",
 		},
+		{
+			test:        "open an issue with quicklook and a copy URL mod",
+			input:       " 123",
+			uid:         "gh:zerowidth/default#123",
+			valid:       true,
+			quicklook:   "https://github.com/zerowidth/default/issues/123",
+			shiftModArg: "https://github.com/zerowidth/default/issues/123",
+			shiftModAct: "copy",
+		},
+		{
+			test:        "open a commit",
+			input:       " foo/bar@abc1234",
+			uid:         "gh:foo/bar@abc1234",
+			valid:       true,
+			title:       "Open foo/bar@abc1234",
+			arg:         "https://github.com/foo/bar/commit/abc1234",
+			action:      "open",
+			quicklook:   "https://github.com/foo/bar/commit/abc1234",
+			shiftModArg: "https://github.com/foo/bar/commit/abc1234",
+			shiftModAct: "copy",
+		},
+		{
+			test:        "open a repo with a copy URL mod",
+			input:       " foo/bar",
+			uid:         "gh:foo/bar",
+			valid:       true,
+			shiftModArg: "https://github.com/foo/bar",
+			shiftModAct: "copy",
+		},
 		{
 			test:   "open the default repo when default is also in map",
 			cfg:    defaultInMap,
@@ -845,6 +894,13 @@ func TestCompleteItems(t *testing.T) {
 			uid:   "ghe:testdata/work/work-foo",
 			valid: true,
 		},
+		{
+			test:     "edit project items are files",
+			input:    "e work-foo",
+			uid:      "ghe:testdata/work/work-foo",
+			valid:    true,
+			itemType: alfred.TypeFileSkipCheck,
+		},
 		{
 			test:    "edit project with input excludes non-matches",
 			input:   "e work-foo",
@@ -918,3 +974,147 @@ func TestFinalizeResult(t *testing.T) {
 	c.finalizeResult()
 	assert.Equal(t, rerunAfter, c.result.Rerun, "c.result.Rerun in result\n%#v", c.result)
 }
+
+// staticClient returns the same result for every query
+type staticClient struct {
+	res rpc.Result
+}
+
+func (c staticClient) Query(endpoint, query string) rpc.Result {
+	return c.res
+}
+
+func (c staticClient) Batch(keys []string) map[string]rpc.Result {
+	results := map[string]rpc.Result{}
+	for _, key := range keys {
+		results[key] = c.res
+	}
+	return results
+}
+
+func TestOutdatedServerWarning(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		res     rpc.Result
+		warning string
+	}{
+		{
+			name: "current server",
+			res:  rpc.Result{Complete: true, Version: rpc.ProtocolVersion, Repos: []rpc.Repo{{Description: "dotfiles"}}},
+		},
+		{
+			name:    "unversioned server",
+			res:     rpc.Result{Complete: true, Repos: []rpc.Repo{{Description: "dotfiles"}}},
+			warning: "It speaks RPC protocol version 0, not 1: run `gh-shorthand server restart`",
+		},
+		{
+			name:    "missing endpoint",
+			res:     rpc.Result{Complete: true, Error: "RPC service error: 404 Not Found"},
+			warning: "It speaks RPC protocol version 0, not 1: run `gh-shorthand server restart`",
+		},
+		{
+			name: "other errors",
+			res:  rpc.Result{Complete: true, Error: "RPC service error: connection refused"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			c := completion{
+				cfg:       config.Config{APIToken: "token"},
+				env:       Environment{Query: " df", Start: time.Now().Add(-time.Second)},
+				result:    alfred.NewFilterResult(),
+				rpcClient: staticClient{res: tc.res},
+			}
+			item := alfred.Item{Title: "Open zerowidth/dotfiles"}
+			c.retrieveRepo("zerowidth/dotfiles", &item)
+			c.finalizeResult()
+
+			warning, ok := findMatchingItem("", "The gh-shorthand server is out of date", c.result.Items)
+			if len(tc.warning) == 0 {
+				assert.False(t, ok, "no warning")
+				return
+			}
+			if assert.True(t, ok, "warning item") {
+				assert.Equal(t, tc.warning, warning.Subtitle)
+				assert.False(t, warning.Valid)
+			}
+		})
+	}
+}
+
+// countingClient records the single queries and batch requests it's asked for
+type countingClient struct {
+	queries []string
+	batches [][]string
+}
+
+func (c *countingClient) Query(endpoint, query string) rpc.Result {
+	c.queries = append(c.queries, endpoint+" "+query)
+	return rpc.Result{Complete: true}
+}
+
+func (c *countingClient) Batch(keys []string) map[string]rpc.Result {
+	c.batches = append(c.batches, keys)
+	results := map[string]rpc.Result{}
+	for _, key := range keys {
+		results[key] = rpc.Result{Complete: true, Version: rpc.ProtocolVersion,
+			Repos:    []rpc.Repo{{Description: "dotfiles"}},
+			Issues:   []rpc.Issue{{Title: "an issue"}},
+			Projects: []rpc.Project{{Name: "a project"}}}
+	}
+	return results
+}
+
+func TestLookupsUseBatchEndpoint(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		retrieve func(c *completion, item *alfred.Item)
+		key      string
+		title    string
+	}{
+		{
+			name:     "repo",
+			retrieve: func(c *completion, item *alfred.Item) { c.retrieveRepo("zerowidth/dotfiles", item) },
+			key:      "repo:zerowidth/dotfiles",
+		},
+		{
+			name:     "issue",
+			retrieve: func(c *completion, item *alfred.Item) { c.retrieveIssue("zerowidth/dotfiles", "1", item) },
+			key:      "issue:zerowidth/dotfiles#1",
+			title:    "an issue",
+		},
+		{
+			name:     "project",
+			retrieve: func(c *completion, item *alfred.Item) { c.retrieveOrgProject("zerowidth", "1", item) },
+			key:      "project:zerowidth/1",
+			title:    "a project",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			client := &countingClient{}
+			c := completion{
+				cfg:       config.Config{APIToken: "token"},
+				env:       Environment{Start: time.Now().Add(-time.Second)},
+				result:    alfred.NewFilterResult(),
+				rpcClient: client,
+			}
+			item := alfred.Item{Title: "Open"}
+			tc.retrieve(&c, &item)
+
+			assert.Empty(t, client.queries, "no single queries")
+			assert.Equal(t, [][]string{{tc.key}}, client.batches, "one batch request")
+			if len(tc.title) > 0 {
+				assert.Equal(t, tc.title, item.Title)
+			} else {
+				assert.Equal(t, "dotfiles", item.Subtitle)
+			}
+		})
+	}
+}
+
+func TestSplitErrors(t *testing.T) {
+	one, two := errors.New("one"), errors.New("two")
+	assert.Equal(t, []error{one, two}, splitErrors(errors.Join(one, two)))
+	assert.Equal(t, []error{one}, splitErrors(one))
+	wrapped := fmt.Errorf("wrapped: %w", one)
+	assert.Equal(t, []error{wrapped}, splitErrors(wrapped))
+}
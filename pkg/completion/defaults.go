@@ -34,5 +34,10 @@ var (
 			Autocomplete: "e ",
 			Icon:         editorIcon,
 		},
+		alfred.Item{
+			Title:        "Clone a GitHub repository",
+			Autocomplete: "c ",
+			Icon:         repoIcon,
+		},
 	}
 )
@@ -4,6 +4,8 @@ import (
 	"os"
 	"strconv"
 	"time"
+
+	"github.com/zerowidth/gh-shorthand/pkg/alfred"
 )
 
 // Environment represents the runtime environment from Alfred's invocation of
@@ -21,14 +23,28 @@ type Environment struct {
 //
 // Exported publicly for use with debugging
 func LoadAlfredEnvironment(input string) Environment {
+	return loadEnvironment(input, os.LookupEnv)
+}
+
+// EnvironmentFromVariables builds the runtime environment for a re-run of the
+// completion from the variables set in the previous result, the same way
+// Alfred passes them on to its re-invocation of the script filter.
+func EnvironmentFromVariables(input string, vars alfred.Variables) Environment {
+	return loadEnvironment(input, func(name string) (string, bool) {
+		value, ok := vars[name]
+		return value, ok
+	})
+}
+
+func loadEnvironment(input string, lookup func(string) (string, bool)) Environment {
 	e := Environment{
 		Query: input,
 		Start: time.Now(),
 	}
 
-	if query, ok := os.LookupEnv("query"); ok && query == input {
-		if sStr, ok := os.LookupEnv("s"); ok {
-			if nsStr, ok := os.LookupEnv("ns"); ok {
+	if query, ok := lookup("query"); ok && query == input {
+		if sStr, ok := lookup("s"); ok {
+			if nsStr, ok := lookup("ns"); ok {
 				if s, err := strconv.ParseInt(sStr, 10, 64); err == nil {
 					if ns, err := strconv.ParseInt(nsStr, 10, 64); err == nil {
 						e.Start = time.Unix(s, ns)
@@ -8,19 +8,19 @@ import (
 
 var (
 	// githubIcon      = octicon("mark-github")
-	repoIcon = octicon("repo")
-	// pullRequestIcon = octicon("git-pull-request")
-	issueListIcon = octicon("list-ordered")
-	pathIcon      = octicon("browser")
-	issueIcon     = octicon("issue-opened")
-	projectIcon   = octicon("project")
-	newIssueIcon  = octicon("bug")
-	editorIcon    = octicon("file-code")
-	finderIcon    = octicon("file-directory")
-	terminalIcon  = octicon("terminal")
-	markdownIcon  = octicon("markdown")
-	searchIcon    = octicon("search")
-	// commitIcon    = octicon("git-commit")
+	repoIcon        = octicon("repo")
+	pullRequestIcon = octicon("git-pull-request")
+	issueListIcon   = octicon("list-ordered")
+	pathIcon        = octicon("browser")
+	issueIcon       = octicon("issue-opened")
+	projectIcon     = octicon("project")
+	newIssueIcon    = octicon("bug")
+	editorIcon      = octicon("file-code")
+	finderIcon      = octicon("file-directory")
+	terminalIcon    = octicon("terminal")
+	markdownIcon    = octicon("markdown")
+	searchIcon      = octicon("search")
+	commitIcon      = octicon("git-commit")
 
 	issueIconOpen         = octicon("issue-opened_open")
 	issueIconClosed       = octicon("issue-closed_closed")
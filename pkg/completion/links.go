@@ -0,0 +1,83 @@
+package completion
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/zerowidth/gh-shorthand/pkg/alfred"
+	"github.com/zerowidth/gh-shorthand/pkg/config"
+	"github.com/zerowidth/gh-shorthand/pkg/snippets"
+)
+
+// links renders the links to repos and issues that items offer as mods, using
+// the link templates and any extra link formats.
+type links struct {
+	templates *snippets.Templates
+	formats   []snippets.Format
+}
+
+// linkFormatKeys are the mod keys for links in the extra link formats, in the
+// order the formats are configured.
+var linkFormatKeys = []string{"cmd+alt", "cmd+ctrl", "cmd+shift", "cmd+fn"}
+
+// linkFormats looks up the configured link formats. Returns the valid ones,
+// up to the number of linkFormatKeys, along with the errors for any that
+// aren't joined into one.
+func linkFormats(cfg config.Config) ([]snippets.Format, error) {
+	var formats []snippets.Format
+	var errs []error
+	for _, name := range cfg.LinkFormats {
+		format, err := snippets.LookupFormat(name)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		formats = append(formats, format)
+	}
+	if len(formats) > len(linkFormatKeys) {
+		formats = formats[:len(linkFormatKeys)]
+		errs = append(errs, fmt.Errorf("only %d link formats can be used", len(linkFormatKeys)))
+	}
+	return formats, errors.Join(errs...)
+}
+
+// mod is a mod to insert a link rendered from a link template. If the template
+// can't be rendered, the mod shows the error instead.
+func (l links) mod(name string, data snippets.LinkData, subtitle string, icon *alfred.Icon) *alfred.ModItem {
+	arg, err := l.templates.Render(name, data)
+	if err != nil {
+		return &alfred.ModItem{
+			Valid:    false,
+			Subtitle: err.Error(),
+			Icon:     octicon("alert"),
+		}
+	}
+	return &alfred.ModItem{
+		Valid:     true,
+		Arg:       arg,
+		Subtitle:  subtitle,
+		Icon:      icon,
+		Variables: alfred.Variables{"action": "paste"},
+	}
+}
+
+// addFormatMods adds mods to insert a link in each of the extra formats,
+// including the title if there is one.
+func (l links) addFormatMods(mods *alfred.Mods, data snippets.LinkData) {
+	text := data.Ref()
+	if len(data.Title) > 0 {
+		text += ": " + data.Title
+	}
+	for i, format := range l.formats {
+		if mods.Combined == nil {
+			mods.Combined = map[string]*alfred.ModItem{}
+		}
+		mods.Combined[linkFormatKeys[i]] = &alfred.ModItem{
+			Valid:     true,
+			Arg:       format.Link(text, data.URL),
+			Subtitle:  fmt.Sprintf("Insert %s link to %s", format.Title, data.Ref()),
+			Icon:      markdownIcon,
+			Variables: alfred.Variables{"action": "paste"},
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package completion
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zerowidth/gh-shorthand/pkg/config"
+	"github.com/zerowidth/gh-shorthand/pkg/rpc"
+	"github.com/zerowidth/gh-shorthand/pkg/snippets"
+)
+
+func TestLinkFormatMods(t *testing.T) {
+	cfg := config.Config{LinkFormats: []string{"slack", "org"}}
+
+	result := Complete(cfg, Environment{Query: " zw/df#1", Start: time.Now()})
+	require.NotEmpty(t, result.Items)
+	mods := result.Items[0].Mods
+	require.NotNil(t, mods)
+	require.Len(t, mods.Combined, 2)
+	assert.Equal(t, "<https://github.com/zw/df/issues/1|zw/df#1>", mods.Combined["cmd+alt"].Arg)
+	assert.Equal(t, "Insert Slack link to zw/df#1", mods.Combined["cmd+alt"].Subtitle)
+	assert.Equal(t, "[[https://github.com/zw/df/issues/1][zw/df#1]]", mods.Combined["cmd+ctrl"].Arg)
+	assert.Equal(t, "paste", mods.Combined["cmd+ctrl"].Variables["action"])
+
+	result = Complete(cfg, Environment{Query: " zw/df", Start: time.Now()})
+	require.NotEmpty(t, result.Items)
+	assert.Equal(t, "<https://github.com/zw/df|zw/df>", result.Items[0].Mods.Combined["cmd+alt"].Arg)
+
+	templates, err := snippets.NewTemplates(cfg)
+	require.NoError(t, err)
+	items := issueItemsFromIssues([]rpc.Issue{{Type: "PullRequest", Repo: "zw/df", Number: "2", Title: "a patch"}}, false,
+		links{templates: templates, formats: []snippets.Format{snippets.Markdown}})
+	require.Len(t, items, 1)
+	assert.Equal(t, "[zw/df#2: a patch](https://github.com/zw/df/issues/2)", items[0].Mods.Combined["cmd+alt"].Arg)
+}
+
+func TestLinkFormatsInvalid(t *testing.T) {
+	cfg := config.Config{LinkFormats: []string{"slack", "wiki"}}
+
+	formats, err := linkFormats(cfg)
+	assert.Error(t, err)
+	assert.Len(t, formats, 1)
+
+	result := Complete(cfg, Environment{Query: " zw/df", Start: time.Now()})
+	require.NotEmpty(t, result.Items)
+	assert.Equal(t, "Invalid link format", result.Items[len(result.Items)-1].Title)
+	assert.Len(t, result.Items[0].Mods.Combined, 1, "valid formats are still used")
+
+	cfg.LinkFormats = []string{"slack", "org", "html", "rst", "asciidoc"}
+	formats, err = linkFormats(cfg)
+	assert.Error(t, err)
+	assert.Len(t, formats, len(linkFormatKeys))
+
+	cfg.LinkFormats = []string{"slack", "wiki", "org", "bogus", "html"}
+	formats, err = linkFormats(cfg)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), `"wiki"`)
+		assert.Contains(t, err.Error(), `"bogus"`)
+	}
+	if assert.Len(t, formats, 3, "formats after an invalid one are still used") {
+		assert.Equal(t, "html", formats[2].Name)
+	}
+
+	result = Complete(cfg, Environment{Query: " zw/df", Start: time.Now()})
+	require.True(t, len(result.Items) > 2)
+	assert.Len(t, result.Items[0].Mods.Combined, 3)
+	last := result.Items[len(result.Items)-2:]
+	assert.Equal(t, "Invalid link format", last[0].Title)
+	assert.Contains(t, last[0].Subtitle, `"wiki"`)
+	assert.Equal(t, "Invalid link format", last[1].Title)
+	assert.Contains(t, last[1].Subtitle, `"bogus"`)
+}
+
+func TestLinkTemplateMods(t *testing.T) {
+	cfg := config.Config{
+		RepoMap: map[string]string{"df": "zw/df"},
+		LinkTemplates: map[string]string{
+			"link":             "{{.Short}}#{{.Number}}",
+			"link_description": "{{.Short}}#{{.Number}} ({{.State}}, @{{.Author}}): {{.Title}}",
+		},
+	}
+	templates, err := snippets.NewTemplates(cfg)
+	require.NoError(t, err)
+	l := links{templates: templates}
+
+	items := issueItemsFromIssues([]rpc.Issue{
+		{Type: "PullRequest", State: "MERGED", Repo: "zw/df", Number: "12", Title: "a patch", Author: "zw"},
+	}, false, l)
+	require.Len(t, items, 1)
+	mods := items[0].Mods
+	assert.Equal(t, "df#12", mods.Cmd.Arg)
+	assert.Equal(t, "zw/df#12", mods.Alt.Arg, "default reference template")
+	assert.Equal(t, "df#12 (merged, @zw): a patch", mods.Ctrl.Arg)
+
+	cfg.LinkTemplates["link"] = "{{.Nope}}"
+	templates, err = snippets.NewTemplates(cfg)
+	require.NoError(t, err)
+	mods = repoMods("zw/df", links{templates: templates})
+	assert.False(t, mods.Cmd.Valid, "template errors are shown")
+	assert.Contains(t, mods.Cmd.Subtitle, "Nope")
+
+	cfg.LinkTemplates["link"] = "{{.Nope"
+	result := Complete(cfg, Environment{Query: " zw/df", Start: time.Now()})
+	require.NotEmpty(t, result.Items)
+	assert.Equal(t, "Invalid link template", result.Items[len(result.Items)-1].Title)
+	assert.Equal(t, "[zw/df](https://github.com/zw/df)", result.Items[0].Mods.Cmd.Arg, "default template is used")
+}
@@ -0,0 +1,188 @@
+package completion
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	homedir "github.com/mitchellh/go-homedir"
+	"github.com/zerowidth/gh-shorthand/pkg/alfred"
+	"github.com/zerowidth/gh-shorthand/pkg/config"
+	"github.com/zerowidth/gh-shorthand/pkg/gitrepo"
+	"github.com/zerowidth/gh-shorthand/pkg/parser"
+)
+
+// cloneRoot is a project directory that repositories can be cloned into
+type cloneRoot struct {
+	short string // as configured, e.g. ~/code
+	path  string // expanded
+}
+
+// cloneItems shows where a repository is checked out if it's already in one of
+// the project directories, and otherwise offers to clone it into each of the
+// project roots.
+func cloneItems(cfg config.Config, input string) alfred.Items {
+	var items alfred.Items
+
+	result := cloneParser(cfg).Parse(input)
+
+	if result.HasRepo() {
+		repo := result.Repo()
+		if short, dir, ok := findCheckout(cfg, repo); ok {
+			item := projectDirItem(short, dir, modeEdit)
+			item.Subtitle = fmt.Sprintf("Edit %s (%s is already cloned)", short, repo)
+			items = append(items, item)
+		} else {
+			roots := cloneRoots(cfg)
+			if len(roots) == 0 {
+				items = append(items, ErrorItem("No project directory to clone "+repo+" into",
+					"project_dirs must include a directory without wildcards"))
+			}
+			for _, root := range roots {
+				items = append(items, cloneItem(result.Repo(), result.Annotation(), root))
+			}
+		}
+	}
+
+	items = append(items,
+		autocompleteItems(cfg, input,
+			autocompleteCloneItem, autocompleteUserCloneItem, openEndedCloneItem)...)
+
+	return items
+}
+
+// cloneParser parses a repository to clone, given as owner/name or shorthand.
+// Only an explicit repository matches: there's no default repo, and anything
+// after the repository, such as an issue or a path, means there's no match.
+func cloneParser(cfg config.Config) *parser.Parser {
+	return parser.NewParser(cfg.RepoMap, cfg.UserMap, "", parser.RequireRepo)
+}
+
+func cloneItem(repo, annotation string, root cloneRoot) alfred.Item {
+	name := repoName(repo)
+	short := path.Join(root.short, name)
+
+	item := alfred.Item{
+		UID:       "ghc:" + repo + ":" + root.short,
+		Title:     "Clone " + repo + annotation,
+		Subtitle:  "Clone into " + short,
+		Arg:       repo,
+		Valid:     true,
+		Icon:      repoIcon,
+		Variables: alfred.Variables{"action": "clone", "root": root.path},
+	}
+
+	if _, err := os.Stat(filepath.Join(root.path, name)); err == nil {
+		item.Valid = false
+		item.Subtitle = short + " already exists"
+	}
+
+	return item
+}
+
+func autocompleteCloneItem(key, repo string) alfred.Item {
+	return alfred.Item{
+		Title:        fmt.Sprintf("Clone %s (%s)", repo, key),
+		Autocomplete: "c " + key,
+		Icon:         repoIcon,
+	}
+}
+
+func autocompleteUserCloneItem(key, user string) alfred.Item {
+	return alfred.Item{
+		Title:        fmt.Sprintf("Clone %s/... (%s)", user, key),
+		Autocomplete: "c " + key + "/",
+		Icon:         repoIcon,
+	}
+}
+
+func openEndedCloneItem(input string) alfred.Item {
+	return alfred.Item{
+		Title:        fmt.Sprintf("Clone %s...", input),
+		Autocomplete: "c " + input,
+		Valid:        false,
+		Icon:         repoIcon,
+	}
+}
+
+// findCheckout looks for a checkout of a GitHub repository in the project
+// directories by matching against each one's origin remote. Returns the short
+// and full paths of the checkout.
+func findCheckout(cfg config.Config, repo string) (string, string, bool) {
+	projects, projectPaths, _ := listProjects(cfg)
+	for _, short := range projects {
+		checkout, err := gitrepo.Open(projectPaths[short])
+		if err != nil {
+			continue
+		}
+		if ghRepo, ok := checkout.GitHubRepo(); ok && strings.EqualFold(ghRepo, repo) {
+			return short, projectPaths[short], true
+		}
+	}
+	return "", "", false
+}
+
+// cloneRoots returns the project directories that can be cloned into. Those
+// containing wildcards are skipped, since there's no single directory to
+// clone into.
+func cloneRoots(cfg config.Config) []cloneRoot {
+	var roots []cloneRoot
+	for _, dir := range cfg.ProjectDirs {
+		if strings.ContainsAny(dir, `*?[\`) {
+			continue
+		}
+		expanded, err := homedir.Expand(dir)
+		if err != nil {
+			continue
+		}
+		roots = append(roots, cloneRoot{short: dir, path: expanded})
+	}
+	return roots
+}
+
+func repoName(repo string) string {
+	return repo[strings.LastIndex(repo, "/")+1:]
+}
+
+// CloneProject clones a GitHub repository, given as owner/name or configured
+// shorthand, into a project directory and returns the path to the checkout.
+//
+// If the repository is already checked out in one of the project directories,
+// the existing checkout is returned instead. The repository is cloned into the
+// first project root unless root is given, and from the URL for the configured
+// clone protocol unless url is given.
+func CloneProject(cfg config.Config, input, root, url string) (string, error) {
+	result := cloneParser(cfg).Parse(input)
+	if !result.HasRepo() {
+		return "", fmt.Errorf("%q is not a repository", input)
+	}
+	repo := result.Repo()
+
+	if _, dir, ok := findCheckout(cfg, repo); ok {
+		return dir, nil
+	}
+
+	if root == "" {
+		roots := cloneRoots(cfg)
+		if len(roots) == 0 {
+			return "", fmt.Errorf("no project directory to clone %s into", repo)
+		}
+		root = roots[0].path
+	}
+	root, err := homedir.Expand(root)
+	if err != nil {
+		return "", err
+	}
+
+	if url == "" {
+		url = cfg.CloneURL(repo)
+	}
+
+	dest := filepath.Join(root, repoName(repo))
+	if err := gitrepo.Clone(url, dest); err != nil {
+		return "", err
+	}
+	return dest, nil
+}
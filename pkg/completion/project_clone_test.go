@@ -0,0 +1,114 @@
+package completion
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zerowidth/gh-shorthand/pkg/config"
+)
+
+func TestCloneItems(t *testing.T) {
+	code := t.TempDir()
+	work := t.TempDir()
+	require.NoError(t, os.Mkdir(filepath.Join(work, "bar"), 0755))
+	existing := newCheckout(t, code, "foo")
+
+	cfg := config.Config{
+		UserMap:     map[string]string{"z": "zerowidth"},
+		ProjectDirs: []string{code, work, filepath.Join(work, "*")},
+		CacheDir:    t.TempDir(),
+	}
+
+	t.Run("already cloned", func(t *testing.T) {
+		items := cloneItems(cfg, "z/FOO")
+		require.NotEmpty(t, items)
+		assert.Equal(t, "ghe:"+existing, items[0].UID)
+		assert.Equal(t, existing, items[0].Arg)
+		assert.Equal(t, "edit", items[0].Variables["action"])
+		assert.Contains(t, items[0].Subtitle, "zerowidth/FOO is already cloned")
+	})
+
+	t.Run("clone into each root", func(t *testing.T) {
+		items := cloneItems(cfg, "zerowidth/baz")
+		require.Len(t, items, 2, "roots with wildcards are skipped")
+		for i, root := range []string{code, work} {
+			assert.Equal(t, "ghc:zerowidth/baz:"+root, items[i].UID)
+			assert.Equal(t, "zerowidth/baz", items[i].Arg)
+			assert.True(t, items[i].Valid)
+			assert.Equal(t, "clone", items[i].Variables["action"])
+			assert.Equal(t, root, items[i].Variables["root"])
+			assert.Equal(t, "Clone into "+filepath.Join(root, "baz"), items[i].Subtitle)
+		}
+	})
+
+	t.Run("destination exists", func(t *testing.T) {
+		items := cloneItems(cfg, "zerowidth/bar")
+		require.Len(t, items, 2)
+		assert.True(t, items[0].Valid)
+		assert.False(t, items[1].Valid)
+		assert.Equal(t, filepath.Join(work, "bar")+" already exists", items[1].Subtitle)
+	})
+
+	t.Run("only an explicit repo", func(t *testing.T) {
+		cfg := cfg
+		cfg.DefaultRepo = "zerowidth/baz"
+		for _, input := range []string{"", "zerowidth/baz#12", "zerowidth/baz/path"} {
+			for _, item := range cloneItems(cfg, input) {
+				assert.NotEqual(t, "clone", item.Variables["action"], input)
+			}
+		}
+	})
+
+	t.Run("autocomplete", func(t *testing.T) {
+		items := cloneItems(cfg, "z")
+		require.NotEmpty(t, items)
+		assert.Equal(t, "c z/", items[0].Autocomplete)
+	})
+}
+
+func TestCloneProject(t *testing.T) {
+	code := t.TempDir()
+	work := t.TempDir()
+	existing := newCheckout(t, code, "foo")
+	origin := filepath.Join(t.TempDir(), "origin.git")
+	git(t, code, "clone", "-q", "--bare", existing, origin)
+
+	cfg := config.Config{
+		RepoMap:     map[string]string{"f": "zerowidth/foo"},
+		ProjectDirs: []string{code, work},
+		CacheDir:    t.TempDir(),
+	}
+
+	path, err := CloneProject(cfg, "f", "", origin)
+	require.NoError(t, err)
+	assert.Equal(t, existing, path, "existing checkout")
+
+	path, err = CloneProject(cfg, "zerowidth/bar", "", origin)
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(code, "bar"), path)
+	assert.DirExists(t, filepath.Join(path, ".git"))
+
+	path, err = CloneProject(cfg, "zerowidth/baz", work, origin)
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(work, "baz"), path)
+
+	_, err = CloneProject(cfg, "zerowidth/baz", work, origin)
+	assert.Error(t, err, "destination exists")
+
+	_, err = CloneProject(cfg, "zerowidth/qux", "", filepath.Join(t.TempDir(), "missing.git"))
+	assert.Error(t, err)
+
+	_, err = CloneProject(cfg, "zerowidth/foo#12", "", origin)
+	assert.Error(t, err)
+	_, err = CloneProject(cfg, "zerowidth/foo /path", "", origin)
+	assert.Error(t, err)
+	cfg.DefaultRepo = "zerowidth/foo"
+	_, err = CloneProject(cfg, "", "", origin)
+	assert.EqualError(t, err, `"" is not a repository`, "no default repo")
+
+	_, err = CloneProject(config.Config{ProjectDirs: []string{code + "/*"}}, "zerowidth/qux", "", origin)
+	assert.Error(t, err, "no root to clone into")
+}
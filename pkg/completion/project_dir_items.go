@@ -5,10 +5,11 @@ import (
 	"path"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/mitchellh/go-homedir"
-	"github.com/sahilm/fuzzy"
 	"github.com/zerowidth/gh-shorthand/pkg/alfred"
+	"github.com/zerowidth/gh-shorthand/pkg/config"
 )
 
 type projectDirMode int
@@ -18,15 +19,55 @@ const (
 	modeTerm
 )
 
-func projectDirItems(searchPaths []string, search string, mode projectDirMode) alfred.Items {
+// projectDirItems lists the project directories matching a search. A branch
+// name can follow the search, separated by a space, to offer creating a
+// worktree for that branch.
+func projectDirItems(cfg config.Config, search string, mode projectDirMode) alfred.Items {
+	projects, projectPaths, items := listProjects(cfg)
+	projects, branches := addWorktrees(projects, projectPaths)
+
+	query, branch, _ := strings.Cut(search, " ")
+	branch = strings.TrimSpace(branch)
+
+	// filter and rank projects by fuzzy search, if applicable
+	if len(query) > 0 {
+		projects = rankProjects(query, projects, projectPaths)
+	}
+
+	counts := loadGitCounts(cfg)
+	deadline := time.Now().Add(gitStatusBudget)
+
+	for i, short := range projects {
+		item := projectDirItem(short, projectPaths[short], mode)
+		if label, ok := branches[short]; ok {
+			item.Title += " (" + label + ")"
+		}
+		if i < gitDetailLimit {
+			addGitDetails(&item, projectPaths[short], counts, deadline)
+			if len(branch) > 0 {
+				addWorktreeMod(&item, short, projectPaths[short], branch)
+			}
+		}
+		items = append(items, item)
+	}
+
+	_ = counts.save()
+
+	return items
+}
+
+// listProjects finds the project directories in every configured project
+// root. Returns the shortened path names of the projects found, a map of those
+// to their full expanded/absolute paths, and error items for invalid roots.
+func listProjects(cfg config.Config) ([]string, map[string]string, alfred.Items) {
 	items := alfred.Items{}
-	// shortened path names of projects found
 	projects := []string{}
-	// map to the full expanded/absolute path for projects
 	projectPaths := map[string]string{}
 
+	discovery := newProjectDiscovery(cfg)
+	index := loadProjectIndex(cfg)
 	home, _ := homedir.Dir()
-	for _, searchPath := range searchPaths {
+	for _, searchPath := range cfg.ProjectDirs {
 		root, err := homedir.Expand(searchPath)
 
 		if err != nil {
@@ -34,7 +75,7 @@ func projectDirItems(searchPaths []string, search string, mode projectDirMode) a
 			continue
 		}
 
-		projectDirs, err := findProjectDirs(root)
+		projectDirs, err := index.find(discovery, root)
 		if err != nil {
 			items = append(items, ErrorItem("Invalid project directory: "+searchPath, err.Error()))
 			continue
@@ -57,63 +98,59 @@ func projectDirItems(searchPaths []string, search string, mode projectDirMode) a
 		}
 	}
 
-	// filter projects by fuzzy search, if applicable
-	if len(search) > 0 {
-		filtered := fuzzy.Find(search, projects)
-		projects = []string{}
-		for _, result := range filtered {
-			projects = append(projects, result.Str)
-		}
-	}
+	// best effort: if the index can't be updated, it'll be rescanned next time
+	_ = index.save()
 
-	for _, short := range projects {
-		var item = alfred.Item{
-			Title: short,
-			Valid: true,
-			Text:  &alfred.Text{Copy: projectPaths[short], LargeType: projectPaths[short]},
-			Mods: &alfred.Mods{
-				Alt: &alfred.ModItem{
-					Valid:     true,
-					Arg:       projectPaths[short],
-					Subtitle:  "Open finder in " + short,
-					Icon:      finderIcon,
-					Variables: alfred.Variables{"action": "finder"},
-				},
-			},
-		}
+	return projects, projectPaths, items
+}
 
-		if mode == modeEdit {
-			item.UID = "ghe:" + short
-			item.Subtitle = "Edit " + short
-			item.Arg = projectPaths[short]
-			item.Variables = alfred.Variables{"action": "edit"}
-			item.Icon = editorIcon
-			item.Mods.Cmd = &alfred.ModItem{
-				Valid:     true,
-				Arg:       projectPaths[short],
-				Subtitle:  "Open terminal in " + short,
-				Icon:      terminalIcon,
-				Variables: alfred.Variables{"action": "term"},
-			}
-		} else {
-			item.UID = "ght:" + short
-			item.Subtitle = "Open terminal in " + short
-			item.Arg = projectPaths[short]
-			item.Variables = alfred.Variables{"action": "term"}
-			item.Icon = terminalIcon
-			item.Mods.Cmd = &alfred.ModItem{
+func projectDirItem(short, path string, mode projectDirMode) alfred.Item {
+	var item = alfred.Item{
+		Title: short,
+		Valid: true,
+		Type:  alfred.TypeFileSkipCheck,
+		Match: projectMatchText(short),
+		Text:  &alfred.Text{Copy: path, LargeType: path},
+		Mods: &alfred.Mods{
+			Alt: &alfred.ModItem{
 				Valid:     true,
-				Arg:       projectPaths[short],
-				Subtitle:  "Edit " + short,
-				Icon:      editorIcon,
-				Variables: alfred.Variables{"action": "edit"},
-			}
-		}
+				Arg:       path,
+				Subtitle:  "Open finder in " + short,
+				Icon:      finderIcon,
+				Variables: alfred.Variables{"action": "finder"},
+			},
+		},
+	}
 
-		items = append(items, item)
+	if mode == modeEdit {
+		item.UID = "ghe:" + short
+		item.Subtitle = "Edit " + short
+		item.Arg = path
+		item.Variables = alfred.Variables{"action": "edit"}
+		item.Icon = editorIcon
+		item.Mods.Cmd = &alfred.ModItem{
+			Valid:     true,
+			Arg:       path,
+			Subtitle:  "Open terminal in " + short,
+			Icon:      terminalIcon,
+			Variables: alfred.Variables{"action": "term"},
+		}
+	} else {
+		item.UID = "ght:" + short
+		item.Subtitle = "Open terminal in " + short
+		item.Arg = path
+		item.Variables = alfred.Variables{"action": "term"}
+		item.Icon = terminalIcon
+		item.Mods.Cmd = &alfred.ModItem{
+			Valid:     true,
+			Arg:       path,
+			Subtitle:  "Edit " + short,
+			Icon:      editorIcon,
+			Variables: alfred.Variables{"action": "edit"},
+		}
 	}
 
-	return items
+	return item
 }
 
 func findProjectDirs(root string) ([]string, error) {
@@ -6,6 +6,7 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"github.com/zerowidth/gh-shorthand/pkg/config"
 )
 
 func TestFindProjectDirs(t *testing.T) {
@@ -44,7 +45,7 @@ func TestFindInvalidProjectDirs(t *testing.T) {
 func TestProjectDirItemsWithFuzzySearch(t *testing.T) {
 	fixturePath, err := filepath.Abs("testdata")
 	require.NoError(t, err)
-	dirs := projectDirItems([]string{"testdata/projects"}, "tdprojbar", modeEdit)
+	dirs := projectDirItems(config.Config{ProjectDirs: []string{"testdata/projects"}}, "tdprojbar", modeEdit)
 	require.Len(t, dirs, 1)
 	assert.Equal(t, fixturePath+"/projects/project-bar", dirs[0].Arg)
 }
@@ -52,9 +53,9 @@ func TestProjectDirItemsWithFuzzySearch(t *testing.T) {
 func TestProjectDirItemsWithGlob(t *testing.T) {
 	fixturePath, err := filepath.Abs("testdata")
 	require.NoError(t, err)
-	dirs := projectDirItems([]string{fixturePath + "/*"}, "", modeEdit)
+	dirs := projectDirItems(config.Config{ProjectDirs: []string{fixturePath + "/*"}}, "", modeEdit)
 	require.Len(t, dirs, 3)
-	dirs = projectDirItems([]string{fixturePath + "/w*"}, "", modeEdit)
+	dirs = projectDirItems(config.Config{ProjectDirs: []string{fixturePath + "/w*"}}, "", modeEdit)
 	require.Len(t, dirs, 1)
 	assert.Equal(t, fixturePath+"/work/work-foo", dirs[0].Arg)
 }
@@ -0,0 +1,197 @@
+package completion
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/zerowidth/gh-shorthand/pkg/config"
+)
+
+// defaultProjectMarkers are the files or directories which identify a project
+// when discovering project directories recursively.
+var defaultProjectMarkers = []string{
+	".git",
+	".hg",
+	"go.mod",
+	"package.json",
+	"Gemfile",
+	"Cargo.toml",
+	"pyproject.toml",
+	"setup.py",
+}
+
+// projectDiscovery defines how project directories are found beneath each
+// configured project root.
+type projectDiscovery struct {
+	depth   int      // how far to descend; 0 lists the root's immediate children
+	markers []string // files or directories marking a directory as a project
+	ignore  []string // base name patterns to skip
+}
+
+func newProjectDiscovery(cfg config.Config) projectDiscovery {
+	markers := cfg.ProjectMarkers
+	if len(markers) == 0 {
+		markers = defaultProjectMarkers
+	}
+	return projectDiscovery{
+		depth:   cfg.ProjectDepth,
+		markers: markers,
+		ignore:  cfg.ProjectIgnore,
+	}
+}
+
+// find returns the project directories for a root, which may contain
+// wildcards.
+//
+// Without a depth configured, every directory directly inside the root is a
+// project. Otherwise the root is walked up to the configured depth, and any
+// directory containing a project marker is a project, without descending any
+// further into it.
+func (d projectDiscovery) find(root string) ([]string, error) {
+	dirs, _, err := d.scan(root)
+	return dirs, err
+}
+
+// scan finds the project directories for a root, also returning every
+// directory that was read to find them.
+func (d projectDiscovery) scan(root string) ([]string, []string, error) {
+	scanned := map[string]bool{}
+	for _, dir := range globDirs(root) {
+		scanned[dir] = true
+	}
+
+	var dirs []string
+	var err error
+	if d.depth > 0 {
+		dirs, err = d.walk(root, scanned)
+	} else {
+		dirs, err = d.glob(root)
+	}
+	if err != nil {
+		return dirs, nil, err
+	}
+
+	scannedDirs := []string{}
+	for dir := range scanned {
+		scannedDirs = append(scannedDirs, dir)
+	}
+	sort.Strings(scannedDirs)
+
+	return dirs, scannedDirs, nil
+}
+
+func (d projectDiscovery) glob(root string) ([]string, error) {
+	entries, err := findProjectDirs(root)
+	if err != nil {
+		return entries, err
+	}
+
+	dirs := []string{}
+	for _, dir := range entries {
+		if !d.ignored(filepath.Base(dir)) {
+			dirs = append(dirs, dir)
+		}
+	}
+	return dirs, nil
+}
+
+func (d projectDiscovery) walk(root string, scanned map[string]bool) ([]string, error) {
+	roots, err := filepath.Glob(root)
+	if err != nil {
+		return []string{}, err
+	}
+
+	// if the glob didn't find anything, make sure it's targeting a valid existing
+	// directory:
+	if len(roots) == 0 {
+		if _, err = os.ReadDir(root); err != nil {
+			return []string{}, err
+		}
+	}
+
+	dirs := []string{}
+	seen := map[string]bool{}
+	for _, r := range roots {
+		dirs = d.walkDir(r, 1, seen, scanned, dirs)
+	}
+	return dirs, nil
+}
+
+// walkDir appends the projects found in dir to dirs, descending into
+// non-project directories until the maximum depth is reached. Directories are
+// tracked by their resolved path so symlink loops are only visited once.
+func (d projectDiscovery) walkDir(dir string, depth int, seen, scanned map[string]bool, dirs []string) []string {
+	resolved, err := filepath.EvalSymlinks(dir)
+	if err != nil || seen[resolved] {
+		return dirs
+	}
+	seen[resolved] = true
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return dirs
+	}
+	scanned[dir] = true
+
+	for _, entry := range entries {
+		name := entry.Name()
+		if strings.HasPrefix(name, ".") || d.ignored(name) {
+			continue
+		}
+
+		path := filepath.Join(dir, name)
+		if info, err := os.Stat(path); err != nil || !info.IsDir() {
+			continue
+		}
+
+		switch {
+		case d.isProject(path):
+			dirs = append(dirs, path)
+		case depth < d.depth:
+			dirs = d.walkDir(path, depth+1, seen, scanned, dirs)
+		default:
+			// not read, but it'll need rescanning if it becomes a project
+			scanned[path] = true
+		}
+	}
+
+	return dirs
+}
+
+func (d projectDiscovery) isProject(dir string) bool {
+	for _, marker := range d.markers {
+		if _, err := os.Stat(filepath.Join(dir, marker)); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+func (d projectDiscovery) ignored(name string) bool {
+	for _, pattern := range d.ignore {
+		if matched, _ := filepath.Match(pattern, name); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// String identifies the discovery settings, so results found with different
+// settings can be told apart.
+func (d projectDiscovery) String() string {
+	return fmt.Sprintf("depth=%d markers=%s ignore=%s",
+		d.depth, strings.Join(d.markers, ","), strings.Join(d.ignore, ","))
+}
+
+// globDirs returns the directories matching a pattern, along with every
+// directory that must be read to expand the pattern's wildcards.
+func globDirs(pattern string) []string {
+	matches, _ := filepath.Glob(pattern)
+	if !strings.ContainsAny(pattern, `*?[\`) {
+		return matches
+	}
+	return append(matches, globDirs(filepath.Dir(pattern))...)
+}
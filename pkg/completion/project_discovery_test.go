@@ -0,0 +1,113 @@
+package completion
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zerowidth/gh-shorthand/pkg/config"
+)
+
+// makeProjectTree creates a directory tree in a temp dir, creating each path
+// as an empty file, or a directory if it ends with a /.
+func makeProjectTree(t *testing.T, paths ...string) string {
+	root := t.TempDir()
+	for _, p := range paths {
+		full := filepath.Join(root, p)
+		if p[len(p)-1] == '/' {
+			require.NoError(t, os.MkdirAll(full, 0755))
+			continue
+		}
+		require.NoError(t, os.MkdirAll(filepath.Dir(full), 0755))
+		require.NoError(t, os.WriteFile(full, nil, 0644))
+	}
+	return root
+}
+
+func TestProjectDiscoveryWalk(t *testing.T) {
+	root := makeProjectTree(t,
+		"dotfiles/.git/",
+		"dotfiles/nested/go.mod",
+		"group/server/package.json",
+		"group/deep/deeper/client/go.mod",
+		"node_modules/dep/package.json",
+		"plain/",
+		".hidden/project/go.mod",
+	)
+	require.NoError(t, os.Symlink(root, filepath.Join(root, "group", "loop")))
+	require.NoError(t, os.Symlink(filepath.Join(root, "group", "server"), filepath.Join(root, "linked")))
+
+	d := newProjectDiscovery(config.Config{
+		ProjectDepth:  3,
+		ProjectIgnore: []string{"node_modules"},
+	})
+	dirs, err := d.find(root)
+	require.NoError(t, err)
+
+	assert.Contains(t, dirs, filepath.Join(root, "dotfiles"), "project with a .git directory")
+	assert.Contains(t, dirs, filepath.Join(root, "group/server"), "nested project")
+	assert.Contains(t, dirs, filepath.Join(root, "linked"), "symlinked project")
+	assert.NotContains(t, dirs, filepath.Join(root, "dotfiles/nested"), "project inside a project")
+	assert.NotContains(t, dirs, filepath.Join(root, "group/deep/deeper/client"), "project beyond max depth")
+	assert.NotContains(t, dirs, filepath.Join(root, "node_modules/dep"), "ignored directory")
+	assert.NotContains(t, dirs, filepath.Join(root, "plain"), "directory without a marker")
+	assert.NotContains(t, dirs, filepath.Join(root, ".hidden/project"), "hidden directory")
+	assert.Len(t, dirs, 3, "symlink loop is only followed once in\n%v", dirs)
+}
+
+func TestProjectDiscoveryWalkDepth(t *testing.T) {
+	root := makeProjectTree(t,
+		"a/go.mod",
+		"b/c/go.mod",
+		"b/c/d/e/go.mod",
+	)
+
+	dirs, err := newProjectDiscovery(config.Config{ProjectDepth: 1}).find(root)
+	require.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(root, "a")}, dirs)
+
+	dirs, err = newProjectDiscovery(config.Config{ProjectDepth: 2}).find(root)
+	require.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(root, "a"), filepath.Join(root, "b/c")}, dirs)
+}
+
+func TestProjectDiscoveryWalkMarkers(t *testing.T) {
+	root := makeProjectTree(t,
+		"a/go.mod",
+		"b/Makefile",
+	)
+
+	dirs, err := newProjectDiscovery(config.Config{
+		ProjectDepth:   1,
+		ProjectMarkers: []string{"Makefile"},
+	}).find(root)
+	require.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(root, "b")}, dirs)
+}
+
+func TestProjectDiscoveryWalkGlob(t *testing.T) {
+	root := makeProjectTree(t,
+		"src/a/foo/go.mod",
+		"src/b/bar/go.mod",
+	)
+
+	dirs, err := newProjectDiscovery(config.Config{ProjectDepth: 1}).find(root + "/src/*")
+	require.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(root, "src/a/foo"), filepath.Join(root, "src/b/bar")}, dirs)
+}
+
+func TestProjectDiscoveryWalkInvalid(t *testing.T) {
+	_, err := newProjectDiscovery(config.Config{ProjectDepth: 2}).find("testdata/invalid")
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "no such file")
+	}
+}
+
+func TestProjectDiscoveryIgnoreWithoutDepth(t *testing.T) {
+	dirs, err := newProjectDiscovery(config.Config{ProjectIgnore: []string{"project-*"}}).find("testdata/projects")
+	require.NoError(t, err)
+	assert.Contains(t, dirs, "testdata/projects/linked")
+	assert.NotContains(t, dirs, "testdata/projects/project-bar")
+}
@@ -0,0 +1,188 @@
+package completion
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/zerowidth/gh-shorthand/pkg/alfred"
+	"github.com/zerowidth/gh-shorthand/pkg/config"
+	"github.com/zerowidth/gh-shorthand/pkg/gitrepo"
+)
+
+const (
+	// gitDetailLimit is how many project directory items get git details. Reading
+	// git metadata for hundreds of projects on every keystroke is too slow, and
+	// only the first few results are visible anyway.
+	gitDetailLimit = 10
+
+	// gitStatusBudget bounds the total time spent counting commits and looking
+	// for uncommitted changes, so a few huge checkouts can't stall completion.
+	gitStatusBudget = 50 * time.Millisecond
+
+	// gitCountsFile is the name of the ahead/behind cache in the cache directory
+	gitCountsFile = "git-counts.json"
+
+	// maxGitCounts limits how many ahead/behind counts are kept in the cache
+	maxGitCounts = 1000
+)
+
+// gitCounts caches ahead/behind counts for pairs of commits.
+//
+// Counting means walking history, but the counts for a given pair of commits
+// never change, so they only need to be counted once.
+type gitCounts struct {
+	Counts map[string][2]int `json:"counts"`
+
+	path    string
+	used    map[string]bool
+	changed bool
+}
+
+func loadGitCounts(cfg config.Config) *gitCounts {
+	gc := &gitCounts{
+		Counts: map[string][2]int{},
+		used:   map[string]bool{},
+	}
+
+	path, err := cfg.CachePath(gitCountsFile)
+	if err != nil {
+		return gc
+	}
+	gc.path = path
+
+	if data, err := os.ReadFile(path); err == nil {
+		_ = json.Unmarshal(data, gc)
+		if gc.Counts == nil {
+			gc.Counts = map[string][2]int{}
+		}
+	}
+	return gc
+}
+
+func (gc *gitCounts) aheadBehind(repo *gitrepo.Repo, deadline time.Time) (int, int, bool) {
+	if repo.UpstreamHead == "" {
+		return 0, 0, false
+	}
+
+	key := repo.Head + "..." + repo.UpstreamHead
+	gc.used[key] = true
+	if counts, ok := gc.Counts[key]; ok {
+		return counts[0], counts[1], true
+	}
+
+	ahead, behind, err := repo.AheadBehind(deadline)
+	if err != nil {
+		return 0, 0, false
+	}
+	gc.Counts[key] = [2]int{ahead, behind}
+	gc.changed = true
+	return ahead, behind, true
+}
+
+func (gc *gitCounts) save() error {
+	if !gc.changed || gc.path == "" {
+		return nil
+	}
+
+	// rather than tracking age, drop everything not used this time once the
+	// cache gets too big
+	if len(gc.Counts) > maxGitCounts {
+		for key := range gc.Counts {
+			if !gc.used[key] {
+				delete(gc.Counts, key)
+			}
+		}
+	}
+
+	data, err := json.Marshal(gc)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(gc.path), 0755); err != nil {
+		return err
+	}
+	tmp := fmt.Sprintf("%s.%d", gc.path, os.Getpid())
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, gc.path)
+}
+
+// addGitDetails annotates a project directory item with the checkout's
+// branch, remote, and status, and adds mods to open it on GitHub.
+func addGitDetails(item *alfred.Item, dir string, counts *gitCounts, deadline time.Time) {
+	repo, err := gitrepo.Open(dir)
+	if err != nil {
+		return
+	}
+
+	var details []string
+	ghRepo, onGitHub := repo.GitHubRepo()
+
+	location := ""
+	if onGitHub {
+		location = ghRepo + " "
+	}
+	if repo.Branch != "" {
+		details = append(details, location+"on "+repo.Branch)
+	} else if len(repo.Head) >= 7 {
+		details = append(details, location+"detached at "+repo.Head[0:7])
+	} else if onGitHub {
+		details = append(details, ghRepo)
+	}
+
+	if ahead, behind, ok := counts.aheadBehind(repo, deadline); ok {
+		if ahead > 0 {
+			details = append(details, fmt.Sprintf("%d ahead", ahead))
+		}
+		if behind > 0 {
+			details = append(details, fmt.Sprintf("%d behind", behind))
+		}
+	}
+
+	if uncommitted, err := repo.Uncommitted(deadline); err == nil && uncommitted {
+		details = append(details, "uncommitted changes")
+	}
+
+	if len(details) > 0 {
+		item.Subtitle += " (" + strings.Join(details, ", ") + ")"
+	}
+
+	if !onGitHub {
+		return
+	}
+
+	repoURL := "https://github.com/" + ghRepo
+
+	item.Mods.Ctrl = &alfred.ModItem{
+		Valid:     true,
+		Arg:       repoURL,
+		Subtitle:  "Open " + ghRepo + " on GitHub",
+		Icon:      repoIcon,
+		Variables: alfred.Variables{"action": "open"},
+	}
+	if repo.Branch != "" && !repo.IsDefaultBranch() {
+		item.Mods.Ctrl.Arg = repoURL + "/tree/" + repo.Branch
+		item.Mods.Ctrl.Subtitle = "Open " + ghRepo + " on GitHub at " + repo.Branch
+
+		item.Mods.Shift = &alfred.ModItem{
+			Valid:     true,
+			Arg:       repoURL + "/pulls?q=" + url.QueryEscape("is:pr head:"+repo.Branch),
+			Subtitle:  "Open pull requests for " + repo.Branch + " in " + ghRepo,
+			Icon:      pullRequestIcon,
+			Variables: alfred.Variables{"action": "open"},
+		}
+		item.Mods.Fn = &alfred.ModItem{
+			Valid:     true,
+			Arg:       repoURL + "/compare/" + repo.Branch,
+			Subtitle:  "Compare " + repo.Branch + " in " + ghRepo,
+			Icon:      pathIcon,
+			Variables: alfred.Variables{"action": "open"},
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package completion
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zerowidth/gh-shorthand/pkg/alfred"
+	"github.com/zerowidth/gh-shorthand/pkg/config"
+)
+
+// git runs a git command in dir, failing the test if it fails
+func git(t *testing.T, dir string, args ...string) {
+	t.Helper()
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(),
+		"GIT_CONFIG_NOSYSTEM=1",
+		"HOME="+dir,
+		"GIT_AUTHOR_NAME=test", "GIT_AUTHOR_EMAIL=test@example.com",
+		"GIT_COMMITTER_NAME=test", "GIT_COMMITTER_EMAIL=test@example.com",
+	)
+	out, err := cmd.CombinedOutput()
+	require.NoError(t, err, "git %v: %s", args, out)
+}
+
+// newCheckout creates a checkout of a local bare repository in root/name,
+// with a GitHub URL for its origin remote.
+func newCheckout(t *testing.T, root, name string) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+
+	origin := filepath.Join(t.TempDir(), name+".git")
+	git(t, root, "init", "-q", "--bare", "-b", "main", origin)
+
+	dir := filepath.Join(root, name)
+	git(t, root, "clone", "-q", origin, dir)
+	git(t, dir, "checkout", "-q", "-b", "main")
+	git(t, dir, "commit", "-q", "--allow-empty", "-m", "initial")
+	git(t, dir, "push", "-q", "-u", "origin", "main")
+	git(t, dir, "remote", "set-head", "origin", "main")
+	git(t, dir, "remote", "set-url", "origin", "git@github.com:zerowidth/"+name+".git")
+	return dir
+}
+
+func TestProjectDirItemsGitDetails(t *testing.T) {
+	root := t.TempDir()
+	dir := newCheckout(t, root, "foo")
+	cfg := config.Config{
+		ProjectDirs: []string{root},
+		CacheDir:    t.TempDir(),
+	}
+
+	items := projectDirItems(cfg, "", modeEdit)
+	require.Len(t, items, 1)
+	item := items[0]
+	assert.Equal(t, "Edit "+dir+" (zerowidth/foo on main)", item.Subtitle)
+	if assert.NotNil(t, item.Mods.Ctrl) {
+		assert.Equal(t, "https://github.com/zerowidth/foo", item.Mods.Ctrl.Arg)
+		assert.Equal(t, "open", item.Mods.Ctrl.Variables["action"])
+	}
+	assert.Nil(t, item.Mods.Shift, "no pull request mod on the default branch")
+	assert.Nil(t, item.Mods.Fn, "no compare mod on the default branch")
+
+	git(t, dir, "checkout", "-q", "-b", "feature/thing", "--track", "origin/main")
+	git(t, dir, "commit", "-q", "--allow-empty", "-m", "feature")
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "file"), nil, 0644))
+	git(t, dir, "add", "file")
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "file"), []byte("changed"), 0644))
+
+	items = projectDirItems(cfg, "", modeEdit)
+	require.Len(t, items, 1)
+	item = items[0]
+	assert.Equal(t, "Edit "+dir+" (zerowidth/foo on feature/thing, 1 ahead, uncommitted changes)", item.Subtitle)
+	if assert.NotNil(t, item.Mods.Ctrl) {
+		assert.Equal(t, "https://github.com/zerowidth/foo/tree/feature/thing", item.Mods.Ctrl.Arg)
+	}
+	if assert.NotNil(t, item.Mods.Shift) {
+		assert.Equal(t, "https://github.com/zerowidth/foo/pulls?q=is%3Apr+head%3Afeature%2Fthing", item.Mods.Shift.Arg)
+	}
+	if assert.NotNil(t, item.Mods.Fn) {
+		assert.Equal(t, "https://github.com/zerowidth/foo/compare/feature/thing", item.Mods.Fn.Arg)
+	}
+}
+
+func TestProjectDirItemsWithoutGit(t *testing.T) {
+	root := makeProjectTree(t, "plain/")
+	cfg := config.Config{
+		ProjectDirs: []string{root},
+		CacheDir:    t.TempDir(),
+	}
+
+	items := projectDirItems(cfg, "", modeTerm)
+	require.Len(t, items, 1)
+	assert.Equal(t, "Open terminal in "+filepath.Join(root, "plain"), items[0].Subtitle)
+	assert.Nil(t, items[0].Mods.Ctrl)
+}
+
+func TestGitCountsCache(t *testing.T) {
+	root := t.TempDir()
+	dir := newCheckout(t, root, "foo")
+	git(t, dir, "commit", "-q", "--allow-empty", "-m", "ahead")
+	cfg := config.Config{CacheDir: t.TempDir()}
+
+	counts := loadGitCounts(cfg)
+	item := alfred.Item{Mods: &alfred.Mods{}}
+	addGitDetails(&item, dir, counts, time.Now().Add(time.Second))
+	assert.Contains(t, item.Subtitle, "1 ahead")
+	require.NoError(t, counts.save())
+
+	cached := loadGitCounts(cfg)
+	assert.Len(t, cached.Counts, 1)
+}
@@ -0,0 +1,158 @@
+package completion
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	homedir "github.com/mitchellh/go-homedir"
+	"github.com/zerowidth/gh-shorthand/pkg/config"
+)
+
+// projectIndexFile is the name of the project index in the cache directory
+const projectIndexFile = "projects.json"
+
+// projectIndex is a persisted listing of the project directories found under
+// each project root.
+//
+// Along with the projects, each root records the modification time of every
+// directory read while finding them. Adding or removing a directory changes
+// its parent's mtime, so an unchanged set of mtimes means the listing is still
+// accurate, and checking that is far cheaper than scanning the root again.
+type projectIndex struct {
+	Roots map[string]indexedRoot `json:"roots"`
+
+	path    string // where the index is stored
+	changed bool   // whether any roots were rescanned
+}
+
+type indexedRoot struct {
+	Discovery string               `json:"discovery"` // the discovery settings used
+	Dirs      []string             `json:"dirs"`      // project directories found
+	Scanned   map[string]time.Time `json:"scanned"`   // directories read, and their mtimes
+}
+
+// loadProjectIndex reads the project index from the cache directory. Returns
+// nil if there's no index, so callers fall back to scanning the project roots.
+func loadProjectIndex(cfg config.Config) *projectIndex {
+	path, err := cfg.CachePath(projectIndexFile)
+	if err != nil {
+		return nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+
+	index := &projectIndex{path: path}
+	if err := json.Unmarshal(data, index); err != nil || index.Roots == nil {
+		return nil
+	}
+	return index
+}
+
+// find returns the project directories for a root, rescanning it if the
+// index is missing or out of date.
+func (idx *projectIndex) find(d projectDiscovery, root string) ([]string, error) {
+	if idx == nil {
+		return d.find(root)
+	}
+
+	if entry, ok := idx.Roots[root]; ok && entry.fresh(d) {
+		return entry.Dirs, nil
+	}
+
+	idx.changed = true
+	dirs, scanned, err := d.scan(root)
+	if err != nil {
+		delete(idx.Roots, root)
+		return dirs, err
+	}
+
+	entry := indexedRoot{
+		Discovery: d.String(),
+		Dirs:      dirs,
+		Scanned:   map[string]time.Time{},
+	}
+	for _, dir := range scanned {
+		if info, err := os.Stat(dir); err == nil {
+			entry.Scanned[dir] = info.ModTime()
+		}
+	}
+	idx.Roots[root] = entry
+
+	return dirs, nil
+}
+
+// save writes the index back to the cache directory if anything changed.
+func (idx *projectIndex) save() error {
+	if idx == nil || !idx.changed {
+		return nil
+	}
+
+	data, err := json.Marshal(idx)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(idx.path), 0755); err != nil {
+		return err
+	}
+
+	// write to a temp file and rename it, so concurrent completions never see a
+	// partially written index
+	tmp := fmt.Sprintf("%s.%d", idx.path, os.Getpid())
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, idx.path)
+}
+
+func (entry indexedRoot) fresh(d projectDiscovery) bool {
+	if entry.Discovery != d.String() {
+		return false
+	}
+	for dir, mtime := range entry.Scanned {
+		info, err := os.Stat(dir)
+		if err != nil || !info.ModTime().Equal(mtime) {
+			return false
+		}
+	}
+	return true
+}
+
+// ReindexProjects rebuilds the project index from scratch, returning how many
+// project directories were found.
+//
+// Once an index exists, project directory completion uses it instead of
+// scanning every project root, and keeps it up to date as roots change.
+func ReindexProjects(cfg config.Config) (int, error) {
+	path, err := cfg.CachePath(projectIndexFile)
+	if err != nil {
+		return 0, err
+	}
+
+	index := &projectIndex{
+		Roots: map[string]indexedRoot{},
+		path:  path,
+	}
+
+	count := 0
+	discovery := newProjectDiscovery(cfg)
+	for _, searchPath := range cfg.ProjectDirs {
+		root, err := homedir.Expand(searchPath)
+		if err != nil {
+			return 0, fmt.Errorf("invalid project directory %s: %w", searchPath, err)
+		}
+		dirs, err := index.find(discovery, root)
+		if err != nil {
+			return 0, fmt.Errorf("invalid project directory %s: %w", searchPath, err)
+		}
+		count += len(dirs)
+	}
+
+	index.changed = true
+	return count, index.save()
+}
@@ -0,0 +1,131 @@
+package completion
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zerowidth/gh-shorthand/pkg/config"
+)
+
+func TestProjectIndexMissing(t *testing.T) {
+	cfg := config.Config{
+		ProjectDirs: []string{"testdata/projects"},
+		CacheDir:    t.TempDir(),
+	}
+	assert.Nil(t, loadProjectIndex(cfg))
+
+	items := projectDirItems(cfg, "", modeEdit)
+	assert.Len(t, items, 2, "falls back to scanning projects")
+
+	assert.Nil(t, loadProjectIndex(cfg), "index isn't created implicitly")
+}
+
+func TestReindexProjects(t *testing.T) {
+	root := makeProjectTree(t, "foo/", "bar/")
+	cfg := config.Config{
+		ProjectDirs: []string{root},
+		CacheDir:    t.TempDir(),
+	}
+
+	count, err := ReindexProjects(cfg)
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+
+	index := loadProjectIndex(cfg)
+	require.NotNil(t, index)
+	assert.ElementsMatch(t,
+		[]string{filepath.Join(root, "foo"), filepath.Join(root, "bar")},
+		index.Roots[root].Dirs)
+}
+
+func TestReindexProjectsInvalid(t *testing.T) {
+	cfg := config.Config{
+		ProjectDirs: []string{"testdata/nonexistent"},
+		CacheDir:    t.TempDir(),
+	}
+	_, err := ReindexProjects(cfg)
+	assert.Error(t, err)
+}
+
+func TestProjectIndexUsed(t *testing.T) {
+	root := makeProjectTree(t, "foo/")
+	cfg := config.Config{
+		ProjectDirs: []string{root},
+		CacheDir:    t.TempDir(),
+	}
+	_, err := ReindexProjects(cfg)
+	require.NoError(t, err)
+
+	// tamper with the index without touching the root's mtime, to show the
+	// listing comes from the index rather than a scan
+	index := loadProjectIndex(cfg)
+	entry := index.Roots[root]
+	entry.Dirs = append(entry.Dirs, filepath.Join(root, "indexed-only"))
+	index.Roots[root] = entry
+	index.changed = true
+	require.NoError(t, index.save())
+
+	items := projectDirItems(cfg, "", modeEdit)
+	assert.Len(t, items, 2)
+}
+
+func TestProjectIndexInvalidation(t *testing.T) {
+	root := makeProjectTree(t, "foo/")
+	cfg := config.Config{
+		ProjectDirs: []string{root},
+		CacheDir:    t.TempDir(),
+	}
+	_, err := ReindexProjects(cfg)
+	require.NoError(t, err)
+
+	require.NoError(t, os.Mkdir(filepath.Join(root, "bar"), 0755))
+	// make sure the mtime changes even on filesystems with coarse timestamps
+	later := time.Now().Add(time.Minute)
+	require.NoError(t, os.Chtimes(root, later, later))
+
+	items := projectDirItems(cfg, "", modeEdit)
+	assert.Len(t, items, 2, "new project directory is found")
+
+	index := loadProjectIndex(cfg)
+	require.NotNil(t, index)
+	assert.Len(t, index.Roots[root].Dirs, 2, "index is updated")
+}
+
+func TestProjectIndexDiscoveryChange(t *testing.T) {
+	root := makeProjectTree(t, "foo/go.mod", "bar/")
+	cfg := config.Config{
+		ProjectDirs: []string{root},
+		CacheDir:    t.TempDir(),
+	}
+	_, err := ReindexProjects(cfg)
+	require.NoError(t, err)
+
+	cfg.ProjectDepth = 1
+	items := projectDirItems(cfg, "", modeEdit)
+	assert.Len(t, items, 1, "changed discovery settings rescan the root")
+}
+
+func TestProjectIndexWalkInvalidation(t *testing.T) {
+	root := makeProjectTree(t, "group/foo/go.mod", "group/plain/")
+	cfg := config.Config{
+		ProjectDirs:  []string{root},
+		ProjectDepth: 2,
+		CacheDir:     t.TempDir(),
+	}
+	count, err := ReindexProjects(cfg)
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+
+	// a directory at the maximum depth becomes a project
+	plain := filepath.Join(root, "group", "plain")
+	require.NoError(t, os.WriteFile(filepath.Join(plain, "go.mod"), nil, 0644))
+	later := time.Now().Add(time.Minute)
+	require.NoError(t, os.Chtimes(plain, later, later))
+
+	items := projectDirItems(cfg, "", modeEdit)
+	assert.Len(t, items, 2)
+}
@@ -0,0 +1,196 @@
+package completion
+
+import (
+	"os"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Scoring weights for matching a search against a project path. Every matched
+// character scores something, with bonuses for matches that a person typing an
+// abbreviation is likely to mean: runs of consecutive characters, the start of
+// a path segment or word, and anything in the directory's own name rather than
+// its parent directories.
+const (
+	scoreMatch        = 1
+	scoreConsecutive  = 4
+	scoreSegmentStart = 8
+	scoreWordStart    = 5
+	scoreBasename     = 2
+	scoreExact        = 20 // the search is exactly the directory's name
+)
+
+type projectMatch struct {
+	short string
+	score int
+}
+
+// rankProjects filters project directories to those matching the search, and
+// orders them from best to worst match. Ties go to the most recently modified
+// directory.
+//
+// A search containing a / is split into parts that must each match within a
+// separate path segment, in order: `w/s` matches `~/work/projects/server`.
+// Otherwise the search can match across segments, so `wps` matches it too.
+func rankProjects(search string, projects []string, paths map[string]string) []string {
+	var matches []projectMatch
+	for _, short := range projects {
+		if score, ok := scoreProject(search, short); ok {
+			matches = append(matches, projectMatch{short: short, score: score})
+		}
+	}
+
+	mtimes := map[string]time.Time{}
+	mtime := func(short string) time.Time {
+		if t, ok := mtimes[short]; ok {
+			return t
+		}
+		var t time.Time
+		if info, err := os.Stat(paths[short]); err == nil {
+			t = info.ModTime()
+		}
+		mtimes[short] = t
+		return t
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		a, b := matches[i], matches[j]
+		if a.score != b.score {
+			return a.score > b.score
+		}
+		if ta, tb := mtime(a.short), mtime(b.short); !ta.Equal(tb) {
+			return ta.After(tb)
+		}
+		if len(a.short) != len(b.short) {
+			return len(a.short) < len(b.short)
+		}
+		return a.short < b.short
+	})
+
+	ranked := make([]string, len(matches))
+	for i, m := range matches {
+		ranked[i] = m.short
+	}
+	return ranked
+}
+
+// scoreProject scores a search against a shortened project path, returning
+// false if it doesn't match.
+func scoreProject(search, path string) (int, bool) {
+	search = strings.ToLower(search)
+	path = strings.TrimPrefix(path, "~/")
+	base := path[strings.LastIndex(path, "/")+1:]
+
+	parts := strings.Split(strings.Trim(search, "/"), "/")
+	exact := 0
+	if strings.EqualFold(parts[len(parts)-1], base) {
+		exact = scoreExact
+	}
+
+	if len(parts) == 1 {
+		baseStart := utf8.RuneCountInString(path) - utf8.RuneCountInString(base)
+		score := fuzzyScore([]rune(parts[0]), []rune(path), baseStart)
+		return score + exact, score >= 0
+	}
+
+	// match each part against a single segment, with segments in order. best
+	// holds the best total score for the parts so far, ending at each segment.
+	segments := strings.Split(path, "/")
+	best := make([]int, len(segments))
+	for p, part := range parts {
+		next := make([]int, len(segments))
+		prevBest := -1
+		for s, segment := range segments {
+			next[s] = -1
+			if p > 0 && s > 0 && best[s-1] > prevBest {
+				prevBest = best[s-1]
+			}
+			if p == 0 || prevBest >= 0 {
+				baseStart := utf8.RuneCountInString(segment) // not in the basename
+				if s == len(segments)-1 {
+					baseStart = 0
+				}
+				if score := fuzzyScore([]rune(part), []rune(segment), baseStart); score >= 0 {
+					if p > 0 {
+						score += prevBest
+					}
+					next[s] = score
+				}
+			}
+		}
+		best = next
+	}
+
+	score := -1
+	for _, s := range best {
+		if s > score {
+			score = s
+		}
+	}
+	return score + exact, score >= 0
+}
+
+// fuzzyScore finds the best scoring way to match query as a subsequence of
+// target, returning -1 if it can't be matched. Characters from baseStart
+// onward are in the directory's name.
+func fuzzyScore(query, target []rune, baseStart int) int {
+	if len(query) == 0 {
+		return 0
+	}
+
+	// prev[j] is the best score for the query so far, with its last character
+	// matched at target[j], or -1 if that's impossible.
+	prev := make([]int, len(target))
+	cur := make([]int, len(target))
+	for i, q := range query {
+		bestBefore := -1 // best prev[k] for k < j-1
+		for j, t := range target {
+			if j >= 2 && prev[j-2] > bestBefore {
+				bestBefore = prev[j-2]
+			}
+			cur[j] = -1
+			if unicode.ToLower(t) != q {
+				continue
+			}
+
+			score := scoreMatch + positionBonus(target, j, baseStart)
+			switch {
+			case i == 0:
+				cur[j] = score
+			case j > 0 && prev[j-1] >= 0 && prev[j-1]+scoreConsecutive >= bestBefore:
+				cur[j] = score + prev[j-1] + scoreConsecutive
+			case bestBefore >= 0:
+				cur[j] = score + bestBefore
+			}
+		}
+		prev, cur = cur, prev
+	}
+
+	best := -1
+	for _, score := range prev {
+		if score > best {
+			best = score
+		}
+	}
+	return best
+}
+
+func positionBonus(target []rune, j, baseStart int) int {
+	bonus := 0
+	if j >= baseStart {
+		bonus += scoreBasename
+	}
+
+	switch {
+	case j == 0 || target[j-1] == '/':
+		bonus += scoreSegmentStart
+	case strings.ContainsRune("-_. ", target[j-1]),
+		unicode.IsLower(target[j-1]) && unicode.IsUpper(target[j]):
+		bonus += scoreWordStart
+	}
+
+	return bonus
+}
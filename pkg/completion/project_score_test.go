@@ -0,0 +1,108 @@
+package completion
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zerowidth/gh-shorthand/pkg/config"
+)
+
+var rankingCfg = config.Config{
+	ProjectDirs: []string{
+		"testdata-ranking/code",
+		"testdata-ranking/work/projects",
+		"testdata-ranking/go/src/*/*",
+	},
+}
+
+func TestRankProjects(t *testing.T) {
+	for _, tc := range []struct {
+		search string
+		first  string   // the expected best match
+		order  []string // if given, the full expected ranking
+	}{
+		{search: "cdf", first: "testdata-ranking/code/dotfiles"},
+		{search: "wc", first: "testdata-ranking/work/projects/client"},
+		{search: "w/s", first: "testdata-ranking/work/projects/server"},
+		{search: "wps", first: "testdata-ranking/work/projects/server"},
+		{search: "demo", first: "testdata-ranking/code/demo"},
+		{search: "sync", first: "testdata-ranking/go/src/golang.org/x/sync"},
+		{search: "ghs", first: "testdata-ranking/go/src/github.com/zerowidth/gh-shorthand"},
+		{search: "zw/gh", first: "testdata-ranking/go/src/github.com/zerowidth/gh-shorthand"},
+		{search: "x/s", first: "testdata-ranking/go/src/golang.org/x/sync"},
+		{
+			search: "server",
+			order: []string{
+				"testdata-ranking/work/projects/server",
+				"testdata-ranking/go/src/github.com/someone/observer",
+			},
+		},
+		{
+			search: "s/server",
+			order: []string{
+				"testdata-ranking/work/projects/server",
+				"testdata-ranking/go/src/github.com/someone/observer",
+			},
+		},
+		{search: "zzz", order: []string{}},
+		{search: "server/work", order: []string{}},
+	} {
+		t.Run(tc.search, func(t *testing.T) {
+			items := projectDirItems(rankingCfg, tc.search, modeEdit)
+			titles := []string{}
+			for _, item := range items {
+				titles = append(titles, item.Title)
+			}
+
+			if tc.order != nil {
+				assert.Equal(t, tc.order, titles)
+			} else if assert.NotEmpty(t, titles) {
+				assert.Equal(t, tc.first, titles[0], "best match in\n%v", titles)
+			}
+		})
+	}
+}
+
+func TestRankProjectsByModificationTime(t *testing.T) {
+	root := makeProjectTree(t, "dotfiles/", "demo/")
+	paths := map[string]string{
+		"dotfiles": filepath.Join(root, "dotfiles"),
+		"demo":     filepath.Join(root, "demo"),
+	}
+
+	older := time.Now().Add(-time.Hour)
+	require.NoError(t, os.Chtimes(paths["dotfiles"], older, older))
+	assert.Equal(t, []string{"demo", "dotfiles"}, rankProjects("d", []string{"dotfiles", "demo"}, paths))
+
+	newer := time.Now().Add(time.Hour)
+	require.NoError(t, os.Chtimes(paths["dotfiles"], newer, newer))
+	assert.Equal(t, []string{"dotfiles", "demo"}, rankProjects("d", []string{"dotfiles", "demo"}, paths))
+}
+
+func TestScoreProject(t *testing.T) {
+	for _, tc := range []struct {
+		search, better, worse string
+	}{
+		{"serv", "~/code/server", "~/code/sxexrxv"},               // consecutive
+		{"bar", "~/code/bar", "~/bar/code"},                       // basename
+		{"ghs", "~/code/gh-shorthand", "~/code/ghosts"},           // word starts
+		{"fb", "~/code/FooBar", "~/code/foobar"},                  // camel case
+		{"api", "~/code/api", "~/code/api-client"},                // exact name
+		{"w/s", "~/work/server", "~/work/projects/misc/listener"}, // segment starts
+	} {
+		better, ok := scoreProject(tc.search, tc.better)
+		require.True(t, ok, "%q matches %q", tc.search, tc.better)
+		worse, ok := scoreProject(tc.search, tc.worse)
+		require.True(t, ok, "%q matches %q", tc.search, tc.worse)
+		assert.Greater(t, better, worse, "%q: %q scores better than %q", tc.search, tc.better, tc.worse)
+	}
+
+	_, ok := scoreProject("xyz", "~/code/foo")
+	assert.False(t, ok)
+	_, ok = scoreProject("foo/code", "~/code/foo")
+	assert.False(t, ok, "parts must match segments in order")
+}
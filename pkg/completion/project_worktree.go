@@ -0,0 +1,122 @@
+package completion
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	homedir "github.com/mitchellh/go-homedir"
+	"github.com/zerowidth/gh-shorthand/pkg/alfred"
+	"github.com/zerowidth/gh-shorthand/pkg/gitrepo"
+)
+
+// addWorktrees adds the linked worktrees of each git checkout to the list of
+// projects, directly after the checkout they belong to. Worktrees that were
+// already found in a project directory aren't listed twice.
+//
+// Returns the branch (or commit, if detached) checked out in each worktree,
+// keyed by its short path.
+func addWorktrees(projects []string, paths map[string]string) ([]string, map[string]string) {
+	branches := map[string]string{}
+	listed := map[string]string{}
+	for short, path := range paths {
+		listed[path] = short
+	}
+	home, _ := homedir.Dir()
+
+	var all []string
+	for _, short := range projects {
+		all = append(all, short)
+
+		// only main checkouts have a worktrees directory, so check for that
+		// before reading anything else
+		if _, err := os.Stat(filepath.Join(paths[short], ".git", "worktrees")); err != nil {
+			continue
+		}
+		repo, err := gitrepo.Open(paths[short])
+		if err != nil {
+			continue
+		}
+		worktrees, err := repo.Worktrees()
+		if err != nil {
+			continue
+		}
+
+		for _, wt := range worktrees {
+			label := wt.Branch
+			if label == "" && len(wt.Head) >= 7 {
+				label = wt.Head[0:7]
+			}
+
+			if existing, ok := listed[wt.Dir]; ok {
+				branches[existing] = label
+				continue
+			}
+
+			wtShort := wt.Dir
+			if home != "" && strings.HasPrefix(wt.Dir, home+string(filepath.Separator)) {
+				wtShort = "~" + strings.TrimPrefix(wt.Dir, home)
+			}
+			listed[wt.Dir] = wtShort
+			paths[wtShort] = wt.Dir
+			branches[wtShort] = label
+			all = append(all, wtShort)
+		}
+	}
+
+	return all, branches
+}
+
+// worktreePath is where a new worktree for a branch goes: alongside the main
+// checkout, so it's found in the same project directory.
+func worktreePath(dir, branch string) string {
+	return dir + "-" + strings.ReplaceAll(branch, "/", "-")
+}
+
+// addWorktreeMod replaces a project directory item's alt mod with one that
+// creates a worktree for a branch, if the project is a git checkout.
+func addWorktreeMod(item *alfred.Item, short, dir, branch string) {
+	if _, err := gitrepo.Open(dir); err != nil {
+		return
+	}
+	item.Mods.Alt = &alfred.ModItem{
+		Valid:     true,
+		Arg:       dir,
+		Subtitle:  "Create a worktree for " + branch + " at " + worktreePath(short, branch),
+		Icon:      repoIcon,
+		Variables: alfred.Variables{"action": "worktree", "branch": branch},
+	}
+}
+
+// AddWorktree creates a worktree for a branch of the git checkout in dir, and
+// returns its path. If the branch is already checked out in the checkout or one
+// of its worktrees, that path is returned instead.
+func AddWorktree(dir, branch string) (string, error) {
+	dir, err := homedir.Expand(dir)
+	if err != nil {
+		return "", err
+	}
+	repo, err := gitrepo.Open(dir)
+	if err != nil {
+		return "", err
+	}
+
+	if repo.Branch == branch {
+		return repo.Dir, nil
+	}
+	worktrees, err := repo.Worktrees()
+	if err != nil {
+		return "", err
+	}
+	for _, wt := range worktrees {
+		if wt.Branch == branch {
+			return wt.Dir, nil
+		}
+	}
+
+	path := worktreePath(dir, branch)
+	if err := repo.AddWorktree(path, branch); err != nil {
+		return "", err
+	}
+	return path, nil
+}
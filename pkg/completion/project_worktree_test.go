@@ -0,0 +1,82 @@
+package completion
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zerowidth/gh-shorthand/pkg/config"
+)
+
+func TestProjectDirItemsWorktrees(t *testing.T) {
+	root := t.TempDir()
+	elsewhere := t.TempDir()
+	dir := newCheckout(t, root, "foo")
+	git(t, dir, "worktree", "add", "-q", "-b", "feature", filepath.Join(elsewhere, "foo-feature"))
+	git(t, dir, "worktree", "add", "-q", "-b", "sibling", dir+"-sibling")
+
+	cfg := config.Config{
+		ProjectDirs: []string{root},
+		CacheDir:    t.TempDir(),
+	}
+
+	items := projectDirItems(cfg, "", modeEdit)
+	titles := []string{}
+	for _, item := range items {
+		titles = append(titles, item.Title)
+	}
+	assert.Equal(t, []string{
+		dir,
+		filepath.Join(elsewhere, "foo-feature") + " (feature)",
+		dir + "-sibling (sibling)",
+	}, titles, "worktrees listed after their checkout, without duplicates")
+
+	for _, item := range items {
+		assert.Equal(t, "finder", item.Mods.Alt.Variables["action"])
+	}
+}
+
+func TestProjectDirItemsWorktreeMod(t *testing.T) {
+	root := t.TempDir()
+	dir := newCheckout(t, root, "foo")
+	plain := makeProjectTree(t, "plain/")
+
+	cfg := config.Config{
+		ProjectDirs: []string{root, plain},
+		CacheDir:    t.TempDir(),
+	}
+
+	items := projectDirItems(cfg, "foo feature/thing", modeEdit)
+	require.Len(t, items, 1)
+	alt := items[0].Mods.Alt
+	require.NotNil(t, alt)
+	assert.Equal(t, dir, alt.Arg)
+	assert.Equal(t, "worktree", alt.Variables["action"])
+	assert.Equal(t, "feature/thing", alt.Variables["branch"])
+	assert.Equal(t, "Create a worktree for feature/thing at "+dir+"-feature-thing", alt.Subtitle)
+
+	items = projectDirItems(cfg, "plain feature", modeEdit)
+	require.Len(t, items, 1)
+	assert.Equal(t, "finder", items[0].Mods.Alt.Variables["action"], "not a git checkout")
+}
+
+func TestAddWorktree(t *testing.T) {
+	dir := newCheckout(t, t.TempDir(), "foo")
+
+	path, err := AddWorktree(dir, "feature/thing")
+	require.NoError(t, err)
+	assert.Equal(t, dir+"-feature-thing", path)
+	assert.FileExists(t, filepath.Join(path, ".git"))
+
+	path, err = AddWorktree(dir, "feature/thing")
+	require.NoError(t, err)
+	assert.Equal(t, dir+"-feature-thing", path, "existing worktree")
+
+	path, err = AddWorktree(dir, "main")
+	require.NoError(t, err)
+	assert.Equal(t, dir, path, "main checkout")
+
+	_, err = AddWorktree(t.TempDir(), "feature")
+	assert.Error(t, err, "not a git checkout")
+}
@@ -3,8 +3,13 @@ package config
 import (
 	"fmt"
 	"log"
+	"log/slog"
+	"net"
 	"os"
+	"path/filepath"
 	"strings"
+	"text/template"
+	"time"
 
 	homedir "github.com/mitchellh/go-homedir"
 
@@ -25,10 +30,59 @@ type Config struct {
 	APIToken   string `yaml:"api_token"`
 	SocketPath string `yaml:"socket_path"`
 
+	// ListenAddr is a TCP address for the RPC server to listen on as well as
+	// the socket, e.g. localhost:7331, for clients in containers or VMs.
+	ListenAddr string `yaml:"listen_addr"`
+	// ListenRemote allows ListenAddr to be something other than a loopback
+	// address, making the server reachable from other machines.
+	ListenRemote bool `yaml:"listen_remote"`
+	// ServerAddr is the TCP address of an RPC server to use instead of the
+	// socket, e.g. host.docker.internal:7331
+	ServerAddr string `yaml:"server_addr"`
+	// RPCSecret is shared by the RPC server and its clients over TCP
+	RPCSecret string `yaml:"rpc_secret"`
+
+	// CacheTTL and ErrorTTL are how long the RPC server caches results and
+	// errors, if not the defaults
+	CacheTTL time.Duration `yaml:"cache_ttl"`
+	ErrorTTL time.Duration `yaml:"error_ttl"`
+
+	// LogLevel is the RPC server's log level: debug, info (the default), warn
+	// or error. The server logs to LogFile if it's set, in LogFormat ("logfmt",
+	// the default, or "json"), rotating it after LogMaxSize megabytes.
+	LogLevel   string `yaml:"log_level"`
+	LogFormat  string `yaml:"log_format"`
+	LogFile    string `yaml:"log_file"`
+	LogMaxSize int    `yaml:"log_max_size"`
+
 	// project configs
-	ProjectDirs  []string `yaml:"project_dirs"`
-	Editor       string   `yaml:"editor"`
-	EditorScript string   `yaml:"editor_script"`
+	ProjectDirs    []string `yaml:"project_dirs"`
+	ProjectDepth   int      `yaml:"project_depth"`
+	ProjectMarkers []string `yaml:"project_markers"`
+	ProjectIgnore  []string `yaml:"project_ignore"`
+	Editor         string   `yaml:"editor"`
+	EditorScript   string   `yaml:"editor_script"`
+
+	// LinkFormats are extra formats to offer links to repos and issues in,
+	// e.g. "slack" or "html", alongside markdown
+	LinkFormats []string `yaml:"link_formats"`
+
+	// LinkTemplates are text/template templates for links to repos and issues,
+	// by name. "link", "link_description" and "reference" replace the defaults.
+	LinkTemplates map[string]string `yaml:"link_templates"`
+
+	// ChangelogSections group the PRs in a changelog by label, in order
+	ChangelogSections []ChangelogSection `yaml:"changelog_sections"`
+
+	// CloneProtocol is how repositories are cloned: "https" (the default) or "ssh"
+	CloneProtocol string `yaml:"clone_protocol"`
+
+	// CacheDir holds persisted data such as the project index
+	CacheDir string `yaml:"cache_dir"`
+
+	// Path and ModTime of the file the config was loaded from, if any
+	Path    string    `yaml:"-"`
+	ModTime time.Time `yaml:"-"`
 }
 
 func (c Config) OpenEditorScript() (string, error) {
@@ -44,8 +98,58 @@ func (c Config) OpenEditorScript() (string, error) {
 	return s, nil
 }
 
+// RPCEnabled is true if there's an RPC server to use: with an API token, the
+// local one, or another over TCP.
 func (c Config) RPCEnabled() bool {
-	return len(c.APIToken) > 0
+	return len(c.APIToken) > 0 || len(c.ServerAddr) > 0
+}
+
+// Level returns the configured log level, or info by default
+func (c Config) Level() slog.Level {
+	var level slog.Level
+	if len(c.LogLevel) > 0 {
+		_ = level.UnmarshalText([]byte(c.LogLevel)) // validated on load
+	}
+	return level
+}
+
+// LogPath returns the path to the log file, with ~ expanded
+func (c Config) LogPath() (string, error) {
+	return homedir.Expand(c.LogFile)
+}
+
+// CloneURL returns the URL to clone an owner/name GitHub repository from,
+// using the configured clone protocol.
+func (c Config) CloneURL(repo string) string {
+	if c.CloneProtocol == "ssh" {
+		return "git@github.com:" + repo + ".git"
+	}
+	return "https://github.com/" + repo + ".git"
+}
+
+// CachePath returns the path to a named file in the cache directory. Defaults
+// to a gh-shorthand directory in the user's cache directory.
+func (c Config) CachePath(name string) (string, error) {
+	dir := c.CacheDir
+	if dir == "" {
+		base, err := os.UserCacheDir()
+		if err != nil {
+			return "", err
+		}
+		dir = filepath.Join(base, "gh-shorthand")
+	}
+	dir, err := homedir.Expand(dir)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, name), nil
+}
+
+// ChangelogSection is a section of a changelog, for PRs with any of its labels.
+// A section without labels is for PRs that aren't in any other section.
+type ChangelogSection struct {
+	Title  string   `yaml:"title"`
+	Labels []string `yaml:"labels"`
 }
 
 // Load a Config from a yaml string.
@@ -71,6 +175,75 @@ func Load(yml string) (Config, error) {
 		}
 	}
 
+	for _, addr := range []struct{ key, value string }{
+		{"listen_addr", config.ListenAddr},
+		{"server_addr", config.ServerAddr},
+	} {
+		if len(addr.value) == 0 {
+			continue
+		}
+		host, _, err := net.SplitHostPort(addr.value)
+		if err != nil {
+			return config, fmt.Errorf("%s: %w", addr.key, err)
+		}
+		if len(config.RPCSecret) == 0 {
+			return config, fmt.Errorf("%s requires an rpc_secret", addr.key)
+		}
+		if addr.key == "listen_addr" && !config.ListenRemote && !isLoopback(host) {
+			return config, fmt.Errorf("listen_addr %s isn't a loopback address, set listen_remote to allow it", addr.value)
+		}
+	}
+
+	if config.CacheTTL < 0 {
+		return config, fmt.Errorf("cache ttl %s must not be negative", config.CacheTTL)
+	}
+	if config.ErrorTTL < 0 {
+		return config, fmt.Errorf("error ttl %s must not be negative", config.ErrorTTL)
+	}
+
+	if len(config.LogLevel) > 0 {
+		var level slog.Level
+		if err := level.UnmarshalText([]byte(config.LogLevel)); err != nil {
+			return config, fmt.Errorf("log level %q must be debug, info, warn or error", config.LogLevel)
+		}
+	}
+	switch config.LogFormat {
+	case "", "logfmt", "json":
+	default:
+		return config, fmt.Errorf("log format %q must be logfmt or json", config.LogFormat)
+	}
+	if config.LogMaxSize < 0 {
+		return config, fmt.Errorf("log max size %d must not be negative", config.LogMaxSize)
+	}
+
+	if config.ProjectDepth < 0 {
+		return config, fmt.Errorf("project depth %d must not be negative", config.ProjectDepth)
+	}
+
+	for _, pattern := range config.ProjectIgnore {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return config, fmt.Errorf("project ignore pattern %q: %w", pattern, err)
+		}
+	}
+
+	for name, text := range config.LinkTemplates {
+		if _, err := template.New(name).Parse(text); err != nil {
+			return config, fmt.Errorf("link template %q: %w", name, err)
+		}
+	}
+
+	for i, section := range config.ChangelogSections {
+		if len(section.Title) == 0 {
+			return config, fmt.Errorf("changelog section %d has no title", i+1)
+		}
+	}
+
+	switch config.CloneProtocol {
+	case "", "https", "ssh":
+	default:
+		return config, fmt.Errorf("clone protocol %q must be https or ssh", config.CloneProtocol)
+	}
+
 	return config, nil
 }
 
@@ -87,7 +260,15 @@ func LoadFromFile(path string) (Config, error) {
 		return Config{}, err
 	}
 
-	return Load(string(yml))
+	config, err := Load(string(yml))
+	if err != nil {
+		return config, err
+	}
+	config.Path = realpath
+	if info, err := os.Stat(realpath); err == nil {
+		config.ModTime = info.ModTime()
+	}
+	return config, nil
 }
 
 // LoadFromDefault loads and validates a config.
@@ -115,3 +296,13 @@ func validRepoFormat(s string) bool {
 	}
 	return true
 }
+
+// isLoopback reports whether a listen host only accepts local connections. An
+// empty host listens on every interface.
+func isLoopback(host string) bool {
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
@@ -1,7 +1,10 @@
 package config
 
 import (
+	"log/slog"
+	"os"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -31,6 +34,15 @@ repos:
 default_repo: foo
 `
 
+	invalidProjectDepth = `---
+project_depth: -1
+`
+
+	invalidProjectIgnore = `---
+project_ignore:
+  - "[unclosed"
+`
+
 	rpcEnabled = "---\napi_token: abcdefg"
 
 	repoMap = map[string]string{
@@ -76,12 +88,52 @@ func TestLoadInvalidDefault(t *testing.T) {
 	}
 }
 
+func TestLoadProjectDiscovery(t *testing.T) {
+	config, err := Load("---\nproject_depth: 3\nproject_markers: [go.mod]\nproject_ignore: [node_modules]")
+	require.NoError(t, err)
+	assert.Equal(t, 3, config.ProjectDepth)
+	assert.Equal(t, []string{"go.mod"}, config.ProjectMarkers)
+	assert.Equal(t, []string{"node_modules"}, config.ProjectIgnore)
+
+	_, err = Load(invalidProjectDepth)
+	assert.Error(t, err)
+
+	_, err = Load(invalidProjectIgnore)
+	assert.Error(t, err)
+}
+
+func TestCloneURL(t *testing.T) {
+	config, err := Load("---\nclone_protocol: ssh")
+	require.NoError(t, err)
+	assert.Equal(t, "git@github.com:zerowidth/gh-shorthand.git", config.CloneURL("zerowidth/gh-shorthand"))
+
+	config, err = Load("---\n")
+	require.NoError(t, err)
+	assert.Equal(t, "https://github.com/zerowidth/gh-shorthand.git", config.CloneURL("zerowidth/gh-shorthand"))
+
+	_, err = Load("---\nclone_protocol: ftp")
+	assert.Error(t, err)
+}
+
+func TestLinkTemplates(t *testing.T) {
+	config, err := Load("---\nlink_templates:\n  link: \"{{.Short}}#{{.Number}}\"")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"link": "{{.Short}}#{{.Number}}"}, config.LinkTemplates)
+
+	_, err = Load("---\nlink_templates:\n  link: \"{{.Short\"")
+	assert.Error(t, err)
+}
+
 func TestLoadFromFile(t *testing.T) {
 	config, err := LoadFromFile("testdata/config.yml")
 	assert.NoError(t, err)
 
 	assert.Equal(t, repoMap, config.RepoMap)
 	assert.Equal(t, "zerowidth/default", config.DefaultRepo)
+	assert.Equal(t, "testdata/config.yml", config.Path)
+	info, err := os.Stat("testdata/config.yml")
+	require.NoError(t, err)
+	assert.Equal(t, info.ModTime(), config.ModTime)
 
 	_, err = LoadFromFile("testdata/nonexistent.yml")
 	assert.Error(t, err)
@@ -120,3 +172,89 @@ func TestEditorScriptTakesPrecedence(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, `exec /usr/local/bin/zsh -c '/usr/local/mvim "$path"'`, s)
 }
+
+func TestCachePath(t *testing.T) {
+	cfg := Config{CacheDir: "/tmp/gh-shorthand-cache"}
+	path, err := cfg.CachePath("projects.json")
+	assert.NoError(t, err)
+	assert.Equal(t, "/tmp/gh-shorthand-cache/projects.json", path)
+
+	path, err = Config{}.CachePath("projects.json")
+	assert.NoError(t, err)
+	assert.Contains(t, path, "gh-shorthand/projects.json")
+}
+
+func TestChangelogSections(t *testing.T) {
+	config, err := Load("---\nchangelog_sections:\n  - title: Fixes\n    labels: [bug]\n  - title: Other\n")
+	require.NoError(t, err)
+	assert.Equal(t, []ChangelogSection{
+		{Title: "Fixes", Labels: []string{"bug"}},
+		{Title: "Other"},
+	}, config.ChangelogSections)
+
+	_, err = Load("---\nchangelog_sections:\n  - labels: [bug]\n")
+	assert.EqualError(t, err, "changelog section 1 has no title")
+}
+
+func TestCacheTTLs(t *testing.T) {
+	config, err := Load("---\ncache_ttl: 1h\nerror_ttl: 30s\n")
+	require.NoError(t, err)
+	assert.Equal(t, time.Hour, config.CacheTTL)
+	assert.Equal(t, 30*time.Second, config.ErrorTTL)
+
+	_, err = Load("---\ncache_ttl: -1m\n")
+	assert.EqualError(t, err, "cache ttl -1m0s must not be negative")
+	_, err = Load("---\nerror_ttl: soon\n")
+	assert.Error(t, err)
+}
+
+func TestRPCAddrs(t *testing.T) {
+	config, err := Load("---\nlisten_addr: localhost:7331\nrpc_secret: hunter2\n")
+	require.NoError(t, err)
+	assert.Equal(t, "localhost:7331", config.ListenAddr)
+	assert.False(t, config.RPCEnabled(), "listening doesn't enable the client")
+
+	config, err = Load("---\nserver_addr: host.docker.internal:7331\nrpc_secret: hunter2\n")
+	require.NoError(t, err)
+	assert.True(t, config.RPCEnabled(), "enabled without an api token")
+
+	_, err = Load("---\nlisten_addr: localhost\nrpc_secret: hunter2\n")
+	assert.EqualError(t, err, "listen_addr: address localhost: missing port in address")
+	_, err = Load("---\nserver_addr: localhost:7331\n")
+	assert.EqualError(t, err, "server_addr requires an rpc_secret")
+
+	for _, addr := range []string{"127.0.0.1:7331", "[::1]:7331", "LOCALHOST:7331"} {
+		_, err = Load("---\nlisten_addr: \"" + addr + "\"\nrpc_secret: hunter2\n")
+		assert.NoError(t, err, addr)
+	}
+	for _, addr := range []string{":7331", "0.0.0.0:7331", "192.168.1.2:7331", "example.com:7331"} {
+		_, err = Load("---\nlisten_addr: \"" + addr + "\"\nrpc_secret: hunter2\n")
+		assert.EqualError(t, err, "listen_addr "+addr+" isn't a loopback address, set listen_remote to allow it")
+		_, err = Load("---\nlisten_addr: \"" + addr + "\"\nlisten_remote: true\nrpc_secret: hunter2\n")
+		assert.NoError(t, err, addr)
+	}
+	_, err = Load("---\nserver_addr: 192.168.1.2:7331\nrpc_secret: hunter2\n")
+	assert.NoError(t, err, "clients can connect to any address")
+}
+
+func TestLogging(t *testing.T) {
+	config, err := Load("---\n")
+	require.NoError(t, err)
+	assert.Equal(t, slog.LevelInfo, config.Level())
+
+	config, err = Load("---\nlog_level: debug\nlog_format: json\nlog_file: ~/gh-shorthand.log\nlog_max_size: 5\n")
+	require.NoError(t, err)
+	assert.Equal(t, slog.LevelDebug, config.Level())
+	assert.Equal(t, "json", config.LogFormat)
+	assert.Equal(t, 5, config.LogMaxSize)
+	path, err := config.LogPath()
+	require.NoError(t, err)
+	assert.NotContains(t, path, "~")
+
+	_, err = Load("---\nlog_level: loud\n")
+	assert.EqualError(t, err, `log level "loud" must be debug, info, warn or error`)
+	_, err = Load("---\nlog_format: xml\n")
+	assert.EqualError(t, err, `log format "xml" must be logfmt or json`)
+	_, err = Load("---\nlog_max_size: -1\n")
+	assert.Error(t, err)
+}
@@ -0,0 +1,356 @@
+// Package gitrepo reads metadata from git checkouts.
+//
+// Everything except cloning and adding worktrees is read directly from the git
+// directory rather than by running git, so it's cheap enough to use while
+// completing.
+package gitrepo
+
+import (
+	"bufio"
+	"container/heap"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Repo is a git checkout
+type Repo struct {
+	Dir       string // the working directory
+	GitDir    string // the git directory, which is per-worktree
+	CommonDir string // the git directory shared between worktrees
+
+	Head          string // the commit HEAD points to
+	Branch        string // the current branch, empty if HEAD is detached
+	Upstream      string // the upstream tracking ref for the branch, if any
+	UpstreamHead  string // the commit the upstream ref points to
+	DefaultBranch string // the remote's default branch, if known
+	Remote        string // the URL of the origin remote, if any
+}
+
+// ErrNotRepo is returned when a directory isn't a git checkout
+var ErrNotRepo = errors.New("not a git repository")
+
+// Open reads the git metadata for a checkout.
+func Open(dir string) (*Repo, error) {
+	gitDir, err := findGitDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Repo{
+		Dir:       dir,
+		GitDir:    gitDir,
+		CommonDir: gitDir,
+	}
+
+	// worktrees share refs and config with the main repository
+	if common, err := readLine(filepath.Join(gitDir, "commondir")); err == nil {
+		if !filepath.IsAbs(common) {
+			common = filepath.Join(gitDir, common)
+		}
+		r.CommonDir = filepath.Clean(common)
+	}
+
+	head, err := readLine(filepath.Join(gitDir, "HEAD"))
+	if err != nil {
+		return nil, err
+	}
+	if ref := strings.TrimPrefix(head, "ref: "); ref != head {
+		r.Branch = strings.TrimPrefix(ref, "refs/heads/")
+		r.Head = r.resolve(ref)
+	} else {
+		r.Head = head
+	}
+
+	cfg := readConfig(filepath.Join(r.CommonDir, "config"))
+	r.Remote = cfg[`remote "origin".url`]
+	if r.Branch != "" {
+		remote := cfg[`branch "`+r.Branch+`".remote`]
+		merge := cfg[`branch "`+r.Branch+`".merge`]
+		if remote != "" && remote != "." && merge != "" {
+			r.Upstream = "refs/remotes/" + remote + "/" + strings.TrimPrefix(merge, "refs/heads/")
+			r.UpstreamHead = r.resolve(r.Upstream)
+		}
+	}
+
+	if originHead, err := readLine(filepath.Join(r.CommonDir, "refs/remotes/origin/HEAD")); err == nil {
+		r.DefaultBranch = strings.TrimPrefix(originHead, "ref: refs/remotes/origin/")
+	}
+
+	return r, nil
+}
+
+// IsDefaultBranch reports whether the current branch is the default branch.
+// Without a known default, main and master are assumed to be the default.
+func (r *Repo) IsDefaultBranch() bool {
+	if r.DefaultBranch != "" {
+		return r.Branch == r.DefaultBranch
+	}
+	return r.Branch == "main" || r.Branch == "master"
+}
+
+// GitHubRepo returns the owner/name of the origin remote, if it's hosted on
+// GitHub.
+func (r *Repo) GitHubRepo() (string, bool) {
+	return ParseGitHubRemote(r.Remote)
+}
+
+var remoteRegexp = regexp.MustCompile(
+	`^(?:(?:https?|git|ssh)://(?:[^@/]+@)?github\.com(?::\d+)?/|(?:[^@/]+@)?github\.com:)([^/]+)/([^/]+?)(?:\.git)?/?$`)
+
+// ParseGitHubRemote extracts the owner/name from a GitHub remote URL, in any of
+// the https, ssh or scp-like forms git accepts.
+func ParseGitHubRemote(remote string) (string, bool) {
+	matches := remoteRegexp.FindStringSubmatch(remote)
+	if matches == nil {
+		return "", false
+	}
+	return matches[1] + "/" + matches[2], true
+}
+
+// AheadBehind counts the commits HEAD is ahead of and behind its upstream.
+//
+// This walks history from both commits, newest first, until everything left to
+// walk is reachable from both, which is the same way git finds a merge base.
+// The result only depends on Head and UpstreamHead, so callers can cache it by
+// those. If the deadline passes before the walk finishes, ErrDeadline is
+// returned.
+func (r *Repo) AheadBehind(deadline time.Time) (int, int, error) {
+	if r.Head == "" || r.UpstreamHead == "" {
+		return 0, 0, fmt.Errorf("no upstream for %s", r.Dir)
+	}
+	if r.Head == r.UpstreamHead {
+		return 0, 0, nil
+	}
+
+	objects := r.objects()
+	defer objects.close()
+
+	const (
+		ahead  = 1 // reachable from HEAD
+		behind = 2 // reachable from the upstream
+		both   = ahead | behind
+	)
+	// in a shallow clone, history stops at the shallow commits
+	shallow := map[string]bool{}
+	if data, err := os.ReadFile(filepath.Join(r.CommonDir, "shallow")); err == nil {
+		for _, oid := range strings.Fields(string(data)) {
+			shallow[oid] = true
+		}
+	}
+
+	flags := map[string]int{r.Head: ahead, r.UpstreamHead: behind}
+	walked := map[string]int{} // the flags each commit was walked with
+	queue := &commitQueue{}
+	for oid := range flags {
+		c, err := objects.commit(oid)
+		if err != nil {
+			return 0, 0, err
+		}
+		heap.Push(queue, queuedCommit{oid, c})
+	}
+
+	for queue.Len() > 0 && !queue.allFlagged(flags, both) {
+		if len(walked)%64 == 0 && time.Now().After(deadline) {
+			return 0, 0, ErrDeadline
+		}
+		next := heap.Pop(queue).(queuedCommit)
+		f := flags[next.oid]
+		if walked[next.oid] == f {
+			continue
+		}
+		walked[next.oid] = f
+		if shallow[next.oid] {
+			continue
+		}
+		for _, parent := range next.parents {
+			if flags[parent]&f == f {
+				continue
+			}
+			flags[parent] |= f
+			c, err := objects.commit(parent)
+			if err != nil {
+				return 0, 0, err
+			}
+			heap.Push(queue, queuedCommit{parent, c})
+		}
+	}
+
+	var aheadCount, behindCount int
+	for _, f := range flags {
+		switch f {
+		case ahead:
+			aheadCount++
+		case behind:
+			behindCount++
+		}
+	}
+	return aheadCount, behindCount, nil
+}
+
+type queuedCommit struct {
+	oid string
+	commit
+}
+
+// commitQueue is a heap of commits, newest first
+type commitQueue []queuedCommit
+
+func (q commitQueue) Len() int            { return len(q) }
+func (q commitQueue) Less(i, j int) bool  { return q[i].time > q[j].time }
+func (q commitQueue) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *commitQueue) Push(x interface{}) { *q = append(*q, x.(queuedCommit)) }
+func (q *commitQueue) Pop() interface{} {
+	old := *q
+	c := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return c
+}
+
+// allFlagged reports whether every queued commit has all of the given flags,
+// so that nothing left to walk can change the counts.
+func (q commitQueue) allFlagged(flags map[string]int, all int) bool {
+	for _, c := range q {
+		if flags[c.oid] != all {
+			return false
+		}
+	}
+	return true
+}
+
+// Clone clones a repository from url into dest, which must not already exist.
+// If git fails, its output is included in the error.
+func Clone(url, dest string) error {
+	if _, err := os.Stat(dest); err == nil {
+		return fmt.Errorf("%s already exists", dest)
+	}
+	out, err := exec.Command("git", "clone", "--quiet", "--", url, dest).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("git clone %s: %s", url, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// findGitDir locates the git directory for a checkout, following the
+// "gitdir: path" files used by worktrees and submodules.
+func findGitDir(dir string) (string, error) {
+	dotGit := filepath.Join(dir, ".git")
+	info, err := os.Stat(dotGit)
+	if err != nil {
+		return "", ErrNotRepo
+	}
+	if info.IsDir() {
+		return dotGit, nil
+	}
+
+	line, err := readLine(dotGit)
+	if err != nil {
+		return "", err
+	}
+	gitDir := strings.TrimPrefix(line, "gitdir: ")
+	if gitDir == line {
+		return "", ErrNotRepo
+	}
+	if !filepath.IsAbs(gitDir) {
+		gitDir = filepath.Join(dir, gitDir)
+	}
+	return filepath.Clean(gitDir), nil
+}
+
+// resolve a ref to a commit, from either a loose ref or packed-refs. Returns an
+// empty string if the ref doesn't exist.
+func (r *Repo) resolve(ref string) string {
+	for i := 0; i < 5; i++ { // follow a few levels of symbolic refs
+		dir := r.CommonDir
+		if !strings.HasPrefix(ref, "refs/") {
+			dir = r.GitDir // HEAD and other pseudo-refs are per-worktree
+		}
+
+		line, err := readLine(filepath.Join(dir, ref))
+		if err != nil {
+			return r.packedRef(ref)
+		}
+		if target := strings.TrimPrefix(line, "ref: "); target != line {
+			ref = target
+			continue
+		}
+		return line
+	}
+	return ""
+}
+
+func (r *Repo) packedRef(ref string) string {
+	f, err := os.Open(filepath.Join(r.CommonDir, "packed-refs"))
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && fields[1] == ref {
+			return fields[0]
+		}
+	}
+	return ""
+}
+
+// readConfig reads a git config file into a map of `section "sub".key` to
+// value. This only handles what's needed here: no includes, no multi-valued
+// keys, and no line continuations.
+func readConfig(path string) map[string]string {
+	cfg := map[string]string{}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return cfg
+	}
+	defer f.Close()
+
+	section := ""
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "" || line[0] == '#' || line[0] == ';':
+			continue
+		case line[0] == '[' && strings.HasSuffix(line, "]"):
+			section = parseSection(line[1 : len(line)-1])
+		default:
+			key, value, found := strings.Cut(line, "=")
+			if !found {
+				continue
+			}
+			key = strings.ToLower(strings.TrimSpace(key))
+			value = strings.Trim(strings.TrimSpace(value), `"`)
+			cfg[section+"."+key] = value
+		}
+	}
+	return cfg
+}
+
+// parseSection normalizes a section header: section names are case
+// insensitive, subsection names are not.
+func parseSection(header string) string {
+	name, sub, found := strings.Cut(header, " ")
+	name = strings.ToLower(name)
+	if !found {
+		return name
+	}
+	return name + " " + strings.TrimSpace(sub)
+}
+
+func readLine(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	line, _, _ := strings.Cut(string(data), "\n")
+	return strings.TrimSpace(line), nil
+}
@@ -0,0 +1,481 @@
+package gitrepo
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// git runs a git command in dir, failing the test if it fails
+func git(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(),
+		"GIT_CONFIG_NOSYSTEM=1",
+		"HOME="+dir,
+		"GIT_AUTHOR_NAME=test", "GIT_AUTHOR_EMAIL=test@example.com",
+		"GIT_COMMITTER_NAME=test", "GIT_COMMITTER_EMAIL=test@example.com",
+	)
+	out, err := cmd.CombinedOutput()
+	require.NoError(t, err, "git %v: %s", args, out)
+	return string(out)
+}
+
+// newRepo creates a checkout with a commit on main, cloned from a bare origin
+// repository.
+func newRepo(t *testing.T) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+
+	root := t.TempDir()
+	origin := filepath.Join(root, "origin.git")
+	git(t, root, "init", "-q", "--bare", "-b", "main", origin)
+
+	dir := filepath.Join(root, "checkout")
+	git(t, root, "clone", "-q", origin, dir)
+	git(t, dir, "checkout", "-q", "-b", "main")
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "README"), []byte("hello\n"), 0644))
+	git(t, dir, "add", "README")
+	git(t, dir, "commit", "-q", "-m", "initial")
+	git(t, dir, "push", "-q", "-u", "origin", "main")
+	git(t, dir, "remote", "set-head", "origin", "main")
+	return dir
+}
+
+func TestOpen(t *testing.T) {
+	dir := newRepo(t)
+
+	r, err := Open(dir)
+	require.NoError(t, err)
+	assert.Equal(t, "main", r.Branch)
+	assert.Equal(t, "main", r.DefaultBranch)
+	assert.True(t, r.IsDefaultBranch())
+	assert.Len(t, r.Head, 40)
+	assert.Equal(t, "refs/remotes/origin/main", r.Upstream)
+	assert.Equal(t, r.Head, r.UpstreamHead)
+	assert.Contains(t, r.Remote, "origin.git")
+}
+
+func TestOpenPackedRefs(t *testing.T) {
+	dir := newRepo(t)
+	git(t, dir, "pack-refs", "--all")
+
+	r, err := Open(dir)
+	require.NoError(t, err)
+	assert.Len(t, r.Head, 40)
+	assert.Equal(t, r.Head, r.UpstreamHead)
+}
+
+func TestOpenDetached(t *testing.T) {
+	dir := newRepo(t)
+	git(t, dir, "checkout", "-q", "--detach")
+
+	r, err := Open(dir)
+	require.NoError(t, err)
+	assert.Empty(t, r.Branch)
+	assert.Len(t, r.Head, 40)
+	assert.False(t, r.IsDefaultBranch())
+}
+
+func TestOpenNotRepo(t *testing.T) {
+	_, err := Open(t.TempDir())
+	assert.Equal(t, ErrNotRepo, err)
+}
+
+// sharedLines is the part of a file that's the same in every version, so that
+// packed versions are stored as deltas of each other
+var sharedLines = func() string {
+	var b strings.Builder
+	for i := 0; i < 500; i++ {
+		fmt.Fprintf(&b, "shared line %d\n", i)
+	}
+	return b.String()
+}()
+
+// packName returns the name of the index of the only pack in a checkout
+func packName(t *testing.T, dir string) string {
+	t.Helper()
+	indexes, err := filepath.Glob(filepath.Join(dir, ".git/objects/pack/*.idx"))
+	require.NoError(t, err)
+	require.Len(t, indexes, 1)
+	return filepath.Base(indexes[0])
+}
+
+func TestAheadBehind(t *testing.T) {
+	dir := newRepo(t)
+	deadline := time.Now().Add(time.Second)
+
+	r, err := Open(dir)
+	require.NoError(t, err)
+	ahead, behind, err := r.AheadBehind(deadline)
+	require.NoError(t, err)
+	assert.Equal(t, 0, ahead)
+	assert.Equal(t, 0, behind)
+
+	git(t, dir, "commit", "-q", "--allow-empty", "-m", "one")
+	git(t, dir, "commit", "-q", "--allow-empty", "-m", "two")
+
+	r, err = Open(dir)
+	require.NoError(t, err)
+	ahead, behind, err = r.AheadBehind(deadline)
+	require.NoError(t, err)
+	assert.Equal(t, 2, ahead)
+	assert.Equal(t, 0, behind)
+}
+
+func TestAheadBehindDiverged(t *testing.T) {
+	for _, packed := range []bool{false, true} {
+		t.Run(fmt.Sprintf("packed %v", packed), func(t *testing.T) {
+			dir := newRepo(t)
+			// the upstream moves on with a merge, and the branch with commits of
+			// its own
+			git(t, dir, "checkout", "-q", "-b", "side")
+			git(t, dir, "commit", "-q", "--allow-empty", "-m", "side")
+			git(t, dir, "checkout", "-q", "main")
+			git(t, dir, "commit", "-q", "--allow-empty", "-m", "upstream")
+			git(t, dir, "merge", "-q", "--no-ff", "-m", "merge", "side")
+			git(t, dir, "push", "-q", "origin", "main")
+			git(t, dir, "reset", "-q", "--hard", "HEAD~2")
+			for i := 0; i < 3; i++ {
+				git(t, dir, "commit", "-q", "--allow-empty", "-m", fmt.Sprintf("local %d", i))
+			}
+			if packed {
+				git(t, dir, "gc", "-q")
+			}
+
+			r, err := Open(dir)
+			require.NoError(t, err)
+			ahead, behind, err := r.AheadBehind(time.Now().Add(time.Second))
+			require.NoError(t, err)
+			assert.Equal(t, "3\t3\n", git(t, dir, "rev-list", "--left-right", "--count", "HEAD...@{u}"))
+			assert.Equal(t, 3, ahead)
+			assert.Equal(t, 3, behind)
+		})
+	}
+}
+
+func TestAheadBehindDeadline(t *testing.T) {
+	dir := newRepo(t)
+	git(t, dir, "commit", "-q", "--allow-empty", "-m", "one")
+
+	r, err := Open(dir)
+	require.NoError(t, err)
+	_, _, err = r.AheadBehind(time.Now().Add(-time.Second))
+	assert.Equal(t, ErrDeadline, err)
+}
+
+func TestUncommitted(t *testing.T) {
+	for _, version := range []string{"2", "3", "4"} {
+		t.Run("index version "+version, func(t *testing.T) {
+			dir := newRepo(t)
+			require.NoError(t, os.MkdirAll(filepath.Join(dir, "some/nested/dir"), 0755))
+			require.NoError(t, os.WriteFile(filepath.Join(dir, "some/nested/dir/file"), []byte("nested"), 0644))
+			require.NoError(t, os.WriteFile(filepath.Join(dir, "some/file"), []byte("file"), 0644))
+			git(t, dir, "add", ".")
+			git(t, dir, "commit", "-q", "-m", "more files")
+			git(t, dir, "update-index", "--index-version", version)
+			git(t, dir, "status") // refresh the index
+
+			r, err := Open(dir)
+			require.NoError(t, err)
+			deadline := time.Now().Add(time.Second)
+
+			uncommitted, err := r.Uncommitted(deadline)
+			require.NoError(t, err)
+			assert.False(t, uncommitted, "clean checkout")
+
+			require.NoError(t, os.WriteFile(filepath.Join(dir, "some/nested/untracked"), []byte("new"), 0644))
+			uncommitted, err = r.Uncommitted(deadline)
+			require.NoError(t, err)
+			assert.True(t, uncommitted, "untracked file")
+			require.NoError(t, os.Remove(filepath.Join(dir, "some/nested/untracked")))
+
+			require.NoError(t, os.WriteFile(filepath.Join(dir, "some/nested/dir/file"), []byte("changed"), 0644))
+			uncommitted, err = r.Uncommitted(deadline)
+			require.NoError(t, err)
+			assert.True(t, uncommitted, "modified file")
+		})
+	}
+}
+
+func TestUncommittedDeleted(t *testing.T) {
+	dir := newRepo(t)
+	require.NoError(t, os.Remove(filepath.Join(dir, "README")))
+
+	r, err := Open(dir)
+	require.NoError(t, err)
+	uncommitted, err := r.Uncommitted(time.Now().Add(time.Second))
+	require.NoError(t, err)
+	assert.True(t, uncommitted)
+}
+
+func TestUncommittedStaged(t *testing.T) {
+	for _, packed := range []bool{false, true} {
+		t.Run(fmt.Sprintf("packed %v", packed), func(t *testing.T) {
+			dir := newRepo(t)
+			// enough history for packed trees and blobs to be stored as deltas
+			for i := 0; i < 10; i++ {
+				require.NoError(t, os.MkdirAll(filepath.Join(dir, "dir"), 0755))
+				content := sharedLines + fmt.Sprintf("line %d\n", i)
+				require.NoError(t, os.WriteFile(filepath.Join(dir, "dir", "file"), []byte(content), 0644))
+				git(t, dir, "add", ".")
+				git(t, dir, "commit", "-q", "-m", fmt.Sprintf("commit %d", i))
+			}
+			if packed {
+				git(t, dir, "gc", "-q")
+			}
+
+			r, err := Open(dir)
+			require.NoError(t, err)
+			deadline := time.Now().Add(time.Second)
+
+			uncommitted, err := r.Uncommitted(deadline)
+			require.NoError(t, err)
+			assert.False(t, uncommitted, "clean checkout, compared with the cached tree")
+
+			// staging a change and then staging it back invalidates the cached
+			// tree, so HEAD's tree has to be read and compared in full
+			require.NoError(t, os.WriteFile(filepath.Join(dir, "README"), []byte("staged\n"), 0644))
+			git(t, dir, "add", "README")
+			uncommitted, err = r.Uncommitted(deadline)
+			require.NoError(t, err)
+			assert.True(t, uncommitted, "staged change")
+
+			require.NoError(t, os.WriteFile(filepath.Join(dir, "README"), []byte("hello\n"), 0644))
+			git(t, dir, "add", "README")
+			uncommitted, err = r.Uncommitted(deadline)
+			require.NoError(t, err)
+			assert.False(t, uncommitted, "change staged back, compared with HEAD's tree")
+
+			git(t, dir, "update-index", "--chmod=+x", "dir/file")
+			uncommitted, err = r.Uncommitted(deadline)
+			require.NoError(t, err)
+			assert.True(t, uncommitted, "staged mode change")
+			git(t, dir, "update-index", "--chmod=-x", "dir/file")
+
+			git(t, dir, "rm", "-q", "--cached", "dir/file")
+			uncommitted, err = r.Uncommitted(deadline)
+			require.NoError(t, err)
+			assert.True(t, uncommitted, "staged removal")
+		})
+	}
+}
+
+func TestUncommittedUntracked(t *testing.T) {
+	dir := newRepo(t)
+	write := func(path, content string) {
+		t.Helper()
+		require.NoError(t, os.MkdirAll(filepath.Join(dir, filepath.Dir(path)), 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, path), []byte(content), 0644))
+	}
+	write(".gitignore", "*.log\n/build/\ndocs/**/*.tmp\n!keep.log\n")
+	write("sub/.gitignore", "local\n")
+	git(t, dir, "add", ".")
+	git(t, dir, "commit", "-q", "-m", "ignores")
+
+	r, err := Open(dir)
+	require.NoError(t, err)
+	uncommitted := func() bool {
+		t.Helper()
+		changed, err := r.Uncommitted(time.Now().Add(time.Second))
+		require.NoError(t, err)
+		return changed
+	}
+
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "empty/dir"), 0755))
+	write("debug.log", "")
+	write("sub/deep/debug.log", "")
+	write("build/output", "")
+	write("docs/a/b/notes.tmp", "")
+	write("sub/local", "")
+	write("sub/deep/local", "")
+	write(".git/info/exclude", "excluded\n")
+	write("excluded", "")
+	assert.False(t, uncommitted(), "only ignored files and empty directories")
+
+	write("sub/build/output", "")
+	assert.True(t, uncommitted(), "/build/ is anchored to the root")
+	require.NoError(t, os.RemoveAll(filepath.Join(dir, "sub/build")))
+
+	write("sub/keep.log", "")
+	assert.True(t, uncommitted(), "re-included by a negated pattern")
+	require.NoError(t, os.Remove(filepath.Join(dir, "sub/keep.log")))
+
+	write("docs/notes.tmp", "")
+	write("other/a/notes.tmp", "")
+	assert.True(t, uncommitted(), "docs/**/*.tmp is anchored to docs")
+	require.NoError(t, os.RemoveAll(filepath.Join(dir, "other")))
+	assert.False(t, uncommitted(), "** matches no directories in between too")
+
+	git(t, dir, "init", "-q", filepath.Join(dir, "nested"))
+	assert.True(t, uncommitted(), "nested repository")
+
+	git(t, dir, "config", "status.showUntrackedFiles", "no")
+	assert.False(t, uncommitted(), "untracked files aren't shown")
+}
+
+func TestIgnoreRules(t *testing.T) {
+	for _, tc := range []struct {
+		pattern, base, path string
+		isDir, ignored      bool
+	}{
+		{pattern: "*.log", path: "a.log", ignored: true},
+		{pattern: "*.log", path: "dir/a.log", ignored: true},
+		{pattern: "*.log", path: "a.txt"},
+		{pattern: "tmp/", path: "dir/tmp", isDir: true, ignored: true},
+		{pattern: "tmp/", path: "dir/tmp"},
+		{pattern: "/tmp", path: "tmp", ignored: true},
+		{pattern: "/tmp", path: "dir/tmp"},
+		{pattern: "a/b", path: "a/b", ignored: true},
+		{pattern: "a/b", path: "x/a/b"},
+		{pattern: "**/b", path: "x/y/b", ignored: true},
+		{pattern: "a/**", path: "a/x/y", ignored: true},
+		{pattern: "a/**", path: "a", isDir: true},
+		{pattern: "a/**/b", path: "a/b", ignored: true},
+		{pattern: "a/**/b", path: "a/x/y/b", ignored: true},
+		{pattern: "[!a]*", path: "b", ignored: true},
+		{pattern: "[!a]*", path: "a"},
+		{pattern: `\#notes`, path: "#notes", ignored: true},
+		{pattern: "out", base: "sub", path: "sub/x/out", ignored: true},
+		{pattern: "out", base: "sub", path: "out"},
+		{pattern: "/out", base: "sub", path: "sub/out", ignored: true},
+		{pattern: "/out", base: "sub", path: "sub/x/out"},
+	} {
+		file := filepath.Join(t.TempDir(), ".gitignore")
+		require.NoError(t, os.WriteFile(file, []byte("# comment\n\n"+tc.pattern+"\n"), 0644))
+		rules := readIgnoreFile(file, tc.base)
+		require.Len(t, rules, 1, tc.pattern)
+		assert.Equal(t, tc.ignored, ignored(rules, tc.path, tc.isDir), "%s in %q matching %s", tc.pattern, tc.base, tc.path)
+	}
+}
+
+func TestReadObjects(t *testing.T) {
+	dir := newRepo(t)
+	for i := 0; i < 10; i++ {
+		content := sharedLines + fmt.Sprintf("line %d\n", i)
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "README"), []byte(content), 0644))
+		git(t, dir, "commit", "-q", "-a", "-m", fmt.Sprintf("commit %d", i))
+	}
+	git(t, dir, "gc", "-q", "--aggressive")
+	assert.Regexp(t, `(?m)^\w+ blob +\d+ \d+ \d+ \d+ \w+$`,
+		git(t, dir, "verify-pack", "-v", filepath.Join(dir, ".git/objects/pack", packName(t, dir))),
+		"some blobs are stored as deltas")
+	// something loose as well as what's packed
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "README"), []byte("loose\n"), 0644))
+	git(t, dir, "commit", "-q", "-a", "-m", "loose")
+
+	r, err := Open(dir)
+	require.NoError(t, err)
+	objects := r.objects()
+	defer objects.close()
+
+	for i := 0; i <= 10; i++ {
+		rev := fmt.Sprintf("HEAD~%d:README", i)
+		oid := strings.TrimSpace(git(t, dir, "rev-parse", rev))
+		typ, data, err := objects.read(oid)
+		require.NoError(t, err, rev)
+		assert.Equal(t, objBlob, typ, rev)
+		assert.Equal(t, git(t, dir, "cat-file", "blob", oid), string(data), rev)
+	}
+
+	c, err := objects.commit(r.Head)
+	require.NoError(t, err)
+	assert.Equal(t, strings.TrimSpace(git(t, dir, "rev-parse", "HEAD^{tree}")), c.tree)
+	assert.Equal(t, []string{strings.TrimSpace(git(t, dir, "rev-parse", "HEAD~"))}, c.parents)
+
+	_, _, err = objects.read(strings.Repeat("0", 40))
+	assert.ErrorIs(t, err, errObjectNotFound)
+}
+
+func TestParseGitHubRemote(t *testing.T) {
+	for remote, expected := range map[string]string{
+		"https://github.com/zerowidth/gh-shorthand":        "zerowidth/gh-shorthand",
+		"https://github.com/zerowidth/gh-shorthand.git":    "zerowidth/gh-shorthand",
+		"https://github.com/zerowidth/gh-shorthand/":       "zerowidth/gh-shorthand",
+		"https://user@github.com/zerowidth/gh-shorthand":   "zerowidth/gh-shorthand",
+		"git@github.com:zerowidth/gh-shorthand.git":        "zerowidth/gh-shorthand",
+		"github.com:zerowidth/gh-shorthand":                "zerowidth/gh-shorthand",
+		"ssh://git@github.com/zerowidth/gh-shorthand.git":  "zerowidth/gh-shorthand",
+		"ssh://git@github.com:22/zerowidth/dotfiles.git":   "zerowidth/dotfiles",
+		"git://github.com/zerowidth/gh-shorthand.git":      "zerowidth/gh-shorthand",
+		"https://gitlab.com/zerowidth/gh-shorthand.git":    "",
+		"git@example.com:zerowidth/gh-shorthand.git":       "",
+		"https://github.com/zerowidth":                     "",
+		"https://github.com/zerowidth/gh-shorthand/issues": "",
+		"/tmp/origin.git": "",
+	} {
+		repo, ok := ParseGitHubRemote(remote)
+		assert.Equal(t, expected, repo, remote)
+		assert.Equal(t, expected != "", ok, remote)
+	}
+}
+
+func TestClone(t *testing.T) {
+	origin := filepath.Join(filepath.Dir(newRepo(t)), "origin.git")
+	dest := filepath.Join(t.TempDir(), "clone")
+
+	require.NoError(t, Clone(origin, dest))
+	r, err := Open(dest)
+	require.NoError(t, err)
+	assert.Equal(t, "main", r.Branch)
+	assert.Equal(t, origin, r.Remote)
+
+	err = Clone(origin, dest)
+	assert.Error(t, err, "destination exists")
+
+	err = Clone(filepath.Join(t.TempDir(), "missing.git"), filepath.Join(t.TempDir(), "clone"))
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "does not exist")
+	}
+}
+
+func TestWorktrees(t *testing.T) {
+	dir := newRepo(t)
+	r, err := Open(dir)
+	require.NoError(t, err)
+
+	worktrees, err := r.Worktrees()
+	require.NoError(t, err)
+	assert.Empty(t, worktrees)
+
+	git(t, dir, "push", "-q", "origin", "main:remote-branch")
+	git(t, dir, "fetch", "-q")
+	require.NoError(t, r.AddWorktree(dir+"-feature", "feature"))
+	require.NoError(t, r.AddWorktree(dir+"-remote", "remote-branch"))
+	git(t, dir, "worktree", "add", "-q", "--detach", dir+"-detached")
+	git(t, dir, "worktree", "add", "-q", dir+"-removed")
+	require.NoError(t, os.RemoveAll(dir+"-removed"))
+
+	worktrees, err = r.Worktrees()
+	require.NoError(t, err)
+	require.Len(t, worktrees, 3)
+
+	assert.Equal(t, dir+"-detached", worktrees[0].Dir)
+	assert.Empty(t, worktrees[0].Branch)
+	assert.Equal(t, r.Head, worktrees[0].Head)
+
+	assert.Equal(t, dir+"-feature", worktrees[1].Dir)
+	assert.Equal(t, "feature", worktrees[1].Branch)
+	assert.Equal(t, r.Head, worktrees[1].Head)
+
+	assert.Equal(t, dir+"-remote", worktrees[2].Dir)
+	assert.Equal(t, "remote-branch", worktrees[2].Branch)
+
+	wt, err := Open(worktrees[2].Dir)
+	require.NoError(t, err)
+	assert.Equal(t, "refs/remotes/origin/remote-branch", wt.Upstream, "tracks the remote branch")
+	assert.Equal(t, r.CommonDir, wt.CommonDir)
+
+	err = r.AddWorktree(dir+"-again", "feature")
+	assert.Error(t, err, "branch is already checked out")
+}
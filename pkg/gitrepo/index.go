@@ -0,0 +1,312 @@
+package gitrepo
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// ErrDeadline is returned when checking for changes took too long
+var ErrDeadline = errors.New("deadline exceeded")
+
+const (
+	flagAssumeValid  = 0x8000
+	flagExtended     = 0x4000
+	flagStage        = 0x3000
+	flagNameLength   = 0x0fff
+	flagSkipWorktree = 0x4000 // in the extended flags
+	flagIntentToAdd  = 0x2000 // in the extended flags
+
+	modeTypeMask = 0170000
+	modeTree     = 0040000
+	modeRegular  = 0100000
+	modeGitlink  = 0160000
+)
+
+// Uncommitted reports whether a checkout has any uncommitted changes: changes
+// to tracked files that haven't been staged, staged changes that haven't been
+// committed, or untracked files that aren't ignored.
+//
+// Like git's own fast path, unstaged changes are found by comparing the size
+// and modification time of each file against what's recorded in the index
+// rather than by reading file contents. Staged changes are found by comparing
+// the index to HEAD's tree, and untracked files by walking the working
+// directory, skipping anything excluded by .gitignore files, info/exclude or
+// core.excludesFile. If the deadline passes before the check finishes,
+// ErrDeadline is returned.
+func (r *Repo) Uncommitted(deadline time.Time) (bool, error) {
+	idx, err := r.readIndex()
+	if err != nil {
+		return false, err
+	}
+	for _, check := range []func(*index, time.Time) (bool, error){r.unstaged, r.staged, r.untracked} {
+		if changed, err := check(idx, deadline); err != nil || changed {
+			return changed, err
+		}
+	}
+	return false, nil
+}
+
+// index is what's needed from a git index to look for changes
+type index struct {
+	entries []indexEntry
+	// the tree the whole index would be written as, if the cache of it in the
+	// TREE extension is valid
+	tree string
+	// whether this is a split index, where most entries are in a shared index
+	split bool
+}
+
+type indexEntry struct {
+	path      string
+	oid       string
+	mode      uint32
+	size      uint32
+	mtimeSec  uint32
+	mtimeNsec uint32
+	flags     uint16
+	extended  uint16
+}
+
+// readIndex parses the index, which is a header, the entries sorted by path,
+// then extensions and a checksum. A missing index has no entries.
+func (r *Repo) readIndex() (*index, error) {
+	data, err := os.ReadFile(filepath.Join(r.GitDir, "index"))
+	if os.IsNotExist(err) {
+		return &index{}, nil // nothing's been added yet
+	} else if err != nil {
+		return nil, err
+	}
+
+	if len(data) < 12 || string(data[0:4]) != "DIRC" {
+		return nil, fmt.Errorf("invalid index in %s", r.GitDir)
+	}
+	version := binary.BigEndian.Uint32(data[4:8])
+	if version < 2 || version > 4 {
+		return nil, fmt.Errorf("unsupported index version %d", version)
+	}
+	count := binary.BigEndian.Uint32(data[8:12])
+	hashSize := r.hashSize()
+	idx := &index{entries: make([]indexEntry, 0, count)}
+	truncated := fmt.Errorf("truncated index in %s", r.GitDir)
+
+	offset := 12
+	var path []byte
+	for i := uint32(0); i < count; i++ {
+		start := offset
+		statEnd := start + 40 + hashSize
+		if statEnd+2 > len(data) {
+			return nil, truncated
+		}
+		e := indexEntry{
+			mtimeSec:  binary.BigEndian.Uint32(data[start+8:]),
+			mtimeNsec: binary.BigEndian.Uint32(data[start+12:]),
+			mode:      binary.BigEndian.Uint32(data[start+24:]),
+			size:      binary.BigEndian.Uint32(data[start+36:]),
+			oid:       hex.EncodeToString(data[start+40 : statEnd]),
+			flags:     binary.BigEndian.Uint16(data[statEnd:]),
+		}
+		offset = statEnd + 2
+
+		if version >= 3 && e.flags&flagExtended != 0 {
+			if offset+2 > len(data) {
+				return nil, truncated
+			}
+			e.extended = binary.BigEndian.Uint16(data[offset:])
+			offset += 2
+		}
+
+		if version == 4 {
+			// paths are prefix-compressed against the previous entry's path
+			strip, n := readOffset(data[offset:])
+			if n == 0 || strip > len(path) {
+				return nil, fmt.Errorf("invalid index entry in %s", r.GitDir)
+			}
+			offset += n
+			end := bytes.IndexByte(data[offset:], 0)
+			if end < 0 {
+				return nil, truncated
+			}
+			path = append(path[:len(path)-strip], data[offset:offset+end]...)
+			offset += end + 1
+		} else {
+			end := bytes.IndexByte(data[offset:], 0)
+			if end < 0 {
+				return nil, truncated
+			}
+			path = append(path[:0], data[offset:offset+end]...)
+			// entries are NUL-padded to a multiple of eight bytes
+			offset = start + ((offset + end - start + 8) &^ 7)
+		}
+		e.path = string(path)
+		idx.entries = append(idx.entries, e)
+	}
+
+	// each extension is a signature and a size, and the checksum follows
+	for offset+8 <= len(data)-hashSize {
+		size := int(binary.BigEndian.Uint32(data[offset+4:]))
+		ext := data[offset+8:]
+		if size > len(ext) {
+			return nil, truncated
+		}
+		switch string(data[offset : offset+4]) {
+		case "TREE":
+			idx.tree = cachedTree(ext[:size], hashSize)
+		case "link":
+			idx.split = true
+		}
+		offset += 8 + size
+	}
+
+	return idx, nil
+}
+
+// cachedTree returns the root tree from the TREE extension, if it's valid. The
+// root is the first entry: an empty path, NUL, the number of index entries it
+// covers, a space, the number of subtrees, a newline, and then the tree's ID.
+// The count is -1 when the tree is invalid and there's no ID.
+func cachedTree(ext []byte, hashSize int) string {
+	if len(ext) == 0 || ext[0] != 0 {
+		return ""
+	}
+	line, rest, found := bytes.Cut(ext[1:], []byte("\n"))
+	entries, _, _ := bytes.Cut(line, []byte(" "))
+	if !found || bytes.HasPrefix(entries, []byte("-")) || len(rest) < hashSize {
+		return ""
+	}
+	return hex.EncodeToString(rest[:hashSize])
+}
+
+// unstaged looks for changes to tracked files that haven't been staged, as well
+// as unmerged files and files added with --intent-to-add.
+func (r *Repo) unstaged(idx *index, deadline time.Time) (bool, error) {
+	for i, e := range idx.entries {
+		if i%256 == 0 && time.Now().After(deadline) {
+			return false, ErrDeadline
+		}
+
+		switch {
+		case e.flags&flagStage != 0, e.extended&flagIntentToAdd != 0:
+			return true, nil // unmerged, or added with --intent-to-add
+		case e.flags&flagAssumeValid != 0, e.extended&flagSkipWorktree != 0:
+			continue
+		case e.mode&modeTypeMask == modeGitlink:
+			continue // submodules are their own checkouts
+		}
+
+		info, err := os.Lstat(filepath.Join(r.Dir, e.path))
+		if err != nil {
+			return true, nil // deleted
+		}
+		mtime := info.ModTime()
+		if uint32(info.Size()) != e.size || uint32(mtime.Unix()) != e.mtimeSec ||
+			(e.mtimeNsec != 0 && uint32(mtime.Nanosecond()) != e.mtimeNsec) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// staged looks for differences between the index and HEAD's tree. When the
+// index has a valid cache of its own tree, that's all that needs comparing.
+// Otherwise HEAD's tree is read in full and compared entry by entry.
+func (r *Repo) staged(idx *index, deadline time.Time) (bool, error) {
+	if idx.split {
+		return false, errors.New("split indexes aren't supported")
+	}
+	if r.Head == "" {
+		return len(idx.entries) > 0, nil // nothing's been committed yet
+	}
+
+	objects := r.objects()
+	defer objects.close()
+	head, err := objects.commit(r.Head)
+	if err != nil {
+		return false, err
+	}
+	if idx.tree != "" {
+		return idx.tree != head.tree, nil
+	}
+
+	// a sparse index has entries for whole directories outside of the sparse
+	// checkout, which are compared as trees
+	sparse := map[string]bool{}
+	for _, e := range idx.entries {
+		if e.mode&modeTypeMask == modeTree {
+			sparse[e.path] = true
+		}
+	}
+
+	tree := map[string]treeEntry{}
+	if err := objects.flatten(head.tree, "", sparse, tree, deadline); err != nil {
+		return false, err
+	}
+	if len(tree) != len(idx.entries) {
+		return true, nil
+	}
+	for _, e := range idx.entries {
+		t, ok := tree[e.path]
+		if !ok || t.oid != e.oid || canonicalMode(t.mode) != canonicalMode(e.mode) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// flatten reads a tree and its subtrees into a map of paths to entries, as
+// they'd appear in the index.
+func (s *objectStore) flatten(oid, prefix string, sparse map[string]bool, into map[string]treeEntry, deadline time.Time) error {
+	if time.Now().After(deadline) {
+		return ErrDeadline
+	}
+	entries, err := s.tree(oid)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		path := prefix + e.name
+		if e.mode&modeTypeMask != modeTree {
+			into[path] = e
+		} else if sparse[path+"/"] {
+			into[path+"/"] = e
+		} else if err := s.flatten(e.oid, path+"/", sparse, into, deadline); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// canonicalMode normalizes the permissions of regular files, since old trees
+// can have modes like 100664 that git treats as 100644.
+func canonicalMode(mode uint32) uint32 {
+	if mode&modeTypeMask != modeRegular {
+		return mode & modeTypeMask
+	}
+	if mode&0100 != 0 {
+		return modeRegular | 0755
+	}
+	return modeRegular | 0644
+}
+
+// readOffset decodes the variable-length integer used in v4 indexes, returning
+// the value and the number of bytes read.
+func readOffset(data []byte) (int, int) {
+	if len(data) == 0 {
+		return 0, 0
+	}
+	value := int(data[0] & 0x7f)
+	n := 1
+	for data[n-1]&0x80 != 0 {
+		if n >= len(data) {
+			return 0, 0
+		}
+		value = ((value + 1) << 7) | int(data[n]&0x7f)
+		n++
+	}
+	return value, n
+}
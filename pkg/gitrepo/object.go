@@ -0,0 +1,434 @@
+package gitrepo
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// object types, as numbered in packfiles
+const (
+	objCommit   = 1
+	objTree     = 2
+	objBlob     = 3
+	objTag      = 4
+	objOfsDelta = 6
+	objRefDelta = 7
+)
+
+var objectTypes = map[string]int{
+	"commit": objCommit,
+	"tree":   objTree,
+	"blob":   objBlob,
+	"tag":    objTag,
+}
+
+// maxDeltaDepth is deeper than git will make a delta chain by default, so
+// anything longer is a corrupt pack rather than a long chain.
+const maxDeltaDepth = 100
+
+// errObjectNotFound is returned when an object isn't loose or in any pack
+var errObjectNotFound = errors.New("object not found")
+
+// objectStore reads objects from a repository's loose objects and packfiles,
+// including those of any alternates. Packs are opened on first use, and must
+// be closed when done.
+type objectStore struct {
+	dirs     []string // objects directories, the repository's own first
+	hashSize int
+	packs    []*pack
+	opened   bool
+}
+
+// pack is a packfile and its index
+type pack struct {
+	idx  []byte
+	file *os.File
+}
+
+func (r *Repo) objects() *objectStore {
+	dir := filepath.Join(r.CommonDir, "objects")
+	s := &objectStore{dirs: []string{dir}, hashSize: r.hashSize()}
+
+	// alternates can have alternates of their own, but that's rare enough
+	// to not bother with
+	if data, err := os.ReadFile(filepath.Join(dir, "info", "alternates")); err == nil {
+		for _, line := range strings.Split(string(data), "\n") {
+			line = strings.TrimSpace(line)
+			if line == "" || line[0] == '#' {
+				continue
+			}
+			if !filepath.IsAbs(line) {
+				line = filepath.Join(dir, line)
+			}
+			s.dirs = append(s.dirs, filepath.Clean(line))
+		}
+	}
+	return s
+}
+
+// hashSize is the size in bytes of the repository's object IDs
+func (r *Repo) hashSize() int {
+	if readConfig(filepath.Join(r.CommonDir, "config"))["extensions.objectformat"] == "sha256" {
+		return 32
+	}
+	return 20
+}
+
+func (s *objectStore) close() {
+	for _, p := range s.packs {
+		p.file.Close()
+	}
+}
+
+// read returns the type and contents of an object
+func (s *objectStore) read(oid string) (int, []byte, error) {
+	return s.readDepth(oid, 0)
+}
+
+func (s *objectStore) readDepth(oid string, depth int) (int, []byte, error) {
+	raw, err := hex.DecodeString(oid)
+	if err != nil || len(raw) != s.hashSize {
+		return 0, nil, fmt.Errorf("invalid object id %q", oid)
+	}
+
+	for _, dir := range s.dirs {
+		typ, data, err := readLoose(filepath.Join(dir, oid[0:2], oid[2:]))
+		if !os.IsNotExist(err) {
+			return typ, data, err
+		}
+	}
+
+	if !s.opened {
+		s.openPacks()
+	}
+	for _, p := range s.packs {
+		if offset, ok := p.find(raw, s.hashSize); ok {
+			return s.readPacked(p, offset, depth)
+		}
+	}
+	return 0, nil, fmt.Errorf("%w: %s", errObjectNotFound, oid)
+}
+
+// readLoose reads a zlib-compressed loose object, which is a header of its
+// type and size followed by its contents.
+func readLoose(path string) (int, []byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer f.Close()
+
+	zr, err := zlib.NewReader(bufio.NewReader(f))
+	if err != nil {
+		return 0, nil, err
+	}
+	defer zr.Close()
+	data, err := io.ReadAll(zr)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	header, contents, found := bytes.Cut(data, []byte{0})
+	name, size, _ := strings.Cut(string(header), " ")
+	typ, known := objectTypes[name]
+	if !found || !known || size != strconv.Itoa(len(contents)) {
+		return 0, nil, fmt.Errorf("invalid loose object %s", path)
+	}
+	return typ, contents, nil
+}
+
+func (s *objectStore) openPacks() {
+	s.opened = true
+	for _, dir := range s.dirs {
+		indexes, _ := filepath.Glob(filepath.Join(dir, "pack", "*.idx"))
+		for _, path := range indexes {
+			idx, err := os.ReadFile(path)
+			// only version 2 indexes are supported, which git has written by
+			// default since 1.5.2
+			if err != nil || len(idx) < 8+256*4 || !bytes.Equal(idx[0:8], []byte("\xfftOc\x00\x00\x00\x02")) {
+				continue
+			}
+			f, err := os.Open(strings.TrimSuffix(path, ".idx") + ".pack")
+			if err != nil {
+				continue
+			}
+			s.packs = append(s.packs, &pack{idx: idx, file: f})
+		}
+	}
+}
+
+// find looks up an object's offset in the pack. The index has a fanout table
+// of counts by first byte, then the sorted object IDs, their CRCs, and their
+// offsets, with large offsets in a table of their own.
+func (p *pack) find(oid []byte, hashSize int) (int64, bool) {
+	fanout := func(i int) int { return int(binary.BigEndian.Uint32(p.idx[8+i*4:])) }
+	count := fanout(255)
+	names := 8 + 256*4
+	offsets := names + count*(hashSize+4)
+	if len(p.idx) < offsets+count*4 {
+		return 0, false
+	}
+
+	lo := 0
+	if oid[0] > 0 {
+		lo = fanout(int(oid[0]) - 1)
+	}
+	hi := fanout(int(oid[0]))
+	i := lo + sort.Search(hi-lo, func(i int) bool {
+		return bytes.Compare(p.idx[names+(lo+i)*hashSize:names+(lo+i+1)*hashSize], oid) >= 0
+	})
+	if i >= hi || !bytes.Equal(p.idx[names+i*hashSize:names+(i+1)*hashSize], oid) {
+		return 0, false
+	}
+
+	offset := binary.BigEndian.Uint32(p.idx[offsets+i*4:])
+	if offset&0x80000000 == 0 {
+		return int64(offset), true
+	}
+	large := offsets + count*4 + int(offset&0x7fffffff)*8
+	if len(p.idx) < large+8 {
+		return 0, false
+	}
+	return int64(binary.BigEndian.Uint64(p.idx[large:])), true
+}
+
+// readPacked reads an object from a pack, resolving deltas against their
+// bases, which are either earlier in the same pack or given by object ID.
+func (s *objectStore) readPacked(p *pack, offset int64, depth int) (int, []byte, error) {
+	if depth > maxDeltaDepth {
+		return 0, nil, errors.New("delta chain too deep")
+	}
+
+	r := bufio.NewReader(io.NewSectionReader(p.file, offset, 1<<62))
+	c, err := r.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+	typ := int(c>>4) & 7
+	size := int64(c & 0x0f)
+	for shift := 4; c&0x80 != 0; shift += 7 {
+		if c, err = r.ReadByte(); err != nil {
+			return 0, nil, err
+		}
+		size |= int64(c&0x7f) << shift
+	}
+
+	switch typ {
+	case objCommit, objTree, objBlob, objTag:
+		data, err := inflate(r, size)
+		return typ, data, err
+
+	case objOfsDelta:
+		// the base's offset is relative to this object
+		if c, err = r.ReadByte(); err != nil {
+			return 0, nil, err
+		}
+		rel := int64(c & 0x7f)
+		for c&0x80 != 0 {
+			if c, err = r.ReadByte(); err != nil {
+				return 0, nil, err
+			}
+			rel = ((rel + 1) << 7) | int64(c&0x7f)
+		}
+		delta, err := inflate(r, size)
+		if err != nil {
+			return 0, nil, err
+		}
+		baseType, base, err := s.readPacked(p, offset-rel, depth+1)
+		if err != nil {
+			return 0, nil, err
+		}
+		data, err := applyDelta(base, delta)
+		return baseType, data, err
+
+	case objRefDelta:
+		oid := make([]byte, s.hashSize)
+		if _, err := io.ReadFull(r, oid); err != nil {
+			return 0, nil, err
+		}
+		delta, err := inflate(r, size)
+		if err != nil {
+			return 0, nil, err
+		}
+		baseType, base, err := s.readDepth(hex.EncodeToString(oid), depth+1)
+		if err != nil {
+			return 0, nil, err
+		}
+		data, err := applyDelta(base, delta)
+		return baseType, data, err
+	}
+
+	return 0, nil, fmt.Errorf("invalid object type %d in pack", typ)
+}
+
+func inflate(r io.Reader, size int64) ([]byte, error) {
+	zr, err := zlib.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	data := make([]byte, size)
+	_, err = io.ReadFull(zr, data)
+	return data, err
+}
+
+var errInvalidDelta = errors.New("invalid delta")
+
+// applyDelta rebuilds an object from its base and a delta, which is the size of
+// the base and the result, followed by instructions to either copy a range from
+// the base or insert new data.
+func applyDelta(base, delta []byte) ([]byte, error) {
+	baseSize, delta := deltaSize(delta)
+	size, delta := deltaSize(delta)
+	if baseSize != len(base) {
+		return nil, errInvalidDelta
+	}
+
+	out := make([]byte, 0, size)
+	for len(delta) > 0 {
+		cmd := delta[0]
+		delta = delta[1:]
+		switch {
+		case cmd&0x80 != 0:
+			var offset, n int
+			for i := 0; i < 7; i++ {
+				if cmd&(1<<i) == 0 {
+					continue
+				}
+				if len(delta) == 0 {
+					return nil, errInvalidDelta
+				}
+				if i < 4 {
+					offset |= int(delta[0]) << (8 * i)
+				} else {
+					n |= int(delta[0]) << (8 * (i - 4))
+				}
+				delta = delta[1:]
+			}
+			if n == 0 {
+				n = 0x10000
+			}
+			if offset+n > len(base) {
+				return nil, errInvalidDelta
+			}
+			out = append(out, base[offset:offset+n]...)
+		case cmd != 0:
+			if int(cmd) > len(delta) {
+				return nil, errInvalidDelta
+			}
+			out = append(out, delta[:cmd]...)
+			delta = delta[cmd:]
+		default:
+			return nil, errInvalidDelta
+		}
+	}
+
+	if len(out) != size {
+		return nil, errInvalidDelta
+	}
+	return out, nil
+}
+
+// deltaSize decodes a little-endian variable-length size from the start of a
+// delta, returning it and the rest of the delta.
+func deltaSize(delta []byte) (int, []byte) {
+	size := 0
+	for shift := 0; len(delta) > 0; shift += 7 {
+		c := delta[0]
+		delta = delta[1:]
+		size |= int(c&0x7f) << shift
+		if c&0x80 == 0 {
+			break
+		}
+	}
+	return size, delta
+}
+
+// commit is the part of a commit object needed to walk history
+type commit struct {
+	tree    string
+	parents []string
+	time    int64 // the committer timestamp
+}
+
+func (s *objectStore) commit(oid string) (commit, error) {
+	typ, data, err := s.read(oid)
+	if err != nil {
+		return commit{}, err
+	}
+	if typ != objCommit {
+		return commit{}, fmt.Errorf("%s is not a commit", oid)
+	}
+
+	var c commit
+	header, _, _ := bytes.Cut(data, []byte("\n\n"))
+	for _, line := range strings.Split(string(header), "\n") {
+		key, value, _ := strings.Cut(line, " ")
+		switch key {
+		case "tree":
+			c.tree = value
+		case "parent":
+			c.parents = append(c.parents, value)
+		case "committer":
+			// name <email> timestamp timezone
+			fields := strings.Fields(value)
+			if len(fields) >= 2 {
+				c.time, _ = strconv.ParseInt(fields[len(fields)-2], 10, 64)
+			}
+		}
+	}
+	if c.tree == "" {
+		return commit{}, fmt.Errorf("invalid commit %s", oid)
+	}
+	return c, nil
+}
+
+// treeEntry is an entry in a tree object
+type treeEntry struct {
+	name string
+	mode uint32
+	oid  string
+}
+
+// tree reads a tree object, whose entries are each an octal mode and a name
+// separated by a space, then a NUL and the raw object ID.
+func (s *objectStore) tree(oid string) ([]treeEntry, error) {
+	typ, data, err := s.read(oid)
+	if err != nil {
+		return nil, err
+	}
+	if typ != objTree {
+		return nil, fmt.Errorf("%s is not a tree", oid)
+	}
+
+	var entries []treeEntry
+	for len(data) > 0 {
+		space := bytes.IndexByte(data, ' ')
+		nul := bytes.IndexByte(data, 0)
+		if space < 0 || nul < space || nul+1+s.hashSize > len(data) {
+			return nil, fmt.Errorf("invalid tree %s", oid)
+		}
+		mode, err := strconv.ParseUint(string(data[:space]), 8, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid tree %s", oid)
+		}
+		entries = append(entries, treeEntry{
+			name: string(data[space+1 : nul]),
+			mode: uint32(mode),
+			oid:  hex.EncodeToString(data[nul+1 : nul+1+s.hashSize]),
+		})
+		data = data[nul+1+s.hashSize:]
+	}
+	return entries, nil
+}
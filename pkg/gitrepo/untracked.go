@@ -0,0 +1,217 @@
+package gitrepo
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// untracked walks the working directory looking for a file that isn't in the
+// index and isn't ignored. Like git, ignored directories aren't walked at all,
+// empty directories don't count, and a nested repository counts as untracked.
+// This is skipped when status.showUntrackedFiles is "no".
+func (r *Repo) untracked(idx *index, deadline time.Time) (bool, error) {
+	cfg := readConfig(filepath.Join(r.CommonDir, "config"))
+	if strings.EqualFold(cfg["status.showuntrackedfiles"], "no") {
+		return false, nil
+	}
+
+	w := untrackedWalk{
+		root:     r.Dir,
+		tracked:  map[string]bool{},
+		dirs:     map[string]bool{},
+		deadline: deadline,
+	}
+	for _, e := range idx.entries {
+		w.tracked[e.path] = true
+		for dir := path.Dir(strings.TrimSuffix(e.path, "/")); dir != "."; dir = path.Dir(dir) {
+			w.dirs[dir] = true
+		}
+	}
+
+	var rules []ignoreRule
+	rules = append(rules, readIgnoreFile(excludesFile(cfg), "")...)
+	rules = append(rules, readIgnoreFile(filepath.Join(r.CommonDir, "info", "exclude"), "")...)
+	return w.walk("", rules)
+}
+
+type untrackedWalk struct {
+	root     string
+	tracked  map[string]bool // every path in the index
+	dirs     map[string]bool // every directory with something in the index
+	deadline time.Time
+}
+
+// walk looks through a directory, given by its slash-separated path relative to
+// the root, with the ignore rules from its parents.
+func (w *untrackedWalk) walk(dir string, rules []ignoreRule) (bool, error) {
+	if time.Now().After(w.deadline) {
+		return false, ErrDeadline
+	}
+
+	full := filepath.Join(w.root, filepath.FromSlash(dir))
+	rules = append(rules[:len(rules):len(rules)], readIgnoreFile(filepath.Join(full, ".gitignore"), dir)...)
+	entries, err := os.ReadDir(full)
+	if err != nil {
+		return false, err
+	}
+
+	for _, entry := range entries {
+		name := entry.Name()
+		rel := path.Join(dir, name)
+		isDir := entry.IsDir() // symlinks to directories are files to git
+		switch {
+		case name == ".git":
+			continue
+		case w.tracked[rel], isDir && w.tracked[rel+"/"]:
+			continue // files, submodules, and sparse directories in the index
+		case ignored(rules, rel, isDir):
+			continue
+		case !isDir:
+			return true, nil
+		}
+
+		if !w.dirs[rel] {
+			if _, err := os.Stat(filepath.Join(full, name, ".git")); err == nil {
+				return true, nil // a repository that isn't a submodule
+			}
+		}
+		if found, err := w.walk(rel, rules); err != nil || found {
+			return found, err
+		}
+	}
+	return false, nil
+}
+
+// ignoreRule is a pattern from a .gitignore or exclude file
+type ignoreRule struct {
+	base     string   // the directory containing the .gitignore, relative to the root
+	pattern  []string // the pattern split on slashes
+	negate   bool     // the pattern starts with "!", re-including what it matches
+	dirOnly  bool     // the pattern ends with "/", only matching directories
+	anchored bool     // the pattern has a slash, so it matches from base rather than any name
+}
+
+// readIgnoreFile reads the rules in an ignore file, which apply to paths
+// within base. A missing file has no rules.
+func readIgnoreFile(file, base string) []ignoreRule {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil
+	}
+
+	var rules []ignoreRule
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSuffix(line, "\r")
+		if !strings.HasSuffix(line, `\ `) {
+			line = strings.TrimRight(line, " ")
+		}
+		if line == "" || line[0] == '#' {
+			continue
+		}
+
+		rule := ignoreRule{base: base}
+		if line[0] == '!' {
+			rule.negate = true
+			line = line[1:]
+		} else if line[0] == '\\' {
+			line = line[1:] // an escaped leading # or !
+		}
+		if strings.HasSuffix(line, "/") {
+			rule.dirOnly = true
+			line = strings.TrimRight(line, "/")
+		}
+		if strings.Contains(line, "/") {
+			rule.anchored = true
+			line = strings.TrimPrefix(line, "/")
+		}
+		if line == "" {
+			continue
+		}
+		// git's wildmatch negates a character class with ! as well as ^
+		line = strings.ReplaceAll(line, "[!", "[^")
+		rule.pattern = strings.Split(line, "/")
+		rules = append(rules, rule)
+	}
+	return rules
+}
+
+// ignored reports whether a path is ignored. The last rule that matches
+// decides, so rules from deeper .gitignore files take precedence.
+func ignored(rules []ignoreRule, rel string, isDir bool) bool {
+	for i := len(rules) - 1; i >= 0; i-- {
+		if rules[i].match(rel, isDir) {
+			return !rules[i].negate
+		}
+	}
+	return false
+}
+
+func (r ignoreRule) match(rel string, isDir bool) bool {
+	if r.dirOnly && !isDir {
+		return false
+	}
+	if r.base != "" {
+		if !strings.HasPrefix(rel, r.base+"/") {
+			return false
+		}
+		rel = rel[len(r.base)+1:]
+	}
+	if !r.anchored {
+		matched, _ := path.Match(r.pattern[0], path.Base(rel))
+		return matched
+	}
+	return matchSegments(r.pattern, strings.Split(rel, "/"))
+}
+
+// matchSegments matches a path against a pattern segment by segment, where
+// "**" matches any number of directories.
+func matchSegments(pattern, segments []string) bool {
+	if len(pattern) == 0 {
+		return len(segments) == 0
+	}
+	if pattern[0] == "**" {
+		if len(pattern) == 1 {
+			return len(segments) > 0 // a trailing "/**" matches everything inside
+		}
+		for i := 0; i <= len(segments); i++ {
+			if matchSegments(pattern[1:], segments[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(segments) == 0 {
+		return false
+	}
+	matched, _ := path.Match(pattern[0], segments[0])
+	return matched && matchSegments(pattern[1:], segments[1:])
+}
+
+// excludesFile returns the global ignore file: core.excludesFile from the
+// repository's config or the user's, or git's default in the XDG config
+// directory.
+func excludesFile(cfg map[string]string) string {
+	home, _ := os.UserHomeDir()
+	xdg := os.Getenv("XDG_CONFIG_HOME")
+	if xdg == "" {
+		xdg = filepath.Join(home, ".config")
+	}
+
+	file := cfg["core.excludesfile"]
+	if file == "" {
+		file = readConfig(filepath.Join(home, ".gitconfig"))["core.excludesfile"]
+	}
+	if file == "" {
+		file = readConfig(filepath.Join(xdg, "git", "config"))["core.excludesfile"]
+	}
+	if file == "" {
+		return filepath.Join(xdg, "git", "ignore")
+	}
+	if strings.HasPrefix(file, "~/") {
+		file = filepath.Join(home, file[2:])
+	}
+	return file
+}
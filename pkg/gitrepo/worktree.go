@@ -0,0 +1,87 @@
+package gitrepo
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Worktree is a linked worktree of a repository
+type Worktree struct {
+	Name   string // the worktree's name in the git directory
+	Dir    string // the working directory
+	Head   string // the commit HEAD points to
+	Branch string // the current branch, empty if HEAD is detached
+}
+
+// Worktrees lists the linked worktrees of a repository, not including the main
+// working directory. Worktrees whose directories have been removed but not yet
+// pruned are skipped.
+func (r *Repo) Worktrees() ([]Worktree, error) {
+	entries, err := os.ReadDir(filepath.Join(r.CommonDir, "worktrees"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var worktrees []Worktree
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		admin := filepath.Join(r.CommonDir, "worktrees", entry.Name())
+
+		// gitdir holds the path to the worktree's .git file
+		gitFile, err := readLine(filepath.Join(admin, "gitdir"))
+		if err != nil {
+			continue
+		}
+		if !filepath.IsAbs(gitFile) {
+			gitFile = filepath.Join(admin, gitFile)
+		}
+		if _, err := os.Stat(gitFile); err != nil {
+			continue
+		}
+
+		wt := Worktree{Name: entry.Name(), Dir: filepath.Dir(filepath.Clean(gitFile))}
+		head, err := readLine(filepath.Join(admin, "HEAD"))
+		if err != nil {
+			continue
+		}
+		if ref := strings.TrimPrefix(head, "ref: "); ref != head {
+			wt.Branch = strings.TrimPrefix(ref, "refs/heads/")
+			wt.Head = r.resolve(ref)
+		} else {
+			wt.Head = head
+		}
+		worktrees = append(worktrees, wt)
+	}
+
+	sort.Slice(worktrees, func(i, j int) bool { return worktrees[i].Dir < worktrees[j].Dir })
+	return worktrees, nil
+}
+
+// AddWorktree runs git to create a worktree at path with branch checked out.
+//
+// An existing local branch is checked out, or a new branch is created to track
+// origin's branch of the same name. Otherwise a new branch is started at HEAD.
+func (r *Repo) AddWorktree(path, branch string) error {
+	args := []string{"worktree", "add", "--quiet"}
+	if r.resolve("refs/heads/"+branch) != "" || r.resolve("refs/remotes/origin/"+branch) != "" {
+		args = append(args, path, branch)
+	} else {
+		args = append(args, "-b", branch, path)
+	}
+
+	cmd := exec.Command("git", args...)
+	cmd.Dir = r.Dir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("git worktree add %s: %s", path, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
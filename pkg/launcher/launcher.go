@@ -0,0 +1,182 @@
+// Package launcher renders completion results for launchers other than Alfred.
+//
+// Completion produces Alfred script filter results, which are converted to a
+// launcher-neutral model of items and actions before being written out in the
+// format a particular launcher expects.
+package launcher
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/zerowidth/gh-shorthand/pkg/alfred"
+)
+
+// Item is a launcher-neutral result item
+type Item struct {
+	ID           string  // optional unique identifier
+	Title        string  // displayed in the result row
+	Subtitle     string  // optional description
+	Icon         string  // optional icon path, relative to the alfred workflow
+	Action       *Action // what selecting the item does, nil if it's not actionable
+	Autocomplete string  // optional input to replace the query with
+	Mods         []Mod   // alternate actions
+}
+
+// Action is something to do when an item is selected, such as "open" a URL or
+// "edit" a directory.
+type Action struct {
+	Name      string            // the action, e.g. open, paste, edit, term
+	Arg       string            // the URL, text, or path for the action
+	Variables map[string]string // any other variables the action needs
+}
+
+// Mod is an alternate action, selected with a modifier key
+type Mod struct {
+	Key      string // alt, cmd, ctrl, shift, or fn
+	Subtitle string // a description of the action
+	Icon     string
+	Action   *Action
+}
+
+// formatter writes items in a launcher's format
+type formatter func(w io.Writer, items []Item) error
+
+var formats = map[string]formatter{
+	"rofi":    writeRofi,
+	"dmenu":   writeDmenu,
+	"fzf":     writeTSV,
+	"raycast": writeRaycast,
+}
+
+// modKeys are the modifier keys, in the order their mods are listed and given
+// slots in the formats that have a fixed place for each.
+var modKeys = []string{"cmd", "alt", "ctrl", "shift", "fn"}
+
+// Formats lists the names of the supported output formats
+func Formats() []string {
+	names := []string{"alfred"}
+	for name := range formats {
+		names = append(names, name)
+	}
+	sort.Strings(names[1:])
+	return names
+}
+
+// Write renders a completion result in the given format. The alfred format is
+// the result as-is, and the others are converted from it.
+func Write(w io.Writer, format string, result alfred.FilterResult) error {
+	if format == "alfred" {
+		return json.NewEncoder(w).Encode(result)
+	}
+	f, ok := formats[format]
+	if !ok {
+		return fmt.Errorf("unknown format %q, must be one of %s", format, strings.Join(Formats(), ", "))
+	}
+	return f(w, FromAlfred(result))
+}
+
+// FromAlfred converts Alfred result items to launcher-neutral items.
+func FromAlfred(result alfred.FilterResult) []Item {
+	items := make([]Item, 0, len(result.Items))
+	for _, ai := range result.Items {
+		item := Item{
+			ID:           ai.UID,
+			Title:        ai.Title,
+			Subtitle:     ai.Subtitle,
+			Icon:         iconPath(ai.Icon),
+			Autocomplete: ai.Autocomplete,
+		}
+		if ai.Valid {
+			item.Action = action(ai.Arg, ai.Variables)
+		}
+		if ai.Mods != nil {
+			mods := map[string]*alfred.ModItem{
+				"cmd":   ai.Mods.Cmd,
+				"alt":   ai.Mods.Alt,
+				"ctrl":  ai.Mods.Ctrl,
+				"shift": ai.Mods.Shift,
+				"fn":    ai.Mods.Fn,
+			}
+			for _, key := range modKeys {
+				mod := mods[key]
+				if mod == nil || !mod.Valid {
+					continue
+				}
+				item.Mods = append(item.Mods, Mod{
+					Key:      key,
+					Subtitle: mod.Subtitle,
+					Icon:     iconPath(mod.Icon),
+					Action:   action(mod.Arg, mod.Variables),
+				})
+			}
+		}
+		items = append(items, item)
+	}
+	return items
+}
+
+func action(arg string, vars alfred.Variables) *Action {
+	a := &Action{Name: vars["action"], Arg: arg}
+	for k, v := range vars {
+		if k == "action" {
+			continue
+		}
+		if a.Variables == nil {
+			a.Variables = map[string]string{}
+		}
+		a.Variables[k] = v
+	}
+	return a
+}
+
+func iconPath(icon *alfred.Icon) string {
+	if icon == nil {
+		return ""
+	}
+	return icon.Path
+}
+
+// actionFields returns the fields that tell a wrapper script what to do with a
+// selection: the action's name, arg, and URL query encoded variables, which
+// are all empty for no action.
+func actionFields(a *Action) []string {
+	if a == nil {
+		return []string{"", "", ""}
+	}
+	return []string{a.Name, a.Arg, encodeVariables(a.Variables)}
+}
+
+// itemFields returns the action fields for an item's own action, or for
+// autocompleting if that's all it does, followed by the action fields for
+// each of the modKeys in order.
+func itemFields(item Item) []string {
+	fields := actionFields(item.Action)
+	if item.Action == nil && item.Autocomplete != "" {
+		fields = []string{"autocomplete", item.Autocomplete, ""}
+	}
+	for _, key := range modKeys {
+		var a *Action
+		for _, mod := range item.Mods {
+			if mod.Key == key {
+				a = mod.Action
+			}
+		}
+		fields = append(fields, actionFields(a)...)
+	}
+	return fields
+}
+
+// encodeVariables encodes an action's extra variables as a URL query string,
+// which is sorted and free of whitespace.
+func encodeVariables(vars map[string]string) string {
+	values := url.Values{}
+	for k, v := range vars {
+		values.Set(k, v)
+	}
+	return values.Encode()
+}
@@ -0,0 +1,130 @@
+package launcher
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zerowidth/gh-shorthand/pkg/alfred"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+var result = alfred.FilterResult{
+	Items: alfred.Items{
+		{
+			UID:       "gh:zerowidth/gh-shorthand#12",
+			Title:     "Open zerowidth/gh-shorthand#12",
+			Subtitle:  "Fix <b>bold</b> & \"quoted\"\ttitles",
+			Arg:       "https://github.com/zerowidth/gh-shorthand/issues/12",
+			Valid:     true,
+			Icon:      &alfred.Icon{Path: "octicons-issue-opened.png"},
+			Variables: alfred.Variables{"action": "open"},
+			Mods: &alfred.Mods{
+				Cmd: &alfred.ModItem{
+					Valid:     true,
+					Arg:       "[zerowidth/gh-shorthand#12](https://github.com/zerowidth/gh-shorthand/issues/12)",
+					Subtitle:  "Insert Markdown link to zerowidth/gh-shorthand#12",
+					Icon:      &alfred.Icon{Path: "octicons-markdown.png"},
+					Variables: alfred.Variables{"action": "paste"},
+				},
+				Alt: &alfred.ModItem{
+					Valid:     true,
+					Arg:       "zerowidth/gh-shorthand#12",
+					Subtitle:  "Insert issue reference to zerowidth/gh-shorthand#12",
+					Variables: alfred.Variables{"action": "paste"},
+				},
+				Fn: &alfred.ModItem{
+					Valid:     true,
+					Arg:       "https://github.com/zerowidth/gh-shorthand/compare/main",
+					Subtitle:  "Compare main",
+					Variables: alfred.Variables{"action": "open"},
+				},
+				Shift: &alfred.ModItem{Valid: false, Subtitle: "invalid mods are skipped"},
+			},
+		},
+		{
+			UID:       "ghc:zerowidth/dotfiles:~/code",
+			Title:     "Clone zerowidth/dotfiles",
+			Subtitle:  "Clone into ~/code/dotfiles",
+			Arg:       "zerowidth/dotfiles",
+			Valid:     true,
+			Variables: alfred.Variables{"action": "clone", "root": "/home/me/code"},
+		},
+		{
+			Title:        "Open zerowidth/...",
+			Autocomplete: " z/",
+			Icon:         &alfred.Icon{Path: "octicons-repo.png"},
+		},
+		{
+			Title:    "Invalid project directory: ~/nope",
+			Subtitle: "multi-line\nerror",
+			Icon:     &alfred.Icon{Path: "octicons-alert.png"},
+		},
+	},
+}
+
+func TestWrite(t *testing.T) {
+	for _, format := range Formats() {
+		t.Run(format, func(t *testing.T) {
+			var buf bytes.Buffer
+			require.NoError(t, Write(&buf, format, result))
+
+			golden := filepath.Join("testdata", format+".golden")
+			if *update {
+				require.NoError(t, os.WriteFile(golden, buf.Bytes(), 0644))
+			}
+			expected, err := os.ReadFile(golden)
+			require.NoError(t, err)
+			assert.Equal(t, string(expected), buf.String())
+		})
+	}
+}
+
+func TestWriteUnknownFormat(t *testing.T) {
+	err := Write(&bytes.Buffer{}, "xml", result)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "alfred, dmenu, fzf, raycast, rofi")
+	}
+}
+
+func TestFromAlfred(t *testing.T) {
+	items := FromAlfred(result)
+	require.Len(t, items, 4)
+
+	assert.Equal(t, &Action{Name: "open", Arg: "https://github.com/zerowidth/gh-shorthand/issues/12"}, items[0].Action)
+	require.Len(t, items[0].Mods, 3)
+	assert.Equal(t, []string{"cmd", "alt", "fn"},
+		[]string{items[0].Mods[0].Key, items[0].Mods[1].Key, items[0].Mods[2].Key})
+
+	assert.Equal(t, map[string]string{"root": "/home/me/code"}, items[1].Action.Variables)
+	assert.Nil(t, items[2].Action)
+	assert.Equal(t, " z/", items[2].Autocomplete)
+	assert.Nil(t, items[3].Action)
+}
+
+func TestModFields(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, Write(&buf, "fzf", result))
+	line, _, _ := strings.Cut(buf.String(), "\n")
+	columns := strings.Split(line, "\t")
+	require.Len(t, columns, 2+3*(1+len(modKeys)))
+
+	mod := func(key string) []string {
+		for i, k := range modKeys {
+			if k == key {
+				return columns[5+3*i : 8+3*i]
+			}
+		}
+		return nil
+	}
+	assert.Equal(t, []string{"paste", "[zerowidth/gh-shorthand#12](https://github.com/zerowidth/gh-shorthand/issues/12)", ""}, mod("cmd"))
+	assert.Equal(t, []string{"paste", "zerowidth/gh-shorthand#12", ""}, mod("alt"))
+	assert.Equal(t, []string{"", "", ""}, mod("shift"), "invalid mods are empty")
+	assert.Equal(t, []string{"open", "https://github.com/zerowidth/gh-shorthand/compare/main", ""}, mod("fn"))
+}
@@ -0,0 +1,117 @@
+package launcher
+
+import (
+	"encoding/json"
+	"io"
+)
+
+// raycastItem mirrors the properties of a Raycast List.Item, so an extension
+// can render it directly.
+type raycastItem struct {
+	ID       string          `json:"id,omitempty"`
+	Title    string          `json:"title"`
+	Subtitle string          `json:"subtitle,omitempty"`
+	Icon     string          `json:"icon,omitempty"`
+	Actions  []raycastAction `json:"actions"`
+}
+
+// raycastAction is an entry in the item's action panel. Type names the
+// built-in Raycast action that does the same thing, if there is one, otherwise
+// the extension has to handle Action itself.
+type raycastAction struct {
+	Title     string            `json:"title"`
+	Type      string            `json:"type,omitempty"`
+	Action    string            `json:"action"`
+	Value     string            `json:"value"`
+	Variables map[string]string `json:"variables,omitempty"`
+	Shortcut  *raycastShortcut  `json:"shortcut,omitempty"`
+}
+
+type raycastShortcut struct {
+	Modifiers []string `json:"modifiers"`
+	Key       string   `json:"key"`
+}
+
+var raycastTypes = map[string]string{
+	"open":   "Action.OpenInBrowser",
+	"paste":  "Action.Paste",
+	"copy":   "Action.CopyToClipboard",
+	"finder": "Action.ShowInFinder",
+}
+
+// raycastModifiers maps mod keys to Raycast's modifiers, which don't include fn
+var raycastModifiers = map[string]string{
+	"cmd":   "cmd",
+	"alt":   "opt",
+	"ctrl":  "ctrl",
+	"shift": "shift",
+}
+
+var raycastTitles = map[string]string{
+	"open":         "Open in Browser",
+	"paste":        "Paste",
+	"copy":         "Copy to Clipboard",
+	"finder":       "Show in Finder",
+	"edit":         "Open in Editor",
+	"term":         "Open in Terminal",
+	"clone":        "Clone",
+	"worktree":     "Create Worktree",
+	"autocomplete": "Complete",
+}
+
+// writeRaycast writes items as JSON for a Raycast extension. The first action
+// is the primary one, and mods become further actions with a keyboard
+// shortcut where Raycast has a matching modifier. Autocompletion is an "autocomplete" action with the new search
+// text as its value.
+func writeRaycast(w io.Writer, items []Item) error {
+	out := struct {
+		Items []raycastItem `json:"items"`
+	}{Items: []raycastItem{}}
+
+	for _, item := range items {
+		ri := raycastItem{
+			ID:       item.ID,
+			Title:    item.Title,
+			Subtitle: item.Subtitle,
+			Icon:     item.Icon,
+			Actions:  []raycastAction{},
+		}
+
+		switch {
+		case item.Action != nil:
+			ri.Actions = append(ri.Actions, raycastActionFor("", item.Action, nil))
+		case item.Autocomplete != "":
+			ri.Actions = append(ri.Actions, raycastActionFor("", &Action{Name: "autocomplete", Arg: item.Autocomplete}, nil))
+		}
+		for _, mod := range item.Mods {
+			var shortcut *raycastShortcut
+			if modifier, ok := raycastModifiers[mod.Key]; ok {
+				shortcut = &raycastShortcut{Modifiers: []string{modifier}, Key: "return"}
+			}
+			ri.Actions = append(ri.Actions, raycastActionFor(mod.Subtitle, mod.Action, shortcut))
+		}
+
+		out.Items = append(out.Items, ri)
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
+
+func raycastActionFor(title string, action *Action, shortcut *raycastShortcut) raycastAction {
+	if title == "" {
+		title = raycastTitles[action.Name]
+	}
+	if title == "" {
+		title = action.Name
+	}
+	return raycastAction{
+		Title:     title,
+		Type:      raycastTypes[action.Name],
+		Action:    action.Name,
+		Value:     action.Arg,
+		Variables: action.Variables,
+		Shortcut:  shortcut,
+	}
+}
@@ -0,0 +1,53 @@
+package launcher
+
+import (
+	"bufio"
+	"html"
+	"io"
+	"strings"
+)
+
+// writeRofi writes items for rofi's script mode.
+//
+// Rows use pango markup to show the subtitle after the title. Rofi sets
+// ROFI_INFO to the selected row's info when calling the script back, which is
+// the row's action fields and then those of its mods in the order of modKeys,
+// all separated by tabs, as in the tab-separated format. Custom keybindings are
+// enabled, so that kb-custom-1 to kb-custom-5 can choose the cmd, alt, ctrl,
+// shift and fn mods: rofi sets ROFI_RETV to 10 to 14 for those. Items that do
+// nothing can't be selected, and icons aren't included.
+func writeRofi(w io.Writer, items []Item) error {
+	bw := bufio.NewWriter(w)
+	_, _ = bw.WriteString(rofiOption("prompt", "gh"))
+	_, _ = bw.WriteString(rofiOption("markup-rows", "true"))
+	_, _ = bw.WriteString(rofiOption("use-hot-keys", "true"))
+
+	for _, item := range items {
+		row := html.EscapeString(rofiRowReplacer.Replace(item.Title))
+		if item.Subtitle != "" {
+			row += " <small>" + html.EscapeString(rofiRowReplacer.Replace(item.Subtitle)) + "</small>"
+		}
+
+		var options []string
+		if item.Action != nil || item.Autocomplete != "" {
+			options = append(options, "info", rofiValueReplacer.Replace(strings.Join(itemFields(item), "\t")))
+		} else {
+			options = append(options, "nonselectable", "true")
+		}
+
+		_, _ = bw.WriteString(row + "\x00" + strings.Join(options, "\x1f") + "\n")
+	}
+	return bw.Flush()
+}
+
+// rofiOption formats a mode option line, which sets the prompt and such
+func rofiOption(name, value string) string {
+	return "\x00" + name + "\x1f" + value + "\n"
+}
+
+var (
+	// rows end at a newline or a NUL
+	rofiRowReplacer = strings.NewReplacer("\x00", "", "\r\n", " ", "\n", " ", "\r", " ")
+	// option values also can't contain the unit separator between options
+	rofiValueReplacer = strings.NewReplacer("\x00", "", "\x1f", "", "\r\n", " ", "\n", " ", "\r", " ")
+)
@@ -0,0 +1,50 @@
+package launcher
+
+import (
+	"bufio"
+	"io"
+	"strings"
+)
+
+// writeTSV writes one item per line for fzf, as tab-separated columns:
+//
+//	title, subtitle, action, arg, variables
+//
+// followed by the action, arg and variables of each mod in the order of
+// modKeys, which are empty if the item doesn't have that mod. Only the first
+// two are meant to be displayed, e.g. with fzf's `--delimiter '\t' --with-nth
+// 1,2`, and the rest tell a wrapper script what to do with the selection, such
+// as choosing a mod for a key given to fzf's --expect. Items that only
+// autocomplete have the action "autocomplete" with the new input as the arg,
+// and items that do nothing have an empty action. Variables are URL query
+// encoded.
+func writeTSV(w io.Writer, items []Item) error {
+	bw := bufio.NewWriter(w)
+	for _, item := range items {
+		columns := append([]string{item.Title, item.Subtitle}, itemFields(item)...)
+		for i, column := range columns {
+			columns[i] = tsvReplacer.Replace(column)
+		}
+		_, _ = bw.WriteString(strings.Join(columns, "\t") + "\n")
+	}
+	return bw.Flush()
+}
+
+// writeDmenu writes one item per line for dmenu, which displays whole lines:
+// the title, followed by the subtitle if there is one. Since dmenu only prints
+// the selected line, a wrapper script finds what to do with it from the same
+// line of the fzf format.
+func writeDmenu(w io.Writer, items []Item) error {
+	bw := bufio.NewWriter(w)
+	for _, item := range items {
+		line := item.Title
+		if item.Subtitle != "" {
+			line += " — " + item.Subtitle
+		}
+		_, _ = bw.WriteString(tsvReplacer.Replace(line) + "\n")
+	}
+	return bw.Flush()
+}
+
+// tabs and newlines would break the columns, so they're replaced with spaces
+var tsvReplacer = strings.NewReplacer("\t", " ", "\r\n", " ", "\n", " ", "\r", " ")
@@ -0,0 +1,55 @@
+// Package opener opens URLs and paths and copies text to the clipboard using
+// the desktop's own tools, for use outside of Alfred.
+package opener
+
+import (
+	"errors"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// ErrNoClipboard is returned when no clipboard tool is installed
+var ErrNoClipboard = errors.New("no clipboard tool found: install pbcopy, wl-copy, xclip or xsel")
+
+// Open opens a URL in the default browser, or a path in the file manager.
+func Open(target string) error {
+	name := openCommand(runtime.GOOS)
+	return exec.Command(name, target).Run()
+}
+
+// Copy copies text to the clipboard.
+func Copy(text string) error {
+	args := copyCommand(runtime.GOOS, exec.LookPath)
+	if args == nil {
+		return ErrNoClipboard
+	}
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdin = strings.NewReader(text)
+	return cmd.Run()
+}
+
+func openCommand(goos string) string {
+	if goos == "darwin" {
+		return "open"
+	}
+	return "xdg-open"
+}
+
+// copyCommand finds an installed clipboard tool, returning nil if there isn't
+// one.
+func copyCommand(goos string, lookPath func(string) (string, error)) []string {
+	if goos == "darwin" {
+		return []string{"pbcopy"}
+	}
+	for _, args := range [][]string{
+		{"wl-copy"},
+		{"xclip", "-selection", "clipboard"},
+		{"xsel", "--clipboard", "--input"},
+	} {
+		if _, err := lookPath(args[0]); err == nil {
+			return args
+		}
+	}
+	return nil
+}
@@ -0,0 +1,32 @@
+package opener
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOpenCommand(t *testing.T) {
+	assert.Equal(t, "open", openCommand("darwin"))
+	assert.Equal(t, "xdg-open", openCommand("linux"))
+}
+
+func TestCopyCommand(t *testing.T) {
+	installed := func(names ...string) func(string) (string, error) {
+		return func(name string) (string, error) {
+			for _, n := range names {
+				if n == name {
+					return "/usr/bin/" + name, nil
+				}
+			}
+			return "", errors.New("not found")
+		}
+	}
+
+	assert.Equal(t, []string{"pbcopy"}, copyCommand("darwin", installed()))
+	assert.Equal(t, []string{"wl-copy"}, copyCommand("linux", installed("xsel", "wl-copy")))
+	assert.Equal(t, []string{"xclip", "-selection", "clipboard"}, copyCommand("linux", installed("xsel", "xclip")))
+	assert.Equal(t, []string{"xsel", "--clipboard", "--input"}, copyCommand("linux", installed("xsel")))
+	assert.Nil(t, copyCommand("linux", installed()))
+}
@@ -15,6 +15,7 @@ type Parser struct {
 	parseUser    bool // look for users
 	requireIssue bool // require an issue match
 	parseIssue   bool // look for issues (#123, 123)
+	parseCommit  bool // look for commits (@sha)
 	parsePath    bool // look for /path
 	parseQuery   bool // any extra text
 }
@@ -35,9 +36,15 @@ func NewParser(repoMap, userMap map[string]string, defaultRepo string, options .
 	return parser
 }
 
-// NewRepoParser returns a parser for repo/issue/path queries
+// NewRepoParser returns a parser for repo/issue/commit/path queries
 func NewRepoParser(repoMap, userMap map[string]string, defaultRepo string) *Parser {
-	return NewParser(repoMap, userMap, defaultRepo, RequireRepo, WithIssue, WithPath)
+	return NewParser(repoMap, userMap, defaultRepo, RequireRepo, WithIssue, WithCommit, WithPath)
+}
+
+// NewURLParser returns a parser for anything that can be expanded to a URL: a
+// repo with an issue, commit, or path, or a user with a path.
+func NewURLParser(repoMap, userMap map[string]string, defaultRepo string) *Parser {
+	return NewParser(repoMap, userMap, defaultRepo, WithRepo, WithUser, WithIssue, WithCommit, WithPath)
 }
 
 // NewIssueParser returns a parser for issue searches
@@ -85,6 +92,9 @@ func RequireIssue(p *Parser) {
 	p.requireIssue = true
 }
 
+// WithCommit instructs the parser to look for a commit sha, e.g. @abc1234
+func WithCommit(p *Parser) { p.parseCommit = true }
+
 // WithPath instructs the parser to look for a path
 func WithPath(p *Parser) { p.parsePath = true }
 
@@ -156,6 +166,13 @@ func (p *Parser) Parse(input string) *Result {
 		}
 	}
 
+	if p.parseCommit && !res.HasIssue() && res.HasRepo() {
+		if matches := commitRegexp.FindStringSubmatch(input); matches != nil {
+			res.Commit = matches[1]
+			input = input[len(matches[0]):]
+		}
+	}
+
 	if p.requireIssue && !res.HasIssue() {
 		return &Result{}
 	}
@@ -183,5 +200,6 @@ var (
 	userRepoRegexp = regexp.MustCompile(`^([A-Za-z0-9][-A-Za-z0-9]*)/([\w\.\-]*)(\A|\z|\w)`) // user/repo
 	userRegexp     = regexp.MustCompile(`^([A-Za-z0-9][-A-Za-z0-9]*)\b`)                     // user
 	issueRegexp    = regexp.MustCompile(`^ ?#?([1-9]\d*)$`)
+	commitRegexp   = regexp.MustCompile(`^ ?@([0-9a-fA-F]{7,40})$`)
 	pathRegexp     = regexp.MustCompile(`^ ?(/\S*)$`)
 )
@@ -29,6 +29,7 @@ var repoTests = []struct {
 	userShorthand string
 	repoShorthand string
 	issue         string
+	commit        string
 	path          string
 }{
 
@@ -213,11 +214,48 @@ var repoTests = []struct {
 		path:        "/pulls",
 	},
 
+	// commit parsing
+	{
+		test:   "matches a commit",
+		input:  "foo/bar@abc1234",
+		user:   "foo",
+		name:   "bar",
+		commit: "abc1234",
+	},
+	{
+		test:          "matches a commit with expanded repo shorthand",
+		input:         "df@0123456789abcdef0123456789abcdef01234567",
+		user:          "zerowidth",
+		name:          "dotfiles",
+		commit:        "0123456789abcdef0123456789abcdef01234567",
+		repoShorthand: "df",
+	},
+	{
+		test:        "matches a commit with a default repo",
+		input:       "@abc1234",
+		defaultRepo: "foo/bar",
+		user:        "foo",
+		name:        "bar",
+		commit:      "abc1234",
+	},
+	{
+		test:  "does not match a short commit",
+		input: "foo/bar@abc",
+	},
+	{
+		test:  "does not match a commit that isn't hex",
+		input: "foo/bar@main123",
+	},
+
 	// issue and path together
 	{
 		test:  "does not match an issue followed by a path",
 		input: "foo/bar 123/foo",
 	},
+	{
+		test:  "does not match an issue and a commit",
+		input: "foo/bar#123@abc1234",
+	},
 }
 
 // TestRepoParser for testing the default "repo" mode parsing
@@ -232,6 +270,7 @@ func TestRepoParser(t *testing.T) {
 			assert.Equal(t, tc.repoShorthand, result.RepoShorthand, "result.RepoShorthand")
 			assert.Equal(t, tc.userShorthand, result.UserShorthand, "result.UserShorthand")
 			assert.Equal(t, tc.issue, result.Issue, "result.Issue")
+			assert.Equal(t, tc.commit, result.Commit, "result.Commit")
 			assert.Equal(t, tc.path, result.Path, "result.Path")
 		})
 	}
@@ -9,6 +9,7 @@ type Result struct {
 	UserShorthand string
 	RepoShorthand string
 	Issue         string
+	Commit        string
 	Path          string
 	Query         string
 }
@@ -38,6 +39,11 @@ func (r *Result) HasIssue() bool {
 	return len(r.Issue) > 0
 }
 
+// HasCommit checks if the result has a matched commit
+func (r *Result) HasCommit() bool {
+	return len(r.Commit) > 0
+}
+
 // HasPath checks if the result has a matched path
 func (r *Result) HasPath() bool {
 	return len(r.Path) > 0
@@ -65,9 +71,35 @@ func (r *Result) Annotation() string {
 		if r.HasIssue() {
 			annotation += "#" + r.Issue
 		}
+		if r.HasCommit() {
+			annotation += "@" + r.Commit
+		}
 		annotation += ")"
 	} else if len(r.UserShorthand) > 0 {
 		annotation += " (" + r.UserShorthand + ")"
 	}
 	return annotation
 }
+
+// URL returns the GitHub URL for the result: the repo, issue, or commit, along
+// with any path. Without a repo, it's the user with any path. Returns an empty
+// string if there's neither.
+func (r *Result) URL() string {
+	var url string
+	switch {
+	case r.HasRepo():
+		url = "https://github.com/" + r.Repo()
+	case r.HasUser():
+		url = "https://github.com/" + r.User
+	default:
+		return ""
+	}
+
+	if r.HasRepo() && r.HasIssue() {
+		url += "/issues/" + r.Issue
+	}
+	if r.HasRepo() && r.HasCommit() {
+		url += "/commit/" + r.Commit
+	}
+	return url + r.Path
+}
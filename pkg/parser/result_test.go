@@ -20,3 +20,22 @@ func TestSetRepoWithMissingRepo(t *testing.T) {
 	assert.True(t, result.HasUser())
 	assert.Equal(t, "foo", result.User)
 }
+
+func TestURL(t *testing.T) {
+	for input, url := range map[string]string{
+		"df":          "https://github.com/zerowidth/dotfiles",
+		"df 12":       "https://github.com/zerowidth/dotfiles/issues/12",
+		"zw/foo#4":    "https://github.com/zerowidth/foo/issues/4",
+		"#7":          "https://github.com/default/repo/issues/7",
+		"7":           "https://github.com/default/repo/issues/7",
+		"df@abc1234":  "https://github.com/zerowidth/dotfiles/commit/abc1234",
+		"df /pulls":   "https://github.com/zerowidth/dotfiles/pulls",
+		"/pulls":      "https://github.com/default/repo/pulls",
+		"zw":          "https://github.com/zerowidth",
+		"zw /stars":   "https://github.com/zerowidth/stars",
+		"df 12 extra": "",
+	} {
+		result := NewURLParser(repoMap, userMap, "default/repo").Parse(input)
+		assert.Equal(t, url, result.URL(), input)
+	}
+}
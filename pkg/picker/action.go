@@ -0,0 +1,93 @@
+package picker
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+
+	homedir "github.com/mitchellh/go-homedir"
+	"github.com/zerowidth/gh-shorthand/pkg/completion"
+	"github.com/zerowidth/gh-shorthand/pkg/config"
+	"github.com/zerowidth/gh-shorthand/pkg/opener"
+)
+
+// Perform carries out a choice the way the Alfred workflow would: URLs and
+// directories are opened with the desktop's tools, text to paste is copied to
+// the clipboard, and editors and shells are run in the terminal.
+//
+// Messages and text that can't be copied are written to out.
+func (c Choice) Perform(cfg config.Config, out io.Writer) error {
+	switch c.Action {
+	case "open", "finder":
+		return opener.Open(c.Arg)
+
+	case "paste", "copy":
+		if err := opener.Copy(c.Arg); err != nil {
+			fmt.Fprintln(out, c.Arg)
+			return nil
+		}
+		fmt.Fprintf(out, "copied %s\n", c.Arg)
+		return nil
+
+	case "edit":
+		return edit(cfg, c.Arg)
+
+	case "term":
+		return shell(c.Arg)
+
+	case "clone":
+		path, err := completion.CloneProject(cfg, c.Arg, c.Variables["root"], "")
+		if err != nil {
+			return err
+		}
+		return edit(cfg, path)
+
+	case "worktree":
+		path, err := completion.AddWorktree(c.Arg, c.Variables["branch"])
+		if err != nil {
+			return err
+		}
+		return edit(cfg, path)
+	}
+
+	return fmt.Errorf("unknown action %q for %s", c.Action, c.Arg)
+}
+
+// edit runs the configured editor script with $path set, like the workflow.
+func edit(cfg config.Config, path string) error {
+	script, err := cfg.OpenEditorScript()
+	if err != nil {
+		return err
+	}
+	path, err = homedir.Expand(path)
+	if err != nil {
+		return err
+	}
+	cmd := exec.Command("bash", "-c", script)
+	cmd.Env = append(os.Environ(), "path="+path)
+	return run(cmd)
+}
+
+// shell starts an interactive shell in a directory, in place of opening a new
+// terminal window.
+func shell(dir string) error {
+	sh := os.Getenv("SHELL")
+	if sh == "" {
+		sh = "/bin/sh"
+	}
+	dir, err := homedir.Expand(dir)
+	if err != nil {
+		return err
+	}
+	cmd := exec.Command(sh)
+	cmd.Dir = dir
+	return run(cmd)
+}
+
+func run(cmd *exec.Cmd) error {
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
@@ -0,0 +1,109 @@
+package picker
+
+import (
+	"bufio"
+	"unicode/utf8"
+)
+
+type key int
+
+const (
+	keyRune key = iota
+	keyEnter
+	keyAltEnter
+	keyTab
+	keyBackspace
+	keyUp
+	keyDown
+	keyEscape
+	keyCtrlC
+	keyCtrlD
+	keyCtrlF
+	keyCtrlN
+	keyCtrlO
+	keyCtrlP
+	keyCtrlT
+	keyCtrlU
+	keyCtrlW
+	keyCtrlY
+	keyUnknown
+)
+
+// keyPress is a key read from the terminal, with the character typed if it's
+// keyRune
+type keyPress struct {
+	key  key
+	char rune
+}
+
+var controlKeys = map[byte]key{
+	'\r':   keyEnter,
+	'\n':   keyEnter,
+	'\t':   keyTab,
+	0x7f:   keyBackspace,
+	0x08:   keyBackspace,
+	0x03:   keyCtrlC,
+	0x04:   keyCtrlD,
+	0x06:   keyCtrlF,
+	0x0e:   keyCtrlN,
+	0x0f:   keyCtrlO,
+	0x10:   keyCtrlP,
+	0x14:   keyCtrlT,
+	0x15:   keyCtrlU,
+	0x17:   keyCtrlW,
+	0x19:   keyCtrlY,
+	'\x1b': keyEscape,
+}
+
+// readKey reads a single key press from a terminal in raw mode.
+//
+// Escape sequences for the arrow keys and alt-enter arrive all at once, so an
+// escape with nothing buffered after it is the escape key on its own.
+func readKey(r *bufio.Reader) (keyPress, error) {
+	b, err := r.ReadByte()
+	if err != nil {
+		return keyPress{}, err
+	}
+
+	if b == '\x1b' && r.Buffered() > 0 {
+		next, _ := r.ReadByte()
+		switch next {
+		case '\r', '\n':
+			return keyPress{key: keyAltEnter}, nil
+		case '[', 'O':
+			final, _ := r.ReadByte()
+			// skip any parameters of longer sequences, e.g. ctrl-up
+			for final >= '0' && final <= '9' || final == ';' {
+				if final, err = r.ReadByte(); err != nil {
+					return keyPress{}, err
+				}
+			}
+			switch final {
+			case 'A':
+				return keyPress{key: keyUp}, nil
+			case 'B':
+				return keyPress{key: keyDown}, nil
+			}
+		}
+		return keyPress{key: keyUnknown}, nil
+	}
+
+	if k, ok := controlKeys[b]; ok {
+		return keyPress{key: k}, nil
+	}
+	if b < 0x20 {
+		return keyPress{key: keyUnknown}, nil
+	}
+
+	if b < utf8.RuneSelf {
+		return keyPress{key: keyRune, char: rune(b)}, nil
+	}
+	if err := r.UnreadByte(); err != nil {
+		return keyPress{}, err
+	}
+	char, _, err := r.ReadRune()
+	if err != nil {
+		return keyPress{}, err
+	}
+	return keyPress{key: keyRune, char: char}, nil
+}
@@ -0,0 +1,269 @@
+// Package picker is an interactive terminal frontend for completion, for use
+// outside of Alfred.
+//
+// It runs the completion on every change to the input, and takes care of
+// re-running it for RPC results the way Alfred would otherwise do by
+// re-invoking the script filter.
+package picker
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/zerowidth/gh-shorthand/pkg/alfred"
+	"github.com/zerowidth/gh-shorthand/pkg/completion"
+	"golang.org/x/term"
+)
+
+// CompleteFunc runs the completion for an environment
+type CompleteFunc func(completion.Environment) alfred.FilterResult
+
+// Choice is the action chosen for an item or one of its mods
+type Choice struct {
+	Action    string
+	Arg       string
+	Variables alfred.Variables
+}
+
+// mods are the mods that can be chosen, and the keys to choose them. Cmd, shift
+// and fn have no equivalent in a terminal, so ctrl-o, ctrl-y and ctrl-f stand
+// in for them.
+var mods = []struct {
+	key  key
+	name string
+	mod  func(*alfred.Mods) *alfred.ModItem
+}{
+	{keyAltEnter, "alt-enter", func(m *alfred.Mods) *alfred.ModItem { return m.Alt }},
+	{keyCtrlO, "ctrl-o", func(m *alfred.Mods) *alfred.ModItem { return m.Cmd }},
+	{keyCtrlT, "ctrl-t", func(m *alfred.Mods) *alfred.ModItem { return m.Ctrl }},
+	{keyCtrlY, "ctrl-y", func(m *alfred.Mods) *alfred.ModItem { return m.Shift }},
+	{keyCtrlF, "ctrl-f", func(m *alfred.Mods) *alfred.ModItem { return m.Fn }},
+}
+
+// picker holds the state of the input and the completion results
+type picker struct {
+	complete CompleteFunc
+	query    string
+	result   alfred.FilterResult
+	selected int
+}
+
+func newPicker(complete CompleteFunc, query string) *picker {
+	p := &picker{complete: complete}
+	p.setQuery(query)
+	return p
+}
+
+// setQuery changes the input, starting the completion over with a new
+// environment.
+func (p *picker) setQuery(query string) {
+	p.query = query
+	p.selected = 0
+	p.result = p.complete(completion.EnvironmentFromVariables(query, nil))
+}
+
+// rerun runs the completion again for the same input, passing on the result's
+// variables so RPC requests continue where they left off.
+func (p *picker) rerun() {
+	var vars alfred.Variables
+	if p.result.Variables != nil {
+		vars = *p.result.Variables
+	}
+	p.result = p.complete(completion.EnvironmentFromVariables(p.query, vars))
+	if p.selected >= len(p.result.Items) {
+		p.selected = 0
+	}
+}
+
+// handle a key press, returning true when the picker is done, along with the
+// choice made, if any.
+func (p *picker) handle(k keyPress) (*Choice, bool) {
+	switch k.key {
+	case keyRune:
+		p.setQuery(p.query + string(k.char))
+	case keyBackspace:
+		if len(p.query) > 0 {
+			_, size := utf8.DecodeLastRuneInString(p.query)
+			p.setQuery(p.query[:len(p.query)-size])
+		}
+	case keyCtrlU:
+		p.setQuery("")
+	case keyCtrlW:
+		trimmed := strings.TrimRight(p.query, " ")
+		p.setQuery(trimmed[:strings.LastIndex(trimmed, " ")+1])
+	case keyUp, keyCtrlP:
+		if p.selected > 0 {
+			p.selected--
+		}
+	case keyDown, keyCtrlN:
+		if p.selected < len(p.result.Items)-1 {
+			p.selected++
+		}
+	case keyTab:
+		if item, ok := p.current(); ok && item.Autocomplete != "" {
+			p.setQuery(item.Autocomplete)
+		}
+	case keyEnter:
+		item, ok := p.current()
+		if !ok {
+			break
+		}
+		if item.Valid {
+			return &Choice{Action: item.Variables["action"], Arg: item.Arg, Variables: item.Variables}, true
+		}
+		if item.Autocomplete != "" {
+			p.setQuery(item.Autocomplete)
+		}
+	case keyEscape, keyCtrlC:
+		return nil, true
+	case keyCtrlD:
+		if p.query == "" {
+			return nil, true
+		}
+	default:
+		for _, m := range mods {
+			if m.key != k.key {
+				continue
+			}
+			if item, ok := p.current(); ok && item.Mods != nil {
+				if mod := m.mod(item.Mods); mod != nil && mod.Valid {
+					return &Choice{Action: mod.Variables["action"], Arg: mod.Arg, Variables: mod.Variables}, true
+				}
+			}
+		}
+	}
+	return nil, false
+}
+
+func (p *picker) current() (alfred.Item, bool) {
+	if p.selected < len(p.result.Items) {
+		return p.result.Items[p.selected], true
+	}
+	return alfred.Item{}, false
+}
+
+// render draws the input and results to fit in a terminal of the given size,
+// followed by the mods available for the selected item.
+func (p *picker) render(w io.Writer, width, height int) {
+	var lines []string
+	lines = append(lines, "> "+p.query)
+
+	var footer []string
+	if item, ok := p.current(); ok && item.Mods != nil {
+		for _, m := range mods {
+			if mod := m.mod(item.Mods); mod != nil && mod.Valid {
+				footer = append(footer, "\x1b[2m"+truncate(m.name+": "+mod.Subtitle, width)+"\x1b[0m")
+			}
+		}
+	}
+
+	// keep the selection in view
+	visible := height - len(lines) - len(footer) - 1
+	if visible < 1 {
+		visible = 1
+	}
+	offset := 0
+	if p.selected >= visible {
+		offset = p.selected - visible + 1
+	}
+
+	for i := offset; i < len(p.result.Items) && i < offset+visible; i++ {
+		item := p.result.Items[i]
+		line := truncate("  "+item.Title, width)
+		if rest := width - utf8.RuneCountInString(line) - 3; item.Subtitle != "" && rest > 0 {
+			line += "\x1b[2m — " + truncate(item.Subtitle, rest) + "\x1b[0m"
+		}
+		if i == p.selected {
+			line = "\x1b[7m>" + strings.TrimPrefix(line, " ") + "\x1b[0m"
+		}
+		lines = append(lines, line)
+	}
+	if len(p.result.Items) == 0 {
+		lines = append(lines, "\x1b[2m  no results\x1b[0m")
+	}
+
+	if len(footer) > 0 {
+		lines = append(lines, "")
+		lines = append(lines, footer...)
+	}
+
+	// clear the screen, draw, and put the cursor back at the end of the input
+	fmt.Fprint(w, "\x1b[H\x1b[J"+strings.Join(lines, "\r\n"))
+	fmt.Fprintf(w, "\x1b[1;%dH", utf8.RuneCountInString(p.query)+3)
+}
+
+func truncate(s string, width int) string {
+	if width <= 0 {
+		return ""
+	}
+	if utf8.RuneCountInString(s) <= width {
+		return s
+	}
+	runes := []rune(s)
+	return string(runes[:width-1]) + "…"
+}
+
+// Run shows the picker on the terminal, starting with an initial query, and
+// returns the choice made. Returns nil if nothing was chosen.
+func Run(complete CompleteFunc, query string) (*Choice, error) {
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer tty.Close()
+
+	fd := int(tty.Fd())
+	state, err := term.MakeRaw(fd)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = term.Restore(fd, state) }()
+
+	// use the alternate screen, so the terminal is left as it was
+	fmt.Fprint(tty, "\x1b[?1049h")
+	defer fmt.Fprint(tty, "\x1b[?1049l")
+
+	keys := make(chan keyPress)
+	errs := make(chan error, 1)
+	go func() {
+		r := bufio.NewReader(tty)
+		for {
+			k, err := readKey(r)
+			if err != nil {
+				errs <- err
+				return
+			}
+			keys <- k
+		}
+	}()
+
+	p := newPicker(complete, query)
+	for {
+		width, height, err := term.GetSize(fd)
+		if err != nil {
+			width, height = 80, 24
+		}
+		p.render(tty, width, height)
+
+		var rerun <-chan time.Time
+		if p.result.Rerun > 0 {
+			rerun = time.After(time.Duration(p.result.Rerun * float64(time.Second)))
+		}
+
+		select {
+		case k := <-keys:
+			if choice, done := p.handle(k); done {
+				return choice, nil
+			}
+		case <-rerun:
+			p.rerun()
+		case err := <-errs:
+			return nil, err
+		}
+	}
+}
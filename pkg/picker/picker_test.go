@@ -0,0 +1,190 @@
+package picker
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zerowidth/gh-shorthand/pkg/alfred"
+	"github.com/zerowidth/gh-shorthand/pkg/completion"
+	"github.com/zerowidth/gh-shorthand/pkg/config"
+)
+
+func TestReadKey(t *testing.T) {
+	r := bufio.NewReader(strings.NewReader("aé\r\x1b\r\t\x7f\x1b[A\x1bOB\x1b[1;5A\x0f\x14\x19\x06\x03"))
+	expected := []keyPress{
+		{key: keyRune, char: 'a'},
+		{key: keyRune, char: 'é'},
+		{key: keyEnter},
+		{key: keyAltEnter},
+		{key: keyTab},
+		{key: keyBackspace},
+		{key: keyUp},
+		{key: keyDown},
+		{key: keyUp},
+		{key: keyCtrlO},
+		{key: keyCtrlT},
+		{key: keyCtrlY},
+		{key: keyCtrlF},
+		{key: keyCtrlC},
+	}
+	for _, e := range expected {
+		k, err := readKey(r)
+		require.NoError(t, err)
+		assert.Equal(t, e, k)
+	}
+
+	r = bufio.NewReader(strings.NewReader("\x1b"))
+	k, err := readKey(r)
+	require.NoError(t, err)
+	assert.Equal(t, keyEscape, k.key, "escape on its own")
+}
+
+// fakeComplete records the environments it's called with and returns items
+// based on the query.
+type fakeComplete struct {
+	envs []completion.Environment
+}
+
+func (f *fakeComplete) complete(env completion.Environment) alfred.FilterResult {
+	f.envs = append(f.envs, env)
+	result := alfred.NewFilterResult()
+	switch env.Query {
+	case "":
+		result.AppendItems(alfred.Item{Title: "Open repositories", Autocomplete: " "})
+	case " ", " z":
+		result.AppendItems(
+			alfred.Item{
+				Title:     "Open zerowidth/gh-shorthand",
+				Arg:       "https://github.com/zerowidth/gh-shorthand",
+				Valid:     true,
+				Variables: alfred.Variables{"action": "open"},
+				Mods: &alfred.Mods{
+					Cmd: &alfred.ModItem{
+						Valid:     true,
+						Arg:       "[zerowidth/gh-shorthand](https://github.com/zerowidth/gh-shorthand)",
+						Subtitle:  "Insert Markdown link",
+						Variables: alfred.Variables{"action": "paste"},
+					},
+					Shift: &alfred.ModItem{
+						Valid:     true,
+						Arg:       "https://github.com/zerowidth/gh-shorthand",
+						Subtitle:  "Copy URL",
+						Variables: alfred.Variables{"action": "copy"},
+					},
+				},
+			},
+			alfred.Item{Title: "Open zerowidth/...", Autocomplete: " z/"},
+		)
+		// pretend an RPC request is pending
+		result.SetVariable("query", env.Query)
+		result.SetVariable("s", "1000")
+		result.SetVariable("ns", "0")
+		result.Rerun = 0.1
+	}
+	return result
+}
+
+func TestPickerHandle(t *testing.T) {
+	f := &fakeComplete{}
+	p := newPicker(f.complete, "")
+	require.Len(t, p.result.Items, 1)
+
+	_, done := p.handle(keyPress{key: keyTab})
+	assert.False(t, done)
+	assert.Equal(t, " ", p.query, "autocompleted")
+	require.Len(t, p.result.Items, 2)
+
+	p.handle(keyPress{key: keyRune, char: 'z'})
+	assert.Equal(t, " z", p.query)
+	p.handle(keyPress{key: keyDown})
+	p.handle(keyPress{key: keyDown})
+	assert.Equal(t, 1, p.selected, "selection stops at the last item")
+
+	_, done = p.handle(keyPress{key: keyEnter})
+	assert.False(t, done, "enter on an invalid item autocompletes")
+	assert.Equal(t, " z/", p.query)
+
+	p.handle(keyPress{key: keyCtrlW})
+	assert.Equal(t, " ", p.query, "deletes the last word")
+	p.setQuery(" z/")
+	p.handle(keyPress{key: keyBackspace})
+	assert.Equal(t, " z", p.query)
+
+	choice, done := p.handle(keyPress{key: keyEnter})
+	assert.True(t, done)
+	assert.Equal(t, &Choice{
+		Action:    "open",
+		Arg:       "https://github.com/zerowidth/gh-shorthand",
+		Variables: alfred.Variables{"action": "open"},
+	}, choice)
+
+	choice, done = p.handle(keyPress{key: keyCtrlO})
+	assert.True(t, done)
+	assert.Equal(t, "paste", choice.Action)
+
+	choice, done = p.handle(keyPress{key: keyCtrlY})
+	assert.True(t, done)
+	assert.Equal(t, "copy", choice.Action)
+
+	choice, done = p.handle(keyPress{key: keyAltEnter})
+	assert.False(t, done, "no alt mod")
+	assert.Nil(t, choice)
+
+	choice, done = p.handle(keyPress{key: keyCtrlF})
+	assert.False(t, done, "no fn mod")
+	assert.Nil(t, choice)
+
+	choice, done = p.handle(keyPress{key: keyEscape})
+	assert.True(t, done)
+	assert.Nil(t, choice)
+}
+
+func TestPickerRerun(t *testing.T) {
+	f := &fakeComplete{}
+	p := newPicker(f.complete, " z")
+	assert.WithinDuration(t, time.Now(), f.envs[0].Start, time.Second, "new query starts now")
+
+	p.rerun()
+	require.Len(t, f.envs, 2)
+	assert.Equal(t, " z", f.envs[1].Query)
+	assert.Equal(t, time.Unix(1000, 0), f.envs[1].Start, "start time from the result's variables")
+}
+
+func TestPickerRender(t *testing.T) {
+	f := &fakeComplete{}
+	p := newPicker(f.complete, " z")
+
+	var buf bytes.Buffer
+	p.render(&buf, 20, 10)
+	out := buf.String()
+	assert.Contains(t, out, ">  z\r\n")
+	assert.Contains(t, out, "\x1b[7m> Open zerowidth/gh…")
+	assert.Contains(t, out, "ctrl-o: Insert Mark…")
+	assert.Contains(t, out, "ctrl-y: Copy URL")
+	assert.NotContains(t, out, "alt-enter")
+	assert.NotContains(t, out, "ctrl-f")
+}
+
+func TestPerformEdit(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "edited")
+	cfg := config.Config{EditorScript: `echo "$path" > ` + out}
+
+	choice := Choice{Action: "edit", Arg: "/tmp/project"}
+	require.NoError(t, choice.Perform(cfg, &bytes.Buffer{}))
+	data, err := os.ReadFile(out)
+	require.NoError(t, err)
+	assert.Equal(t, "/tmp/project\n", string(data))
+
+	err = Choice{Action: "edit", Arg: "/tmp/project"}.Perform(config.Config{}, &bytes.Buffer{})
+	assert.Error(t, err, "no editor configured")
+
+	err = Choice{Action: "launch", Arg: "rockets"}.Perform(cfg, &bytes.Buffer{})
+	assert.Error(t, err)
+}
@@ -0,0 +1,212 @@
+package rpc
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/shurcooL/githubv4"
+)
+
+// The actions that can be looked up in a batch
+var batchActions = map[string]bool{
+	"repo":    true,
+	"issue":   true,
+	"project": true,
+}
+
+// maximum number of lookups in a single GraphQL query
+const maxBatchSize = 50
+
+// BatchKey returns the key for a lookup in a batch, such as
+// "issue:owner/name#123", from an action and its query. These match the keys
+// used to cache the results of the /repo, /issue and /project endpoints.
+func BatchKey(action, query string) string {
+	return action + ":" + query
+}
+
+// splitBatchKey splits a batch key into its action and query
+func splitBatchKey(key string) (string, string, error) {
+	action, query, ok := strings.Cut(key, ":")
+	if !ok || len(query) == 0 {
+		return "", "", fmt.Errorf("incomplete batch key action:query: %v", key)
+	}
+	if !batchActions[action] {
+		return "", "", fmt.Errorf("unsupported batch action: %v", action)
+	}
+	return action, query, nil
+}
+
+// GetBatch looks up many repos, issues and projects at once, given their batch
+// keys, using a single GraphQL query with an alias for each key.
+func (g *GitHubClient) GetBatch(ctx context.Context, keys []string) map[string]Result {
+	results := make(map[string]Result, len(keys))
+	for start := 0; start < len(keys); start += maxBatchSize {
+		end := start + maxBatchSize
+		if end > len(keys) {
+			end = len(keys)
+		}
+		g.getBatch(ctx, keys[start:end], results)
+	}
+	return results
+}
+
+func (g *GitHubClient) getBatch(ctx context.Context, keys []string, results map[string]Result) {
+	var b batchQuery
+	for _, key := range keys {
+		action, query, err := splitBatchKey(key)
+		if err == nil {
+			err = b.add(key, action, query)
+		}
+		if err != nil {
+			results[key] = Result{Error: err.Error()}
+		}
+	}
+	if len(b.items) == 0 {
+		return
+	}
+
+	q := reflect.New(reflect.StructOf(b.fields))
+	err := g.query(ctx, q.Interface(), b.vars)
+
+	// GraphQL errors don't say which alias they're for, so an error is only
+	// given for a key when it's the only one that couldn't be resolved, or when
+	// the whole query failed.
+	var resolved, unresolved []batchItem
+	for i, item := range b.items {
+		var res Result
+		if item.decode(q.Elem().Field(i), &res) {
+			results[item.key] = res
+			resolved = append(resolved, item)
+		} else {
+			unresolved = append(unresolved, item)
+		}
+	}
+	for _, item := range unresolved {
+		msg := fmt.Sprintf("could not resolve to %s %s", item.action, item.query)
+		if err != nil && (len(unresolved) == 1 || len(resolved) == 0) {
+			msg = err.Error()
+		}
+		results[item.key] = Result{Error: msg}
+	}
+}
+
+// batchQuery builds a GraphQL query with an aliased field for each lookup in a
+// batch, e.g. `item0: repository(owner: $owner0, name: $name0)`.
+type batchQuery struct {
+	fields []reflect.StructField
+	vars   map[string]interface{}
+	items  []batchItem
+}
+
+type batchItem struct {
+	key    string
+	action string
+	query  string
+	// decode adds the value of the item's field to a result, returning false
+	// if it couldn't be resolved.
+	decode func(v reflect.Value, res *Result) bool
+}
+
+func (b *batchQuery) add(key, action, query string) error {
+	i := len(b.items)
+	item := batchItem{key: key, action: action, query: query}
+	vars := map[string]interface{}{}
+	var field string
+	var typ reflect.Type
+
+	switch action {
+	case "repo":
+		owner, name, err := splitRepo(query)
+		if err != nil {
+			return err
+		}
+		vars["owner"] = githubv4.String(owner)
+		vars["name"] = githubv4.String(name)
+		field = "repository(owner: $owner%[1]d, name: $name%[1]d)"
+		typ = reflect.TypeOf(&repoFragment{})
+		item.decode = func(v reflect.Value, res *Result) bool {
+			if v.IsNil() {
+				return false
+			}
+			repo := v.Interface().(*repoFragment)
+			res.Repos = append(res.Repos, Repo{Description: repo.Description, Private: repo.IsPrivate})
+			return true
+		}
+
+	case "issue":
+		owner, name, number, err := splitIssue(query)
+		if err != nil {
+			return err
+		}
+		vars["owner"] = githubv4.String(owner)
+		vars["name"] = githubv4.String(name)
+		vars["number"] = githubv4.Int(number)
+		field = "repository(owner: $owner%[1]d, name: $name%[1]d)"
+		typ = nested(fmt.Sprintf("issueOrPullRequest(number: $number%d)", i),
+			reflect.TypeOf(&issueOrPullRequest{}))
+		item.decode = func(v reflect.Value, res *Result) bool {
+			if v.IsNil() || v.Elem().Field(0).IsNil() {
+				return false
+			}
+			issue := v.Elem().Field(0).Interface().(*issueOrPullRequest)
+			res.Issues = append(res.Issues, issue.toIssue())
+			return true
+		}
+
+	case "project":
+		user, repo, number, err := splitProject(query)
+		if err != nil {
+			return err
+		}
+		if len(repo) == 0 {
+			vars["login"] = githubv4.String(user)
+			field = "organization(login: $login%[1]d)"
+		} else {
+			vars["owner"] = githubv4.String(user)
+			vars["name"] = githubv4.String(repo)
+			field = "repository(owner: $owner%[1]d, name: $name%[1]d)"
+		}
+		vars["number"] = githubv4.Int(number)
+		typ = nested(fmt.Sprintf("project(number: $number%d)", i),
+			reflect.TypeOf(&projectFragment{}))
+		item.decode = func(v reflect.Value, res *Result) bool {
+			if v.IsNil() || v.Elem().Field(0).IsNil() {
+				return false
+			}
+			project := v.Elem().Field(0).Interface().(*projectFragment)
+			res.Projects = append(res.Projects, project.toProject())
+			return true
+		}
+	}
+
+	if b.vars == nil {
+		b.vars = map[string]interface{}{}
+	}
+	for name, value := range vars {
+		b.vars[fmt.Sprintf("%s%d", name, i)] = value
+	}
+	b.fields = append(b.fields, reflect.StructField{
+		Name: fmt.Sprintf("Item%d", i),
+		Type: typ,
+		Tag:  reflect.StructTag(fmt.Sprintf(`graphql:"item%d: %s"`, i, fmt.Sprintf(field, i))),
+	})
+	b.items = append(b.items, item)
+	return nil
+}
+
+// nested returns a pointer to a struct type with a single field, for an
+// object whose field takes arguments specific to one item in a batch.
+func nested(tag string, typ reflect.Type) reflect.Type {
+	return reflect.PtrTo(reflect.StructOf([]reflect.StructField{{
+		Name: "Field",
+		Type: typ,
+		Tag:  reflect.StructTag(fmt.Sprintf(`graphql:"%s"`, tag)),
+	}}))
+}
+
+type repoFragment struct {
+	Description string
+	IsPrivate   bool
+}
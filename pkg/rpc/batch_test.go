@@ -0,0 +1,81 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/shurcooL/githubv4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// graphqlServer returns a GitHub client for a fake GraphQL API that responds
+// with the given body, recording the request it was sent.
+func graphqlServer(t *testing.T, body string, request *graphqlRequest) *GitHubClient {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, json.NewDecoder(r.Body).Decode(request))
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(body))
+	}))
+	t.Cleanup(srv.Close)
+	return &GitHubClient{client: githubv4.NewEnterpriseClient(srv.URL, srv.Client())}
+}
+
+type graphqlRequest struct {
+	Query     string                 `json:"query"`
+	Variables map[string]interface{} `json:"variables"`
+}
+
+func TestGetBatch(t *testing.T) {
+	var req graphqlRequest
+	g := graphqlServer(t, `{"data": {
+		"item0": {"description": "dotfiles"},
+		"item1": {"issueOrPullRequest": {"__typename": "PullRequest",
+			"state": "MERGED", "title": "a fix", "number": 2,
+			"author": {"login": "zw"}, "labels": {"nodes": [{"name": "bug"}]},
+			"repository": {"name": "df", "owner": {"login": "zw"}}}},
+		"item2": {"project": {"number": 3, "name": "roadmap", "state": "OPEN", "url": "https://github.com/orgs/zw/projects/3"}}
+	}}`, &req)
+
+	results := g.GetBatch(context.Background(), []string{"repo:zw/df", "issue:zw/df#2", "project:zw/3", "issue:zw/df", "user:zw"})
+
+	assert.Contains(t, req.Query, "item0: repository(owner: $owner0, name: $name0){description,isPrivate}")
+	assert.Contains(t, req.Query, "item1: repository(owner: $owner1, name: $name1){issueOrPullRequest(number: $number1)")
+	assert.Contains(t, req.Query, "item2: organization(login: $login2){project(number: $number2)")
+	assert.Equal(t, "zw", req.Variables["owner1"])
+	assert.Equal(t, float64(2), req.Variables["number1"])
+
+	assert.Equal(t, Result{Repos: []Repo{{Description: "dotfiles"}}}, results["repo:zw/df"])
+	assert.Equal(t, Result{Issues: []Issue{{
+		Type: "PullRequest", State: "MERGED", Title: "a fix", Repo: "zw/df",
+		Number: "2", Author: "zw", Labels: []string{"bug"},
+	}}}, results["issue:zw/df#2"])
+	assert.Equal(t, Result{Projects: []Project{{
+		Number: 3, Name: "roadmap", State: "OPEN", URL: "https://github.com/orgs/zw/projects/3",
+	}}}, results["project:zw/3"])
+	assert.Equal(t, "incomplete issue owner/name#issue: zw/df", results["issue:zw/df"].Error)
+	assert.Equal(t, "unsupported batch action: user", results["user:zw"].Error)
+}
+
+func TestGetBatchErrors(t *testing.T) {
+	var req graphqlRequest
+	g := graphqlServer(t, `{"data": {"item0": {"description": "dotfiles"}, "item1": null},
+		"errors": [{"message": "Could not resolve to a Repository with the name 'zw/nope'."}]}`, &req)
+	results := g.GetBatch(context.Background(), []string{"repo:zw/df", "repo:zw/nope"})
+	assert.Empty(t, results["repo:zw/df"].Error)
+	assert.Equal(t, "Could not resolve to a Repository with the name 'zw/nope'.", results["repo:zw/nope"].Error)
+
+	g = graphqlServer(t, `{"data": {"item0": null, "item1": {"issueOrPullRequest": null}, "item2": {"description": "x"}},
+		"errors": [{"message": "Could not resolve to a Repository with the name 'zw/nope'."}]}`, &req)
+	results = g.GetBatch(context.Background(), []string{"repo:zw/nope", "issue:zw/df#99", "repo:zw/df"})
+	assert.Equal(t, "could not resolve to repo zw/nope", results["repo:zw/nope"].Error)
+	assert.Equal(t, "could not resolve to issue zw/df#99", results["issue:zw/df#99"].Error)
+
+	g = graphqlServer(t, `{"data": null, "errors": [{"message": "Bad credentials"}]}`, &req)
+	results = g.GetBatch(context.Background(), []string{"repo:zw/df", "repo:zw/foo"})
+	assert.Equal(t, "Bad credentials", results["repo:zw/df"].Error)
+	assert.Equal(t, "Bad credentials", results["repo:zw/foo"].Error)
+}
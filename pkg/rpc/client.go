@@ -3,30 +3,52 @@ package rpc
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"io"
 	"net"
 	"net/http"
 	"net/url"
 	"time"
+
+	"github.com/zerowidth/gh-shorthand/pkg/config"
 )
 
 // Client represents an RPC client interface
 type Client interface {
 	Query(endpoint, query string) Result
+	// Batch looks up many repos, issues and projects at once, given their batch
+	// keys. The results are by key, and each is complete on its own.
+	Batch(keys []string) map[string]Result
 }
 
-// SocketClient is a client that talks to a local socket
-type SocketClient struct {
-	socketPath string
+// AddrClient is a client that talks to the RPC server over a unix socket, or
+// over TCP with a shared secret.
+type AddrClient struct {
+	network string
+	address string
+	secret  string
 }
 
-// NewClient creates a new Client from a config
-func NewClient(socketPath string) SocketClient {
-	return SocketClient{
-		socketPath: socketPath,
+// NewClient creates a new Client from a config. It connects to server_addr
+// over TCP if that's set, and otherwise to the local socket if RPC is enabled.
+func NewClient(cfg config.Config) AddrClient {
+	switch {
+	case len(cfg.ServerAddr) > 0:
+		return AddrClient{network: "tcp", address: cfg.ServerAddr, secret: cfg.RPCSecret}
+	case cfg.RPCEnabled():
+		return AddrClient{network: "unix", address: cfg.SocketPath}
+	default:
+		return AddrClient{}
 	}
 }
 
+// errNotFound is the error for an endpoint the server doesn't have
+var errNotFound = errors.New("RPC service error: 404 Not Found")
+
+// SecretHeader is the request header carrying the shared secret for TCP
+// connections
+const SecretHeader = "X-Gh-Shorthand-Secret"
+
 // How long to wait before giving up on the backend
 const socketTimeout = 250 * time.Millisecond
 
@@ -34,18 +56,69 @@ const socketTimeout = 250 * time.Millisecond
 //
 // Returns a Result if the RPC call completed successfully, regardless of
 // whether the ultimate value is ready or not.
-func (sc SocketClient) Query(endpoint, query string) Result {
+func (c AddrClient) Query(endpoint, query string) Result {
 	var res Result
 
-	if len(sc.socketPath) == 0 {
+	if len(c.address) == 0 {
 		return Result{Complete: true} // RPC isn't enabled, don't worry about it
 	}
 
+	v := url.Values{}
+	v.Set("q", query)
+	if err := c.get(endpoint, v, &res); err != nil {
+		res.Error = err.Error()
+		res.Complete = true
+	}
+
+	return res
+}
+
+// Batch executes a batch of lookups against the RPC server. If the RPC call
+// itself fails, every key's result is the error.
+func (c AddrClient) Batch(keys []string) map[string]Result {
+	results := make(map[string]Result, len(keys))
+
+	if len(c.address) == 0 {
+		for _, key := range keys {
+			results[key] = Result{Complete: true} // RPC isn't enabled
+		}
+		return results
+	}
+
+	v := url.Values{"q": keys}
+	if err := c.get("/batch", v, &results); err != nil {
+		results = make(map[string]Result, len(keys))
+		for _, key := range keys {
+			results[key] = Result{Complete: true, Error: err.Error()}
+		}
+	}
+
+	return results
+}
+
+// Version asks the RPC server which version of the protocol it speaks. A
+// server from before versioning doesn't know, and reports version 0.
+func (c AddrClient) Version() (Version, error) {
+	var v Version
+
+	if len(c.address) == 0 {
+		return v, errors.New("RPC isn't enabled")
+	}
+
+	err := c.get("/version", url.Values{}, &v)
+	if err == errNotFound {
+		return Version{}, nil
+	}
+	return v, err
+}
+
+// get requests an endpoint from the RPC server, decoding the JSON response
+func (c AddrClient) get(endpoint string, v url.Values, out interface{}) error {
 	httpClient := http.Client{
 		Transport: &http.Transport{
 			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
 				d := net.Dialer{}
-				return d.DialContext(ctx, "unix", sc.socketPath)
+				return d.DialContext(ctx, c.network, c.address)
 			},
 		},
 		Timeout: socketTimeout,
@@ -53,39 +126,36 @@ func (sc SocketClient) Query(endpoint, query string) Result {
 
 	u, err := url.Parse("http://gh-shorthand" + endpoint)
 	if err != nil {
-		res.Complete = true
-		res.Error = "url parsing error: " + err.Error()
-		return res
+		return errors.New("url parsing error: " + err.Error())
 	}
-	v := url.Values{}
-	v.Set("q", query)
 	u.RawQuery = v.Encode()
 
-	resp, err := httpClient.Get(u.String())
+	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
 	if err != nil {
-		res.Error = "RPC service error: " + err.Error()
-		res.Complete = true
-		return res
+		return errors.New("url parsing error: " + err.Error())
 	}
-	if resp.StatusCode >= 400 {
-		res.Error = "RPC service error: " + resp.Status
-		res.Complete = true
-		return res
+	if len(c.secret) > 0 {
+		req.Header.Set(SecretHeader, c.secret)
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return errors.New("RPC service error: " + err.Error())
 	}
 	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return errNotFound
+	}
+	if resp.StatusCode >= 400 {
+		return errors.New("RPC service error: " + resp.Status)
+	}
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		res.Error = "RPC response error: " + err.Error()
-		res.Complete = true
-		return res
+		return errors.New("RPC response error: " + err.Error())
 	}
-	err = json.Unmarshal(body, &res)
-	if err != nil {
-		res.Error = "unmarshal error: " + err.Error()
-		res.Complete = true
-		return res
+	if err := json.Unmarshal(body, out); err != nil {
+		return errors.New("unmarshal error: " + err.Error())
 	}
-
-	return res
+	return nil
 }
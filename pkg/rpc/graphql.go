@@ -31,7 +31,7 @@ func NewGitHubClient(cfg config.Config) *GitHubClient {
 }
 
 // GetRepo retrieves a repo's information
-func (g *GitHubClient) GetRepo(res *Result, repo string) error {
+func (g *GitHubClient) GetRepo(ctx context.Context, res *Result, repo string) error {
 	owner, name, err := splitRepo(repo)
 	if err != nil {
 		return err
@@ -39,23 +39,25 @@ func (g *GitHubClient) GetRepo(res *Result, repo string) error {
 	var query struct {
 		Repository struct {
 			Description string
+			IsPrivate   bool
 		} `graphql:"repository(owner: $owner, name: $name)"`
 	}
 	vars := map[string]interface{}{
 		"owner": githubv4.String(owner),
 		"name":  githubv4.String(name),
 	}
-	err = g.query(&query, vars)
+	err = g.query(ctx, &query, vars)
 
 	var r Repo
 	r.Description = query.Repository.Description
+	r.Private = query.Repository.IsPrivate
 	res.Repos = append(res.Repos, r)
 
 	return err
 }
 
 // GetIssue retrieves an issue's information
-func (g *GitHubClient) GetIssue(res *Result, issue string) error {
+func (g *GitHubClient) GetIssue(ctx context.Context, res *Result, issue string) error {
 	owner, name, number, err := splitIssue(issue)
 	if err != nil {
 		return err
@@ -70,13 +72,13 @@ func (g *GitHubClient) GetIssue(res *Result, issue string) error {
 		"name":   githubv4.String(name),
 		"number": githubv4.Int(number),
 	}
-	err = g.query(&query, vars)
+	err = g.query(ctx, &query, vars)
 	res.Issues = append(res.Issues, query.Repository.IssueOrPullRequest.toIssue())
 	return err
 }
 
 // GetIssues retrieves issues from the search API given a query
-func (g *GitHubClient) GetIssues(res *Result, query string) error {
+func (g *GitHubClient) GetIssues(ctx context.Context, res *Result, query string) error {
 	var search struct {
 		Search struct {
 			Nodes []issueOrPullRequest
@@ -85,26 +87,104 @@ func (g *GitHubClient) GetIssues(res *Result, query string) error {
 	vars := map[string]interface{}{
 		"query": githubv4.String(query),
 	}
-	err := g.query(&search, vars)
+	err := g.query(ctx, &search, vars)
 	for _, n := range search.Search.Nodes {
 		res.Issues = append(res.Issues, n.toIssue())
 	}
 	return err
 }
 
+// maximum number of pages of commits to look through for merged PRs
+const maxComparePages = 5
+
+// comparePageSize is the number of commits in each page of a comparison
+const comparePageSize = 100
+
+// GetMergedPullRequests retrieves the merged PRs for the commits in a range of
+// a repo, given as "owner/name base...head". Ranges with more commits than
+// can be looked through are an error rather than a partial list of PRs.
+func (g *GitHubClient) GetMergedPullRequests(ctx context.Context, res *Result, query string) error {
+	repo, refs, _ := strings.Cut(query, " ")
+	owner, name, err := splitRepo(repo)
+	if err != nil {
+		return err
+	}
+	base, head, ok := strings.Cut(refs, "...")
+	if !ok || len(base) == 0 || len(head) == 0 {
+		return fmt.Errorf("incomplete range base...head: %v", refs)
+	}
+
+	var q struct {
+		Repository struct {
+			Ref *struct {
+				Compare struct {
+					Commits struct {
+						Nodes []struct {
+							AssociatedPullRequests struct {
+								Nodes []issueFragment
+							} `graphql:"associatedPullRequests(first: 5)"`
+						}
+						PageInfo struct {
+							EndCursor   githubv4.String
+							HasNextPage bool
+						}
+					} `graphql:"commits(first: $first, after: $after)"`
+				} `graphql:"compare(headRef: $head)"`
+			} `graphql:"ref(qualifiedName: $base)"`
+		} `graphql:"repository(owner: $owner, name: $name)"`
+	}
+	vars := map[string]interface{}{
+		"owner": githubv4.String(owner),
+		"name":  githubv4.String(name),
+		"base":  githubv4.String(base),
+		"head":  githubv4.String(head),
+		"first": githubv4.Int(comparePageSize),
+		"after": (*githubv4.String)(nil),
+	}
+
+	seen := map[int]bool{}
+	for page := 0; page < maxComparePages; page++ {
+		if err := g.query(ctx, &q, vars); err != nil {
+			return err
+		}
+		if q.Repository.Ref == nil {
+			return fmt.Errorf("could not resolve to a ref named %q", base)
+		}
+		commits := q.Repository.Ref.Compare.Commits
+		for _, commit := range commits.Nodes {
+			for _, pr := range commit.AssociatedPullRequests.Nodes {
+				// PRs from forks and unmerged PRs can also include the commit
+				if pr.State != "MERGED" || seen[pr.Number] ||
+					!strings.EqualFold(pr.Repository.Owner.Login+"/"+pr.Repository.Name, repo) {
+					continue
+				}
+				seen[pr.Number] = true
+				res.Issues = append(res.Issues, pr.toIssue("PullRequest"))
+			}
+		}
+		if !commits.PageInfo.HasNextPage {
+			return nil
+		}
+		vars["after"] = githubv4.NewString(commits.PageInfo.EndCursor)
+	}
+
+	return fmt.Errorf("%s has more than %d commits, try a smaller range",
+		refs, maxComparePages*comparePageSize)
+}
+
 // GetProject retrieves a project for either an org or a repo
-func (g *GitHubClient) GetProject(res *Result, query string) error {
+func (g *GitHubClient) GetProject(ctx context.Context, res *Result, query string) error {
 	user, repo, number, err := splitProject(query)
 	if err != nil {
 		return err
 	}
 	if len(repo) == 0 {
-		return g.getOrgProject(res, user, number)
+		return g.getOrgProject(ctx, res, user, number)
 	}
-	return g.getRepoProject(res, user, repo, number)
+	return g.getRepoProject(ctx, res, user, repo, number)
 }
 
-func (g *GitHubClient) getOrgProject(res *Result, org string, number int) error {
+func (g *GitHubClient) getOrgProject(ctx context.Context, res *Result, org string, number int) error {
 	var q struct {
 		Organization struct {
 			Project projectFragment `graphql:"project(number:$number)"`
@@ -114,12 +194,12 @@ func (g *GitHubClient) getOrgProject(res *Result, org string, number int) error
 		"login":  githubv4.String(org),
 		"number": githubv4.Int(number),
 	}
-	err := g.query(&q, vars)
+	err := g.query(ctx, &q, vars)
 	res.Projects = append(res.Projects, q.Organization.Project.toProject())
 	return err
 }
 
-func (g *GitHubClient) getRepoProject(res *Result, owner, name string, number int) error {
+func (g *GitHubClient) getRepoProject(ctx context.Context, res *Result, owner, name string, number int) error {
 	var q struct {
 		Repository struct {
 			Project projectFragment `graphql:"project(number:$number)"`
@@ -130,7 +210,7 @@ func (g *GitHubClient) getRepoProject(res *Result, owner, name string, number in
 		"name":   githubv4.String(name),
 		"number": githubv4.Int(number),
 	}
-	err := g.query(&q, vars)
+	err := g.query(ctx, &q, vars)
 	if q.Repository.Project.Number == 0 {
 		return fmt.Errorf("could not resolve to a project with the number %d", number)
 	}
@@ -139,15 +219,15 @@ func (g *GitHubClient) getRepoProject(res *Result, owner, name string, number in
 }
 
 // GetProjects retrieves a list of projects
-func (g *GitHubClient) GetProjects(res *Result, query string) error {
+func (g *GitHubClient) GetProjects(ctx context.Context, res *Result, query string) error {
 	split := strings.SplitN(query, "/", 2)
 	if len(split) == 1 {
-		return g.getOrgProjects(res, split[0])
+		return g.getOrgProjects(ctx, res, split[0])
 	}
-	return g.getRepoProjects(res, split[0], split[1])
+	return g.getRepoProjects(ctx, res, split[0], split[1])
 }
 
-func (g *GitHubClient) getOrgProjects(res *Result, org string) error {
+func (g *GitHubClient) getOrgProjects(ctx context.Context, res *Result, org string) error {
 	var q struct {
 		Organization struct {
 			Projects struct {
@@ -158,14 +238,14 @@ func (g *GitHubClient) getOrgProjects(res *Result, org string) error {
 	vars := map[string]interface{}{
 		"login": githubv4.String(org),
 	}
-	err := g.query(&q, vars)
+	err := g.query(ctx, &q, vars)
 	for _, project := range q.Organization.Projects.Nodes {
 		res.Projects = append(res.Projects, project.toProject())
 	}
 	return err
 }
 
-func (g *GitHubClient) getRepoProjects(res *Result, owner, name string) error {
+func (g *GitHubClient) getRepoProjects(ctx context.Context, res *Result, owner, name string) error {
 	var q struct {
 		Repository struct {
 			Projects struct {
@@ -177,7 +257,7 @@ func (g *GitHubClient) getRepoProjects(res *Result, owner, name string) error {
 		"owner": githubv4.String(owner),
 		"name":  githubv4.String(name),
 	}
-	err := g.query(&q, vars)
+	err := g.query(ctx, &q, vars)
 	for _, project := range q.Repository.Projects.Nodes {
 		res.Projects = append(res.Projects, project.toProject())
 	}
@@ -185,19 +265,29 @@ func (g *GitHubClient) getRepoProjects(res *Result, owner, name string) error {
 }
 
 // wrap query with a timeout
-func (g *GitHubClient) query(q interface{}, vars map[string]interface{}) error {
-	ctx, cancel := context.WithTimeout(context.Background(), graphqlTimeout)
+func (g *GitHubClient) query(ctx context.Context, q interface{}, vars map[string]interface{}) error {
+	ctx, cancel := context.WithTimeout(ctx, graphqlTimeout)
 	defer cancel()
 	return g.client.Query(ctx, q, vars)
 }
 
 type issueFragment struct {
-	State      string
-	Title      string
-	Number     int
+	State  string
+	Title  string
+	Number int
+	Author struct {
+		Login string
+	}
+	Labels struct {
+		Nodes []struct {
+			Name string
+		}
+	} `graphql:"labels(first: 20)"`
 	Repository struct {
-		Name  string
-		Owner struct {
+		Name        string
+		Description string
+		IsPrivate   bool
+		Owner       struct {
 			Login string
 		}
 	}
@@ -239,6 +329,12 @@ func (f issueFragment) toIssue(t string) Issue {
 	i.Title = f.Title
 	i.Repo = fmt.Sprintf("%s/%s", f.Repository.Owner.Login, f.Repository.Name)
 	i.Number = fmt.Sprintf("%d", f.Number)
+	i.Author = f.Author.Login
+	i.RepoDescription = f.Repository.Description
+	i.RepoPrivate = f.Repository.IsPrivate
+	for _, label := range f.Labels.Nodes {
+		i.Labels = append(i.Labels, label.Name)
+	}
 	return i
 }
 
@@ -0,0 +1,36 @@
+package rpc
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/shurcooL/githubv4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetMergedPullRequestsTooManyCommits(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		// every page has one merged PR, and there's always another page
+		fmt.Fprintf(w, `{"data": {"repository": {"ref": {"compare": {"commits": {
+			"nodes": [{"associatedPullRequests": {"nodes": [{
+				"number": %d, "state": "MERGED",
+				"repository": {"name": "gh-shorthand", "owner": {"login": "zerowidth"}}
+			}]}}],
+			"pageInfo": {"endCursor": "cursor%d", "hasNextPage": true}
+		}}}}}}`, requests, requests)
+	}))
+	defer server.Close()
+
+	g := &GitHubClient{client: githubv4.NewEnterpriseClient(server.URL, server.Client())}
+	var res Result
+	err := g.GetMergedPullRequests(context.Background(), &res, "zerowidth/gh-shorthand v1.0...v2.0")
+	if assert.Error(t, err) {
+		assert.Equal(t, "v1.0...v2.0 has more than 500 commits, try a smaller range", err.Error())
+	}
+	assert.Equal(t, maxComparePages, requests)
+}
@@ -1,52 +1,136 @@
 package rpc
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
+	"log/slog"
 	"net/http"
+	"runtime/debug"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/go-chi/chi"
-	"github.com/kardianos/service"
 	"github.com/patrickmn/go-cache"
 	"github.com/zerowidth/gh-shorthand/pkg/config"
 )
 
 const (
-	resultTTL     = 10 * time.Minute // how long to keep successful results
-	errorTTL      = 10 * time.Second // how long to keep errors
+	resultTTL     = 10 * time.Minute // how long to keep successful results, by default
+	errorTTL      = 10 * time.Second // how long to keep errors, by default
 	sweepInterval = 10 * time.Minute // how often to sweep the cache
+	maxPending    = time.Minute      // how long before a pending request is retried
 )
 
+// backend makes the API calls for the RPC handlers
+type backend interface {
+	GetRepo(ctx context.Context, res *Result, repo string) error
+	GetIssue(ctx context.Context, res *Result, issue string) error
+	GetIssues(ctx context.Context, res *Result, query string) error
+	GetMergedPullRequests(ctx context.Context, res *Result, query string) error
+	GetProject(ctx context.Context, res *Result, query string) error
+	GetProjects(ctx context.Context, res *Result, query string) error
+	GetBatch(ctx context.Context, keys []string) map[string]Result
+}
+
 // Handler is a set of RPC http handlers
 type Handler struct {
 	cache   *cache.Cache
-	github  *GitHubClient
-	logger  service.Logger
+	logger  *slog.Logger
 	m       sync.Mutex
-	pending map[string]struct{}
+	pending map[string]pendingRequest
+	seq     uint64 // the id of the last pending request
+	queue   *requestQueue
+
+	// settings from the config, which can be changed while running
+	github    backend
+	token     string
+	resultTTL time.Duration
+	errorTTL  time.Duration
+
+	maxPending time.Duration
 }
 
-type rpcCall func(result *Result, query string) error
+type rpcCall func(github backend, ctx context.Context, result *Result, query string) error
+
+// pendingRequest is a request that's queued or in flight. Only the result of
+// the current pending request for a key is kept, so a late result from one
+// that was given up on can't replace a newer one.
+type pendingRequest struct {
+	id    uint64
+	since time.Time // when it was queued
+}
 
 // NewHandler creates a new RPC handler with the given config
-func NewHandler(cfg config.Config, lg service.Logger) *Handler {
+func NewHandler(cfg config.Config, logger *slog.Logger) *Handler {
+	h := newHandler(NewGitHubClient(cfg), logger)
+	h.token = cfg.APIToken
+	h.resultTTL, h.errorTTL = cacheTTLs(cfg)
+	return h
+}
+
+func newHandler(github backend, logger *slog.Logger) *Handler {
 	handler := Handler{
-		cache:   cache.New(resultTTL, sweepInterval),
-		pending: make(map[string]struct{}),
-		github:  NewGitHubClient(cfg),
-		logger:  lg,
+		cache:      cache.New(resultTTL, sweepInterval),
+		pending:    make(map[string]pendingRequest),
+		github:     github,
+		logger:     logger,
+		queue:      newRequestQueue(maxConcurrentRequests, maxPending, logger),
+		resultTTL:  resultTTL,
+		errorTTL:   errorTTL,
+		maxPending: maxPending,
 	}
 	return &handler
 }
 
+// Reconfigure applies a new config to a running handler. Requests from then on
+// use a new API client if the token has changed, and cached results are
+// dropped, since what they show depends on the token. The cache TTLs apply to
+// results from then on.
+func (h *Handler) Reconfigure(cfg config.Config) {
+	resultTTL, errorTTL := cacheTTLs(cfg)
+
+	h.m.Lock()
+	defer h.m.Unlock()
+	if cfg.APIToken != h.token {
+		h.logger.Info("API token changed, clearing the cache")
+		h.github = NewGitHubClient(cfg)
+		h.token = cfg.APIToken
+		h.cache.Flush()
+	}
+	h.resultTTL, h.errorTTL = resultTTL, errorTTL
+}
+
+// cacheTTLs returns the configured cache TTLs, or the defaults
+func cacheTTLs(cfg config.Config) (time.Duration, time.Duration) {
+	results, errs := resultTTL, errorTTL
+	if cfg.CacheTTL > 0 {
+		results = cfg.CacheTTL
+	}
+	if cfg.ErrorTTL > 0 {
+		errs = cfg.ErrorTTL
+	}
+	return results, errs
+}
+
+// backend returns the current API client
+func (h *Handler) backend() backend {
+	h.m.Lock()
+	defer h.m.Unlock()
+	return h.github
+}
+
 // Mount routes the RPC handlers on a mux
 func (h *Handler) Mount(mux *chi.Mux) {
-	mux.Get("/repo", h.rpcHandler("repo", h.github.GetRepo))
-	mux.Get("/issue", h.rpcHandler("issue", h.github.GetIssue))
-	mux.Get("/issues", h.rpcHandler("issues", h.github.GetIssues))
-	mux.Get("/project", h.rpcHandler("project", h.github.GetProject))
-	mux.Get("/projects", h.rpcHandler("projects", h.github.GetProjects))
+	mux.Get("/repo", h.rpcHandler("repo", backend.GetRepo))
+	mux.Get("/issue", h.rpcHandler("issue", backend.GetIssue))
+	mux.Get("/issues", h.rpcHandler("issues", backend.GetIssues))
+	mux.Get("/merged", h.rpcHandler("merged", backend.GetMergedPullRequests))
+	mux.Get("/project", h.rpcHandler("project", backend.GetProject))
+	mux.Get("/projects", h.rpcHandler("projects", backend.GetProjects))
+	mux.Get("/batch", h.batchHandler)
+	mux.Get("/version", h.versionHandler)
 }
 
 // rpcHandler creates an http handler func to wrap a GitHub API call with
@@ -64,46 +148,303 @@ func (h *Handler) rpcHandler(action string, rpc rpcCall) http.HandlerFunc {
 		}
 
 		// Now that basic checks are done, lock the cache and pending map to see
-		// if the request is already in flight. If not, kick it off.
+		// if the request is already in flight. If not, queue it up.
+		start := time.Now()
 		h.m.Lock()
 		defer h.m.Unlock()
 		var res Result
 
-		key := action + ":" + query
-		if _, pending := h.pending[key]; !pending {
+		key := BatchKey(action, query)
+		status := "pending"
+		if !h.isPending(key) {
 			if cr, ok := h.cache.Get(key); ok {
 				res = cr.(Result)
+				status = "hit"
 			} else {
-				// this will wait on the mutex immediately, but we're returning soon anyway
-				go h.makeRequest(rpc, query, key)
+				status = "miss"
+				id := h.addPending(key)
+				superseded := h.queue.push(key, action, query, func(ctx context.Context) {
+					h.makeRequest(ctx, rpc, query, key, id)
+				})
+				// let these be requested again if they're still wanted
+				for _, old := range superseded {
+					delete(h.pending, old)
+				}
 			}
 		}
 
+		// only a hit has a result to tell whether the query is public
+		level := slog.LevelDebug
+		if status == "hit" {
+			level = slog.LevelInfo
+		}
+		h.logger.Log(context.Background(), level, "RPC request",
+			keyAttr(h.logger, key, status == "hit" && res.public()),
+			slog.String("cache", status),
+			slog.Duration("duration", time.Since(start)))
+
+		res.Version = ProtocolVersion
 		if err := json.NewEncoder(w).Encode(res); err != nil {
-			_ = h.logger.Error("encoding error", err)
+			h.logger.Error("encoding error", "error", err)
 		}
 	}
 }
 
-func (h *Handler) makeRequest(rpc rpcCall, query, key string) {
-	h.m.Lock()
-	h.pending[key] = struct{}{}
-	h.m.Unlock()
+// isPending checks if a request is in flight. A request that's been pending
+// for too long is assumed to be lost, and can be made again. Must be called
+// with the lock held.
+func (h *Handler) isPending(key string) bool {
+	p, pending := h.pending[key]
+	if pending && time.Since(p.since) > h.maxPending {
+		h.logger.Warn("RPC pending too long, retrying", keyAttr(h.logger, key, false),
+			slog.Duration("pending", time.Since(p.since).Round(time.Second)))
+		delete(h.pending, key)
+		return false
+	}
+	return pending
+}
+
+// addPending marks a key as pending, returning the new request's id. Must be
+// called with the lock held.
+func (h *Handler) addPending(key string) uint64 {
+	h.seq++
+	h.pending[key] = pendingRequest{id: h.seq, since: time.Now()}
+	return h.seq
+}
 
+// isCurrent checks if a request is still the pending one for its key. Must be
+// called with the lock held.
+func (h *Handler) isCurrent(key string, id uint64) bool {
+	p, pending := h.pending[key]
+	return pending && p.id == id
+}
+
+func (h *Handler) makeRequest(ctx context.Context, rpc rpcCall, query, key string, id uint64) {
 	var res Result
-	ttl := resultTTL
+	start := time.Now()
+
+	// a request that's cancelled is given up on right away, even if the call
+	// doesn't return until much later
+	stop := context.AfterFunc(ctx, func() { h.abandonRequest(key, id) })
 
-	_ = h.logger.Infof("RPC request: %s", key)
-	err := rpc(&res, query)
-	if err != nil {
+	// whatever happens, record the result and clear the pending request
+	defer func() {
+		abandoned := !stop()
+		if r := recover(); r != nil {
+			h.logger.Error("RPC panic", keyAttr(h.logger, key, false),
+				slog.Any("panic", r), slog.String("stack", string(debug.Stack())))
+			res = Result{Error: fmt.Sprintf("rpc panic: %v", r)}
+		} else if abandoned {
+			return
+		}
+		h.finishRequest(key, id, res, time.Since(start))
+	}()
+
+	h.logger.Debug("RPC call", slog.String("key", key))
+	if err := rpc(h.backend(), ctx, &res, query); err != nil {
 		res.Error = err.Error()
-		ttl = errorTTL
 	}
+}
+
+// abandonRequest clears a request that timed out, without caching its error,
+// so it's made again the next time it's asked for
+func (h *Handler) abandonRequest(key string, id uint64) {
+	h.m.Lock()
+	defer h.m.Unlock()
+	if h.isCurrent(key, id) {
+		delete(h.pending, key)
+	}
+}
+
+// finishRequest caches a result, along with the results derived from it, and
+// clears its pending request. The result is dropped if the request has since
+// been retried.
+func (h *Handler) finishRequest(key string, id uint64, res Result, took time.Duration) {
 	res.Complete = true
-	_ = h.logger.Infof("RPC result: %s %+v\n", key, res)
 
 	h.m.Lock()
+	defer h.m.Unlock()
+	if !h.isCurrent(key, id) {
+		h.logger.Debug("RPC result dropped, the request was retried", slog.String("key", key))
+		return
+	}
+	h.logResult(key, res, took)
+	ttl := h.resultTTL
+	if len(res.Error) > 0 {
+		ttl = h.errorTTL
+	}
 	delete(h.pending, key)
+	h.cacheDerived(key, res)
 	h.cache.Set(key, res, ttl)
-	h.m.Unlock()
+}
+
+// batchHandler looks up many repos, issues and projects at once, given their
+// batch keys as q parameters. Keys that aren't cached or already pending are
+// looked up together in the background, and each key's result is complete
+// once it's in the cache, shared with the single lookup endpoints.
+func (h *Handler) batchHandler(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, http.StatusText(400), 400)
+		return
+	}
+
+	start := time.Now()
+	h.m.Lock()
+	defer h.m.Unlock()
+	results := map[string]Result{}
+	var missing []string
+	ids := map[string]uint64{}
+
+	for _, key := range r.Form["q"] {
+		if _, seen := results[key]; seen || len(key) == 0 {
+			continue
+		}
+		if h.isPending(key) {
+			results[key] = Result{}
+		} else if cr, ok := h.cache.Get(key); ok {
+			results[key] = cr.(Result)
+		} else {
+			// mark these pending now, so the next poll doesn't request them again
+			ids[key] = h.addPending(key)
+			results[key] = Result{}
+			missing = append(missing, key)
+		}
+	}
+
+	if len(missing) > 0 {
+		h.queue.push(BatchKey("batch", strings.Join(missing, " ")), "batch", "", func(ctx context.Context) {
+			h.makeBatchRequest(ctx, missing, ids)
+		})
+	}
+
+	hits := 0
+	for key, res := range results {
+		if res.Complete {
+			hits++
+		}
+		res.Version = ProtocolVersion
+		results[key] = res
+	}
+	h.logger.Info("RPC batch request",
+		slog.Int("hits", hits),
+		slog.Int("misses", len(missing)),
+		slog.Int("pending", len(results)-hits-len(missing)),
+		slog.Duration("duration", time.Since(start)))
+
+	if err := json.NewEncoder(w).Encode(results); err != nil {
+		h.logger.Error("encoding error", "error", err)
+	}
+}
+
+// versionHandler describes the protocol this server speaks, so clients can
+// check what it supports
+func (h *Handler) versionHandler(w http.ResponseWriter, r *http.Request) {
+	if err := json.NewEncoder(w).Encode(CurrentVersion()); err != nil {
+		h.logger.Error("encoding error", "error", err)
+	}
+}
+
+func (h *Handler) makeBatchRequest(ctx context.Context, keys []string, ids map[string]uint64) {
+	var results map[string]Result
+	start := time.Now()
+
+	stop := context.AfterFunc(ctx, func() {
+		for _, key := range keys {
+			h.abandonRequest(key, ids[key])
+		}
+	})
+
+	defer func() {
+		abandoned := !stop()
+		if r := recover(); r != nil {
+			h.logger.Error("RPC panic", keyAttr(h.logger, BatchKey("batch", strings.Join(keys, " ")), false),
+				slog.Any("panic", r), slog.String("stack", string(debug.Stack())))
+			results = map[string]Result{}
+			for _, key := range keys {
+				results[key] = Result{Error: fmt.Sprintf("rpc panic: %v", r)}
+			}
+		} else if abandoned {
+			return
+		}
+		for _, key := range keys {
+			h.finishRequest(key, ids[key], results[key], time.Since(start))
+		}
+	}()
+
+	h.logger.Debug("RPC batch call", slog.Any("keys", keys))
+	results = h.backend().GetBatch(ctx, keys)
+}
+
+// logResult logs the result of an API call, with the full result only at the
+// debug level
+func (h *Handler) logResult(key string, res Result, took time.Duration) {
+	level := slog.LevelInfo
+	public := res.public()
+	attrs := []slog.Attr{
+		keyAttr(h.logger, key, public),
+		slog.Duration("duration", took),
+		slog.Int("repos", len(res.Repos)),
+		slog.Int("issues", len(res.Issues)),
+		slog.Int("projects", len(res.Projects)),
+	}
+	if len(res.Error) > 0 {
+		level = slog.LevelWarn
+		errText := res.Error
+		if redacting(h.logger, public) {
+			errText = hash(errText)
+		}
+		attrs = append(attrs, slog.String("error", errText))
+	}
+	if h.logger.Enabled(context.Background(), slog.LevelDebug) {
+		attrs = append(attrs, slog.String("result", fmt.Sprintf("%+v", res)))
+	}
+	h.logger.LogAttrs(context.Background(), level, "RPC result", attrs...)
+}
+
+// cacheDerived caches the results that can be derived from a successful
+// result, so following lookups of them don't need another API call. Must be
+// called with the lock held.
+func (h *Handler) cacheDerived(key string, res Result) {
+	if len(res.Error) > 0 {
+		return
+	}
+	for derivedKey, derived := range derivedResults(key, res) {
+		h.cache.Set(derivedKey, derived, h.resultTTL)
+	}
+}
+
+// derivedResults returns the results for other keys that are implied by a
+// result: each issue in a search under its issue key, each issue's repo under
+// its repo key, and each project in a list of projects under its project key.
+func derivedResults(key string, res Result) map[string]Result {
+	derived := map[string]Result{}
+	action, query, _ := strings.Cut(key, ":")
+
+	for _, issue := range res.Issues {
+		if len(issue.Repo) == 0 || len(issue.Number) == 0 {
+			continue
+		}
+		if action != "issue" {
+			derived[BatchKey("issue", issue.Repo+"#"+issue.Number)] = Result{
+				Complete: true,
+				Issues:   []Issue{issue},
+			}
+		}
+		derived[BatchKey("repo", issue.Repo)] = Result{
+			Complete: true,
+			Repos:    []Repo{{Description: issue.RepoDescription, Private: issue.RepoPrivate}},
+		}
+	}
+
+	if action == "projects" {
+		for _, project := range res.Projects {
+			derived[BatchKey("project", fmt.Sprintf("%s/%d", query, project.Number))] = Result{
+				Complete: true,
+				Projects: []Project{project},
+			}
+		}
+	}
+
+	delete(derived, key)
+	return derived
 }
@@ -0,0 +1,383 @@
+package rpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zerowidth/gh-shorthand/pkg/config"
+)
+
+func TestDerivedResults(t *testing.T) {
+	issue := Issue{Type: "Issue", State: "OPEN", Title: "a bug", Repo: "zw/df", Number: "1", RepoDescription: "dotfiles"}
+	pr := Issue{Type: "PullRequest", State: "MERGED", Title: "a fix", Repo: "zw/gs", Number: "2"}
+
+	t.Run("search results", func(t *testing.T) {
+		derived := derivedResults("issues:repo:zw/df bug", Result{Complete: true, Issues: []Issue{issue, pr}})
+		assert.Equal(t, map[string]Result{
+			"issue:zw/df#1": {Complete: true, Issues: []Issue{issue}},
+			"issue:zw/gs#2": {Complete: true, Issues: []Issue{pr}},
+			"repo:zw/df":    {Complete: true, Repos: []Repo{{Description: "dotfiles"}}},
+			"repo:zw/gs":    {Complete: true, Repos: []Repo{{Description: ""}}},
+		}, derived)
+	})
+
+	t.Run("a single issue", func(t *testing.T) {
+		derived := derivedResults("issue:zw/df#1", Result{Complete: true, Issues: []Issue{issue}})
+		assert.Equal(t, map[string]Result{
+			"repo:zw/df": {Complete: true, Repos: []Repo{{Description: "dotfiles"}}},
+		}, derived)
+	})
+
+	t.Run("projects", func(t *testing.T) {
+		project := Project{Number: 3, Name: "roadmap"}
+		assert.Equal(t, map[string]Result{
+			"project:zw/df/3": {Complete: true, Projects: []Project{project}},
+		}, derivedResults("projects:zw/df", Result{Complete: true, Projects: []Project{project}}))
+		assert.Equal(t, map[string]Result{
+			"project:zw/3": {Complete: true, Projects: []Project{project}},
+		}, derivedResults("projects:zw", Result{Complete: true, Projects: []Project{project}}))
+	})
+
+	t.Run("repos", func(t *testing.T) {
+		assert.Empty(t, derivedResults("repo:zw/df", Result{Complete: true, Repos: []Repo{{Description: "dotfiles"}}}))
+	})
+}
+
+// fakeBackend stands in for the GitHub API, counting its calls. Repos are
+// always found, and issues and batches are up to its funcs.
+type fakeBackend struct {
+	m     sync.Mutex
+	calls map[string]int
+	issue func(ctx context.Context, res *Result, issue string, call int) error
+	batch func(keys []string) map[string]Result
+}
+
+func (b *fakeBackend) call(key string) int {
+	b.m.Lock()
+	defer b.m.Unlock()
+	if b.calls == nil {
+		b.calls = map[string]int{}
+	}
+	b.calls[key]++
+	return b.calls[key]
+}
+
+func (b *fakeBackend) callCount(key string) int {
+	b.m.Lock()
+	defer b.m.Unlock()
+	return b.calls[key]
+}
+
+func (b *fakeBackend) GetRepo(ctx context.Context, res *Result, repo string) error {
+	b.call("repo:" + repo)
+	res.Repos = append(res.Repos, Repo{Description: "a repo"})
+	return nil
+}
+
+func (b *fakeBackend) GetIssue(ctx context.Context, res *Result, issue string) error {
+	return b.issue(ctx, res, issue, b.call("issue:"+issue))
+}
+
+func (b *fakeBackend) GetIssues(ctx context.Context, res *Result, query string) error { return nil }
+func (b *fakeBackend) GetMergedPullRequests(ctx context.Context, res *Result, query string) error {
+	return nil
+}
+func (b *fakeBackend) GetProject(ctx context.Context, res *Result, query string) error  { return nil }
+func (b *fakeBackend) GetProjects(ctx context.Context, res *Result, query string) error { return nil }
+
+func (b *fakeBackend) GetBatch(ctx context.Context, keys []string) map[string]Result {
+	return b.batch(keys)
+}
+
+// testServer serves a handler for a backend, with a single worker that gives
+// up on requests, and retries pending ones, after the timeout.
+func testServer(t *testing.T, b backend, timeout time.Duration) *httptest.Server {
+	h := newHandler(b, nopLogger)
+	h.queue = newRequestQueue(1, timeout, nopLogger)
+	h.maxPending = timeout
+	mux := chi.NewRouter()
+	h.Mount(mux)
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// await polls an endpoint until its result is complete
+func await(t *testing.T, srv *httptest.Server, endpoint string, v url.Values) []byte {
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		resp, err := http.Get(srv.URL + endpoint + "?" + v.Encode())
+		require.NoError(t, err)
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		require.NoError(t, err)
+		if !bytes.Contains(body, []byte(`"complete":false`)) {
+			return body
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	require.FailNow(t, "timed out waiting for "+endpoint)
+	return nil
+}
+
+func awaitResult(t *testing.T, srv *httptest.Server, endpoint, query string) Result {
+	var res Result
+	require.NoError(t, json.Unmarshal(await(t, srv, endpoint, url.Values{"q": {query}}), &res))
+	return res
+}
+
+func TestHandlerRecoversFromPanics(t *testing.T) {
+	b := &fakeBackend{
+		issue: func(ctx context.Context, res *Result, issue string, call int) error {
+			var fragment *issueFragment
+			res.Issues = append(res.Issues, fragment.toIssue("Issue")) // nil deref
+			return nil
+		},
+		batch: func(keys []string) map[string]Result {
+			panic("unexpected shape")
+		},
+	}
+	srv := testServer(t, b, time.Second)
+
+	res := awaitResult(t, srv, "/issue", "zw/df#1")
+	assert.True(t, res.Complete)
+	assert.Contains(t, res.Error, "rpc panic: runtime error: invalid memory address")
+	assert.Empty(t, res.Issues)
+
+	var results map[string]Result
+	require.NoError(t, json.Unmarshal(await(t, srv, "/batch", url.Values{"q": {"repo:zw/df", "issue:zw/df#2"}}), &results))
+	assert.Equal(t, Result{Complete: true, Version: ProtocolVersion, Error: "rpc panic: unexpected shape"}, results["repo:zw/df"])
+	assert.Equal(t, Result{Complete: true, Version: ProtocolVersion, Error: "rpc panic: unexpected shape"}, results["issue:zw/df#2"])
+
+	// the server is still going
+	res = awaitResult(t, srv, "/repo", "zw/gs")
+	assert.Equal(t, Result{Complete: true, Version: ProtocolVersion, Repos: []Repo{{Description: "a repo"}}}, res)
+}
+
+// assertNoLeaks checks that the number of goroutines goes back down
+func assertNoLeaks(t *testing.T, goroutines int) {
+	// Eventually runs each check in a goroutine of its own
+	assert.Eventually(t, func() bool { return runtime.NumGoroutine() <= goroutines+1 },
+		time.Second, 10*time.Millisecond, "nothing's left running")
+}
+
+func TestHandlerRetriesHungRequests(t *testing.T) {
+	b := &fakeBackend{
+		issue: func(ctx context.Context, res *Result, issue string, call int) error {
+			if call == 1 {
+				<-ctx.Done() // hangs until it's cancelled
+				return ctx.Err()
+			}
+			res.Issues = append(res.Issues, Issue{Title: "found it"})
+			return nil
+		},
+	}
+	srv := testServer(t, b, 50*time.Millisecond)
+
+	resp, err := http.Get(srv.URL + "/issue?q=zw/df%231")
+	require.NoError(t, err)
+	resp.Body.Close()
+	goroutines := runtime.NumGoroutine()
+	require.Eventually(t, func() bool { return b.callCount("issue:zw/df#1") == 1 },
+		time.Second, time.Millisecond)
+
+	// the hung request is cancelled, so it doesn't hold up the only worker
+	res := awaitResult(t, srv, "/repo", "zw/df")
+	assert.Equal(t, []Repo{{Description: "a repo"}}, res.Repos)
+
+	// and it's retried the next time it's asked for, without caching the timeout
+	res = awaitResult(t, srv, "/issue", "zw/df#1")
+	assert.Equal(t, Result{Complete: true, Version: ProtocolVersion, Issues: []Issue{{Title: "found it"}}}, res)
+	assert.Equal(t, 2, b.callCount("issue:zw/df#1"))
+
+	assertNoLeaks(t, goroutines)
+}
+
+func TestHandlerFreesWorkersFromCallsIgnoringCancellation(t *testing.T) {
+	release := make(chan struct{})
+	b := &fakeBackend{
+		issue: func(ctx context.Context, res *Result, issue string, call int) error {
+			if call == 1 {
+				<-release // ignores ctx entirely
+				return nil
+			}
+			res.Issues = append(res.Issues, Issue{Title: "found it"})
+			return nil
+		},
+	}
+	srv := testServer(t, b, 50*time.Millisecond)
+	t.Cleanup(func() { close(release) })
+
+	resp, err := http.Get(srv.URL + "/issue?q=zw/df%231")
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Eventually(t, func() bool { return b.callCount("issue:zw/df#1") == 1 },
+		time.Second, time.Millisecond)
+
+	// the only worker moves on once the call times out, though it's still running
+	res := awaitResult(t, srv, "/repo", "zw/df")
+	assert.Equal(t, []Repo{{Description: "a repo"}}, res.Repos)
+	res = awaitResult(t, srv, "/repo", "zw/other")
+	assert.True(t, res.Complete)
+
+	res = awaitResult(t, srv, "/issue", "zw/df#1")
+	assert.Equal(t, []Issue{{Title: "found it"}}, res.Issues)
+	assert.Equal(t, 2, b.callCount("issue:zw/df#1"))
+}
+
+func TestHandlerDropsLateResults(t *testing.T) {
+	release := make(chan struct{})
+	returned := make(chan struct{})
+	b := &fakeBackend{
+		issue: func(ctx context.Context, res *Result, issue string, call int) error {
+			if call == 1 {
+				defer close(returned)
+				<-release
+				res.Issues = append(res.Issues, Issue{Title: "stale"})
+				return nil
+			}
+			res.Issues = append(res.Issues, Issue{Title: "found it"})
+			return nil
+		},
+	}
+	h := newHandler(b, nopLogger)
+	h.queue = newRequestQueue(2, time.Minute, nopLogger)
+	h.maxPending = 50 * time.Millisecond
+	mux := chi.NewRouter()
+	h.Mount(mux)
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/issue?q=zw/df%231")
+	require.NoError(t, err)
+	resp.Body.Close()
+	goroutines := runtime.NumGoroutine()
+	require.Eventually(t, func() bool { return b.callCount("issue:zw/df#1") == 1 },
+		time.Second, time.Millisecond)
+
+	// the slow request is retried once it's been pending too long
+	res := awaitResult(t, srv, "/issue", "zw/df#1")
+	assert.Equal(t, []Issue{{Title: "found it"}}, res.Issues)
+	assert.Equal(t, 2, b.callCount("issue:zw/df#1"))
+
+	// and when the first one finally finishes, its result is dropped
+	close(release)
+	<-returned
+	assert.Never(t, func() bool {
+		return awaitResult(t, srv, "/issue", "zw/df#1").Issues[0].Title != "found it"
+	}, 100*time.Millisecond, 10*time.Millisecond)
+
+	assertNoLeaks(t, goroutines)
+}
+
+func TestHandlerReconfigure(t *testing.T) {
+	b := &fakeBackend{}
+	h := newHandler(b, nopLogger)
+	h.cache.Set("repo:zw/df", Result{Complete: true}, time.Minute)
+
+	h.Reconfigure(config.Config{CacheTTL: time.Hour})
+	assert.Equal(t, time.Hour, h.resultTTL)
+	assert.Equal(t, errorTTL, h.errorTTL)
+	assert.Same(t, b, h.backend(), "keeps the client for the same token")
+	assert.Equal(t, 1, h.cache.ItemCount())
+
+	h.Reconfigure(config.Config{APIToken: "abc", ErrorTTL: time.Minute})
+	assert.Equal(t, resultTTL, h.resultTTL)
+	assert.Equal(t, time.Minute, h.errorTTL)
+	assert.IsType(t, &GitHubClient{}, h.backend(), "uses a new client for a new token")
+	assert.Zero(t, h.cache.ItemCount(), "clears the cache")
+}
+
+// logBuffer collects the log output from a handler's goroutines
+type logBuffer struct {
+	m sync.Mutex
+	b bytes.Buffer
+}
+
+func (l *logBuffer) Write(p []byte) (int, error) {
+	l.m.Lock()
+	defer l.m.Unlock()
+	return l.b.Write(p)
+}
+
+func (l *logBuffer) String() string {
+	l.m.Lock()
+	defer l.m.Unlock()
+	return l.b.String()
+}
+
+func TestHandlerLogs(t *testing.T) {
+	for _, level := range []slog.Level{slog.LevelInfo, slog.LevelDebug} {
+		t.Run(level.String(), func(t *testing.T) {
+			var logs logBuffer
+			b := &fakeBackend{
+				issue: func(ctx context.Context, res *Result, issue string, call int) error {
+					if issue == "zw/hidden#1" {
+						return errors.New("Could not resolve to a Repository with the name 'zw/hidden'.")
+					}
+					res.Issues = append(res.Issues, Issue{
+						Title:       "secret plans",
+						Repo:        "zw/private",
+						Number:      "1",
+						RepoPrivate: true,
+					})
+					return nil
+				},
+			}
+			h := newHandler(b, slog.New(slog.NewTextHandler(&logs, &slog.HandlerOptions{Level: level})))
+			mux := chi.NewRouter()
+			h.Mount(mux)
+			srv := httptest.NewServer(mux)
+			defer srv.Close()
+
+			awaitResult(t, srv, "/issue", "zw/private#1")
+			awaitResult(t, srv, "/issue", "zw/hidden#1")
+			awaitResult(t, srv, "/project", "zw/secret/1")
+			awaitResult(t, srv, "/repo", "zw/public")
+
+			out := logs.String()
+			assert.Contains(t, out, `msg="RPC result" key=repo:zw/public`)
+			assert.Contains(t, out, `msg="RPC request" key=repo:zw/public cache=hit`)
+			if level == slog.LevelDebug {
+				assert.Contains(t, out, "key=issue:zw/private#1")
+				assert.Contains(t, out, "secret plans", "logs the full result")
+				assert.Contains(t, out, "Could not resolve to a Repository with the name 'zw/hidden'.")
+				assert.Contains(t, out, "key=project:zw/secret/1")
+				assert.Contains(t, out, "cache=miss")
+			} else {
+				for _, secret := range []string{"zw/private", "secret plans", "zw/hidden", "zw/secret"} {
+					assert.NotContains(t, out, secret)
+				}
+				assert.Contains(t, out, "key="+redact("issue:zw/private#1"))
+				assert.Contains(t, out, "key="+redact("issue:zw/hidden#1")+" ", "an error doesn't show it's public")
+				assert.Contains(t, out, "error="+hash("Could not resolve to a Repository with the name 'zw/hidden'."))
+				assert.Contains(t, out, "key="+redact("project:zw/secret/1"), "projects don't say if they're public")
+				assert.NotContains(t, out, "cache=miss", "only logged at debug")
+			}
+		})
+	}
+}
+
+func TestResultPublic(t *testing.T) {
+	assert.True(t, Result{Repos: []Repo{{}}}.public())
+	assert.True(t, Result{Issues: []Issue{{}, {}}}.public())
+	assert.False(t, Result{}.public(), "empty")
+	assert.False(t, Result{Error: "not found"}.public())
+	assert.False(t, Result{Projects: []Project{{}}}.public())
+	assert.False(t, Result{Issues: []Issue{{}, {RepoPrivate: true}}}.public())
+	assert.False(t, Result{Repos: []Repo{{Private: true}}}.public())
+}
@@ -0,0 +1,60 @@
+package rpc
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+// Queries can name private repositories, so they're only logged in full at the
+// debug level. Above that, a query and any error about it are redacted unless
+// its result shows it's only for public repositories: errors, empty results
+// and projects don't say, so they're redacted too.
+
+// redacting checks if a query should be redacted from the logs, given whether
+// its result shows it's public
+func redacting(logger *slog.Logger, public bool) bool {
+	return !public && !logger.Enabled(context.Background(), slog.LevelDebug)
+}
+
+// keyAttr is the log attribute for a request's key, redacted unless the
+// request is known to be public or the logger is logging debug messages
+func keyAttr(logger *slog.Logger, key string, public bool) slog.Attr {
+	if redacting(logger, public) {
+		return slog.String("key", redact(key))
+	}
+	return slog.String("key", key)
+}
+
+// redact replaces the query in a key with a short hash of it, so log messages
+// about the same query can still be matched up
+func redact(key string) string {
+	action, query, _ := strings.Cut(key, ":")
+	return action + ":" + hash(query)
+}
+
+func hash(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return fmt.Sprintf("redacted-%x", sum[:4])
+}
+
+// public checks if a result shows its query is only for public repositories:
+// it has repos or issues, and none of them are private
+func (r Result) public() bool {
+	if len(r.Error) > 0 || len(r.Projects) > 0 || len(r.Repos)+len(r.Issues) == 0 {
+		return false
+	}
+	for _, repo := range r.Repos {
+		if repo.Private {
+			return false
+		}
+	}
+	for _, issue := range r.Issues {
+		if issue.RepoPrivate {
+			return false
+		}
+	}
+	return true
+}
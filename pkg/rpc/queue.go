@@ -0,0 +1,176 @@
+package rpc
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+)
+
+// how many API requests to make at once
+const maxConcurrentRequests = 4
+
+// maxQueueWait is how long a request can wait behind newer and higher priority
+// ones before it runs ahead of them
+const maxQueueWait = 10 * time.Second
+
+// Request priorities: single lookups are quick and are for what's in front of
+// the user right now, while searches are slower and more speculative.
+const (
+	searchPriority = iota
+	lookupPriority
+)
+
+// the actions that are lookups rather than searches
+var lookupActions = map[string]bool{
+	"repo":    true,
+	"issue":   true,
+	"project": true,
+	"batch":   true,
+}
+
+// the searches whose queries are typed by the user, and so can be superseded
+var supersedable = map[string]bool{
+	"issues":   true,
+	"projects": true,
+}
+
+// queuedRequest is an API request waiting for a worker
+type queuedRequest struct {
+	key      string
+	action   string
+	query    string
+	priority int
+	seq      uint64
+	queued   time.Time
+	run      func(ctx context.Context)
+}
+
+// requestQueue runs API requests on a fixed number of workers, highest
+// priority and then most recent first, except that requests which have waited
+// longer than maxWait run first, oldest first, so they can't starve.
+//
+// A request that takes longer than the timeout is cancelled, and its worker
+// moves on to the next request without waiting for it to return, so requests
+// that hang can't take up all the workers even if they ignore the
+// cancellation.
+type requestQueue struct {
+	m        sync.Mutex
+	ready    *sync.Cond
+	requests []*queuedRequest
+	seq      uint64
+	timeout  time.Duration
+	maxWait  time.Duration
+	logger   *slog.Logger
+}
+
+func newRequestQueue(workers int, timeout time.Duration, logger *slog.Logger) *requestQueue {
+	q := &requestQueue{timeout: timeout, maxWait: maxQueueWait, logger: logger}
+	q.ready = sync.NewCond(&q.m)
+	for i := 0; i < workers; i++ {
+		go q.work()
+	}
+	return q
+}
+
+// push queues a request for an action and query, identified by key. Returns
+// the keys of any queued searches the new one supersedes, i.e. earlier
+// searches for the same action whose query the user has since extended. These
+// are dropped from the queue without running.
+func (q *requestQueue) push(key, action, query string, run func(ctx context.Context)) (superseded []string) {
+	q.m.Lock()
+	defer q.m.Unlock()
+
+	priority := searchPriority
+	if lookupActions[action] {
+		priority = lookupPriority
+	}
+
+	if supersedable[action] {
+		remaining := q.requests[:0]
+		for _, r := range q.requests {
+			if r.action == action && r.query != query && strings.HasPrefix(query, r.query) {
+				superseded = append(superseded, r.key)
+				q.logger.Debug("RPC superseded", slog.String("key", r.key), slog.String("by", key))
+				continue
+			}
+			remaining = append(remaining, r)
+		}
+		q.requests = remaining
+	}
+
+	q.seq++
+	q.requests = append(q.requests, &queuedRequest{
+		key:      key,
+		action:   action,
+		query:    query,
+		priority: priority,
+		seq:      q.seq,
+		queued:   time.Now(),
+		run:      run,
+	})
+	q.logger.Debug("RPC queued", slog.String("key", key), slog.Int("queued", len(q.requests)))
+	q.ready.Signal()
+
+	return superseded
+}
+
+// next waits for and removes the next request to run
+func (q *requestQueue) next() *queuedRequest {
+	q.m.Lock()
+	defer q.m.Unlock()
+	for len(q.requests) == 0 {
+		q.ready.Wait()
+	}
+
+	best := 0
+	for i, r := range q.requests {
+		b := q.requests[best]
+		if q.before(r, b) {
+			best = i
+		}
+	}
+	r := q.requests[best]
+	q.requests = append(q.requests[:best], q.requests[best+1:]...)
+	q.logger.Debug("RPC dequeued", slog.String("key", r.key),
+		slog.Duration("waited", time.Since(r.queued).Round(time.Millisecond)),
+		slog.Int("queued", len(q.requests)))
+	return r
+}
+
+// before reports whether request a should run before request b. Must be
+// called with the lock held.
+func (q *requestQueue) before(a, b *queuedRequest) bool {
+	aOverdue := time.Since(a.queued) > q.maxWait
+	bOverdue := time.Since(b.queued) > q.maxWait
+	switch {
+	case aOverdue && bOverdue:
+		return a.seq < b.seq
+	case aOverdue || bOverdue:
+		return aOverdue
+	case a.priority != b.priority:
+		return a.priority > b.priority
+	default:
+		return a.seq > b.seq
+	}
+}
+
+func (q *requestQueue) work() {
+	for {
+		r := q.next()
+		ctx, cancel := context.WithTimeout(context.Background(), q.timeout)
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			r.run(ctx)
+		}()
+		select {
+		case <-done:
+		case <-ctx.Done():
+			// the request is left to return whenever it does, if ever
+			q.logger.Warn("RPC timed out", keyAttr(q.logger, r.key, false), slog.Duration("after", q.timeout))
+		}
+		cancel()
+	}
+}
@@ -0,0 +1,141 @@
+package rpc
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// nopLogger discards log messages
+var nopLogger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+func TestRequestQueueOrder(t *testing.T) {
+	q := newRequestQueue(1, time.Minute, nopLogger)
+
+	// occupy the only worker while the rest are queued
+	started := make(chan struct{})
+	release := make(chan struct{})
+	q.push("issue:zw/df#1", "issue", "zw/df#1", func(context.Context) {
+		close(started)
+		<-release
+	})
+	<-started
+
+	var m sync.Mutex
+	var wg sync.WaitGroup
+	var order []string
+	push := func(action, query string) {
+		key := BatchKey(action, query)
+		wg.Add(1)
+		q.push(key, action, query, func(context.Context) {
+			m.Lock()
+			order = append(order, key)
+			m.Unlock()
+			wg.Done()
+		})
+	}
+	push("issues", "bug")
+	push("issue", "zw/df#2")
+	push("issues", "feature")
+	push("repo", "zw/df")
+	push("merged", "zw/df v1...v2")
+
+	close(release)
+	wg.Wait()
+	assert.Equal(t, []string{
+		"repo:zw/df", "issue:zw/df#2", "merged:zw/df v1...v2", "issues:feature", "issues:bug",
+	}, order)
+}
+
+func TestRequestQueueMaxWait(t *testing.T) {
+	q := newRequestQueue(1, time.Minute, nopLogger)
+	q.maxWait = 20 * time.Millisecond
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	q.push("issue:zw/df#1", "issue", "zw/df#1", func(context.Context) {
+		close(started)
+		<-release
+	})
+	<-started
+
+	var m sync.Mutex
+	var wg sync.WaitGroup
+	var order []string
+	push := func(action, query string) {
+		key := BatchKey(action, query)
+		wg.Add(1)
+		q.push(key, action, query, func(context.Context) {
+			m.Lock()
+			order = append(order, key)
+			m.Unlock()
+			wg.Done()
+		})
+	}
+	push("issues", "bug")
+	push("issues", "feature")
+	time.Sleep(2 * q.maxWait)
+	push("issues", "docs")
+	push("repo", "zw/df")
+
+	close(release)
+	wg.Wait()
+	assert.Equal(t, []string{
+		"issues:bug", "issues:feature", "repo:zw/df", "issues:docs",
+	}, order, "overdue requests run first, oldest first")
+}
+
+func TestRequestQueueSupersedes(t *testing.T) {
+	q := newRequestQueue(0, time.Minute, nopLogger) // nothing runs
+	nop := func(context.Context) {}
+
+	assert.Empty(t, q.push("issues:b", "issues", "b", nop))
+	assert.Empty(t, q.push("issues:x", "issues", "x", nop))
+	assert.Empty(t, q.push("projects:zw", "projects", "zw", nop))
+	assert.Equal(t, []string{"issues:b"}, q.push("issues:bu", "issues", "bu", nop))
+	assert.Equal(t, []string{"issues:bu"}, q.push("issues:bug", "issues", "bug", nop))
+	assert.Empty(t, q.push("issues:bug", "issues", "bug", nop), "doesn't supersede the same query")
+	assert.Equal(t, []string{"projects:zw"}, q.push("projects:zw/df", "projects", "zw/df", nop))
+
+	assert.Empty(t, q.push("issue:zw/df#1", "issue", "zw/df#1", nop))
+	assert.Empty(t, q.push("issue:zw/df#12", "issue", "zw/df#12", nop), "lookups aren't superseded")
+
+	var keys []string
+	for _, r := range q.requests {
+		keys = append(keys, r.key)
+	}
+	assert.Equal(t, []string{
+		"issues:x", "issues:bug", "issues:bug", "projects:zw/df", "issue:zw/df#1", "issue:zw/df#12",
+	}, keys)
+}
+
+func TestRequestQueueConcurrency(t *testing.T) {
+	q := newRequestQueue(2, time.Minute, nopLogger)
+
+	var m sync.Mutex
+	var wg sync.WaitGroup
+	running, most := 0, 0
+	for i := 0; i < 6; i++ {
+		wg.Add(1)
+		q.push("issue:zw/df#1", "issue", "zw/df#1", func(context.Context) {
+			m.Lock()
+			running++
+			if running > most {
+				most = running
+			}
+			m.Unlock()
+			time.Sleep(10 * time.Millisecond)
+			m.Lock()
+			running--
+			m.Unlock()
+			wg.Done()
+		})
+	}
+	wg.Wait()
+	assert.Equal(t, 2, most)
+}
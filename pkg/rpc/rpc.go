@@ -4,6 +4,7 @@ package rpc
 type Result struct {
 	Complete bool   `json:"complete"` // is the request finished?
 	Error    string `json:"error"`    // server error, if applicable
+	Version  int    `json:"version"`  // the server's ProtocolVersion
 
 	Repos    []Repo    `json:"repos"`
 	Issues   []Issue   `json:"issues"`
@@ -13,15 +14,25 @@ type Result struct {
 // Repo is a respository in an RPC result
 type Repo struct {
 	Description string `json:"description"`
+
+	// Private is only used by the server, to redact its logs
+	Private bool `json:"-"`
 }
 
 // Issue is an issue in a RPC result
 type Issue struct {
-	Type   string `json:"type"`
-	State  string `json:"state"`
-	Title  string `json:"description"`
-	Repo   string `json:"repo"`
-	Number string `json:"number"`
+	Type   string   `json:"type"`
+	State  string   `json:"state"`
+	Title  string   `json:"description"`
+	Repo   string   `json:"repo"`
+	Number string   `json:"number"`
+	Author string   `json:"author"`
+	Labels []string `json:"labels"`
+
+	// RepoDescription and RepoPrivate are only used by the server, to cache
+	// the repo too and to redact its logs
+	RepoDescription string `json:"-"`
+	RepoPrivate     bool   `json:"-"`
 }
 
 // Project is a project in an RPC result
@@ -0,0 +1,46 @@
+package rpc
+
+// ProtocolVersion is the version of the RPC protocol: the endpoints and the
+// format of their results. Bump it when either changes, so a client can tell
+// when the running server is an older binary that's missing something. Servers
+// from before versioning report version 0.
+const ProtocolVersion = 1
+
+// Capabilities are the optional features of the protocol this server supports
+var Capabilities = []string{"batch", "merged", "labels"}
+
+// Version describes the protocol a server speaks
+type Version struct {
+	Protocol     int      `json:"protocol"`
+	Capabilities []string `json:"capabilities"`
+}
+
+// CurrentVersion is the version of the protocol spoken by this binary
+func CurrentVersion() Version {
+	return Version{Protocol: ProtocolVersion, Capabilities: Capabilities}
+}
+
+// Supports checks if the server supports a capability
+func (v Version) Supports(capability string) bool {
+	for _, c := range v.Capabilities {
+		if c == capability {
+			return true
+		}
+	}
+	return false
+}
+
+// Outdated checks if the server is older than this client
+func (v Version) Outdated() bool {
+	return v.Protocol < ProtocolVersion
+}
+
+// FromOutdatedServer checks if a result came from a server that's older than
+// this client: either it has no version, or the server doesn't have the
+// endpoint at all.
+func (r Result) FromOutdatedServer() bool {
+	if r.Error == errNotFound.Error() {
+		return true
+	}
+	return len(r.Error) == 0 && r.Version < ProtocolVersion
+}
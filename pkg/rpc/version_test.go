@@ -0,0 +1,58 @@
+package rpc
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zerowidth/gh-shorthand/pkg/config"
+)
+
+func TestVersion(t *testing.T) {
+	v := CurrentVersion()
+	assert.True(t, v.Supports("batch"))
+	assert.False(t, v.Supports("teleport"))
+	assert.False(t, v.Outdated())
+	assert.True(t, Version{}.Outdated())
+}
+
+func TestVersionEndpoint(t *testing.T) {
+	srv := testServer(t, &fakeBackend{}, time.Second)
+
+	resp, err := http.Get(srv.URL + "/version")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	var v Version
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&v))
+	assert.Equal(t, CurrentVersion(), v)
+}
+
+func TestClientVersion(t *testing.T) {
+	client := func(srv *httptest.Server) AddrClient {
+		return NewClient(config.Config{
+			ServerAddr: strings.TrimPrefix(srv.URL, "http://"),
+			RPCSecret:  "secret",
+		})
+	}
+
+	v, err := client(testServer(t, &fakeBackend{}, time.Second)).Version()
+	require.NoError(t, err)
+	assert.Equal(t, CurrentVersion(), v)
+
+	// a server from before versioning has no /version endpoint
+	old := httptest.NewServer(chi.NewRouter())
+	defer old.Close()
+	v, err = client(old).Version()
+	require.NoError(t, err)
+	assert.Equal(t, 0, v.Protocol)
+	assert.True(t, v.Outdated())
+
+	_, err = NewClient(config.Config{}).Version()
+	assert.Error(t, err)
+}
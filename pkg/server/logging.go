@@ -0,0 +1,188 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/kardianos/service"
+	"github.com/zerowidth/gh-shorthand/pkg/config"
+)
+
+const (
+	defaultLogMaxSize = 10 // megabytes
+	logBackups        = 3  // how many rotated log files to keep
+)
+
+// newLogger creates the server's logger at the given level. It writes to the
+// log file if one is configured, and otherwise to the service's log. The
+// returned func closes the log file, if any.
+func newLogger(cfg config.Config, svcLogger service.Logger, level slog.Leveler) (*slog.Logger, func() error, error) {
+	opts := &slog.HandlerOptions{Level: level}
+	if len(cfg.LogFile) == 0 {
+		return slog.New(newServiceHandler(svcLogger, cfg.LogFormat, opts)), func() error { return nil }, nil
+	}
+
+	path, err := cfg.LogPath()
+	if err != nil {
+		return nil, nil, err
+	}
+	maxSize := cfg.LogMaxSize
+	if maxSize == 0 {
+		maxSize = defaultLogMaxSize
+	}
+	f, err := openRotatingFile(path, int64(maxSize)<<20, logBackups)
+	if err != nil {
+		return nil, nil, err
+	}
+	return slog.New(newFormatHandler(cfg.LogFormat, f, opts)), f.Close, nil
+}
+
+// newFormatHandler creates a handler for the configured log format
+func newFormatHandler(format string, w io.Writer, opts *slog.HandlerOptions) slog.Handler {
+	if format == "json" {
+		return slog.NewJSONHandler(w, opts)
+	}
+	return slog.NewTextHandler(w, opts)
+}
+
+// serviceHandler formats each log record and passes it on to the service
+// logger at the same level, so logs go wherever the platform puts them.
+type serviceHandler struct {
+	slog.Handler // formats records into out
+	out          *serviceOutput
+}
+
+type serviceOutput struct {
+	m      sync.Mutex
+	buf    bytes.Buffer
+	logger service.Logger
+}
+
+func (o *serviceOutput) Write(p []byte) (int, error) {
+	return o.buf.Write(p)
+}
+
+func newServiceHandler(logger service.Logger, format string, opts *slog.HandlerOptions) serviceHandler {
+	out := &serviceOutput{logger: logger}
+	withoutTime := *opts
+	withoutTime.ReplaceAttr = func(groups []string, a slog.Attr) slog.Attr {
+		if len(groups) == 0 && a.Key == slog.TimeKey {
+			return slog.Attr{} // the service logger has its own timestamps
+		}
+		return a
+	}
+	return serviceHandler{Handler: newFormatHandler(format, out, &withoutTime), out: out}
+}
+
+func (h serviceHandler) Handle(ctx context.Context, r slog.Record) error {
+	h.out.m.Lock()
+	defer h.out.m.Unlock()
+	h.out.buf.Reset()
+	if err := h.Handler.Handle(ctx, r); err != nil {
+		return err
+	}
+
+	line := strings.TrimSuffix(h.out.buf.String(), "\n")
+	switch {
+	case r.Level >= slog.LevelError:
+		return h.out.logger.Error(line)
+	case r.Level >= slog.LevelWarn:
+		return h.out.logger.Warning(line)
+	default:
+		return h.out.logger.Info(line)
+	}
+}
+
+func (h serviceHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return serviceHandler{Handler: h.Handler.WithAttrs(attrs), out: h.out}
+}
+
+func (h serviceHandler) WithGroup(name string) slog.Handler {
+	return serviceHandler{Handler: h.Handler.WithGroup(name), out: h.out}
+}
+
+// rotatingFile is a log file that's rotated when it reaches its maximum size,
+// keeping a number of old files as path.1, path.2 and so on, newest first.
+type rotatingFile struct {
+	m       sync.Mutex
+	path    string
+	maxSize int64
+	backups int
+	f       *os.File
+	size    int64
+}
+
+func openRotatingFile(path string, maxSize int64, backups int) (*rotatingFile, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	r := &rotatingFile{path: path, maxSize: maxSize, backups: backups}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *rotatingFile) open() error {
+	f, err := os.OpenFile(r.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	r.f, r.size = f, info.Size()
+	return nil
+}
+
+func (r *rotatingFile) Write(p []byte) (int, error) {
+	r.m.Lock()
+	defer r.m.Unlock()
+	if r.size > 0 && r.size+int64(len(p)) > r.maxSize {
+		if err := r.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := r.f.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+// rotate moves each file along to the next backup, dropping the oldest, and
+// starts a new file. Must be called with the lock held.
+func (r *rotatingFile) rotate() error {
+	if err := r.f.Close(); err != nil {
+		return err
+	}
+	backup := func(i int) string { return fmt.Sprintf("%s.%d", r.path, i) }
+	for i := r.backups - 1; i > 0; i-- {
+		if err := os.Rename(backup(i), backup(i+1)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	var err error
+	if r.backups > 0 {
+		err = os.Rename(r.path, backup(1))
+	} else {
+		err = os.Remove(r.path)
+	}
+	if err != nil {
+		return err
+	}
+	return r.open()
+}
+
+func (r *rotatingFile) Close() error {
+	r.m.Lock()
+	defer r.m.Unlock()
+	return r.f.Close()
+}
@@ -0,0 +1,78 @@
+package server
+
+import (
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zerowidth/gh-shorthand/pkg/config"
+)
+
+func TestServiceHandler(t *testing.T) {
+	svcLogger := &testLogger{}
+	logger := slog.New(newServiceHandler(svcLogger, "", &slog.HandlerOptions{}))
+
+	logger.Debug("too quiet")
+	logger.Info("started", "addr", "localhost:7331")
+	logger.With("key", "repo:zw/df").Warn("slow")
+	logger.WithGroup("rpc").Error("failed", "error", "boom")
+
+	assert.Equal(t, []string{
+		"info: level=INFO msg=started addr=localhost:7331",
+		"warning: level=WARN msg=slow key=repo:zw/df",
+		"error: level=ERROR msg=failed rpc.error=boom",
+	}, svcLogger.messages)
+}
+
+func TestLogFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs", "gh-shorthand.log")
+	cfg := config.Config{LogFile: path, LogFormat: "json"}
+	logger, closeLog, err := newLogger(cfg, &testLogger{}, slog.LevelInfo)
+	require.NoError(t, err)
+
+	logger.Info("started", "addr", "localhost:7331")
+	require.NoError(t, closeLog())
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	var record map[string]interface{}
+	require.NoError(t, json.Unmarshal(data, &record))
+	assert.Equal(t, "started", record["msg"])
+	assert.Equal(t, "localhost:7331", record["addr"])
+}
+
+func TestRotatingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "gh-shorthand.log")
+	f, err := openRotatingFile(path, 10, 2)
+	require.NoError(t, err)
+
+	for _, line := range []string{"one\n", "two\n", "three\n", "four\n", "five\n"} {
+		_, err := f.Write([]byte(line))
+		require.NoError(t, err)
+	}
+	require.NoError(t, f.Close())
+
+	read := func(name string) string {
+		data, err := os.ReadFile(name)
+		require.NoError(t, err)
+		return string(data)
+	}
+	assert.Equal(t, "four\nfive\n", read(path))
+	assert.Equal(t, "three\n", read(path+".1"))
+	assert.Equal(t, "one\ntwo\n", read(path+".2"))
+
+	// picks up where it left off, and drops the oldest file
+	f, err = openRotatingFile(path, 10, 2)
+	require.NoError(t, err)
+	_, err = f.Write([]byte("six\n"))
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	assert.Equal(t, "six\n", read(path))
+	assert.Equal(t, "four\nfive\n", read(path+".1"))
+	assert.Equal(t, "three\n", read(path+".2"))
+	assert.NoFileExists(t, path+".3")
+}
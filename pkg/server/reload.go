@@ -0,0 +1,91 @@
+package server
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/zerowidth/gh-shorthand/pkg/config"
+)
+
+// how often to check the config file for changes
+const configPollInterval = 5 * time.Second
+
+// configReloader reloads the config while the server is running, when the
+// config file changes or on SIGHUP, and applies it.
+type configReloader struct {
+	cfg    config.Config
+	apply  func(config.Config)
+	logger *slog.Logger
+}
+
+// watch checks for config changes until stopped
+func (r *configReloader) watch(stop <-chan interface{}) {
+	if len(r.cfg.Path) == 0 {
+		return // not loaded from a file
+	}
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+	ticker := time.NewTicker(configPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-hup:
+			r.logger.Info("SIGHUP received, reloading config")
+			r.check(true)
+		case <-ticker.C:
+			r.check(false)
+		}
+	}
+}
+
+// check reloads the config if the file has changed since it was last loaded,
+// or if forced. If the new config is invalid, the error is logged and the
+// current config is kept.
+func (r *configReloader) check(force bool) {
+	info, err := os.Stat(r.cfg.Path)
+	if err != nil {
+		if force {
+			r.logger.Error("couldn't reload config", "error", err)
+		}
+		return
+	}
+	if !force && info.ModTime().Equal(r.cfg.ModTime) {
+		return
+	}
+
+	cfg, err := config.LoadFromFile(r.cfg.Path)
+	if err == nil && len(cfg.APIToken) == 0 {
+		err = fmt.Errorf("no api_token configured")
+	}
+	if err != nil {
+		r.logger.Error("couldn't reload config, keeping the current config", "path", r.cfg.Path, "error", err)
+		r.cfg.ModTime = info.ModTime() // don't try again until it changes
+		return
+	}
+
+	// the listeners and the log file are set up at startup
+	if cfg.SocketPath != r.cfg.SocketPath {
+		r.logger.Warn("socket_path changed, which takes effect after a restart", "socket_path", cfg.SocketPath)
+		cfg.SocketPath = r.cfg.SocketPath
+	}
+	if cfg.ListenAddr != r.cfg.ListenAddr || cfg.ListenRemote != r.cfg.ListenRemote || cfg.RPCSecret != r.cfg.RPCSecret {
+		r.logger.Warn("listen_addr, listen_remote or rpc_secret changed, which takes effect after a restart")
+		cfg.ListenAddr, cfg.ListenRemote, cfg.RPCSecret = r.cfg.ListenAddr, r.cfg.ListenRemote, r.cfg.RPCSecret
+	}
+	if cfg.LogFile != r.cfg.LogFile || cfg.LogFormat != r.cfg.LogFormat || cfg.LogMaxSize != r.cfg.LogMaxSize {
+		r.logger.Warn("log_file, log_format or log_max_size changed, which takes effect after a restart")
+		cfg.LogFile, cfg.LogFormat, cfg.LogMaxSize = r.cfg.LogFile, r.cfg.LogFormat, r.cfg.LogMaxSize
+	}
+	r.cfg = cfg
+	r.apply(cfg)
+	r.logger.Info("reloaded config", "path", r.cfg.Path)
+}
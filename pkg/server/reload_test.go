@@ -0,0 +1,100 @@
+package server
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zerowidth/gh-shorthand/pkg/config"
+)
+
+// testLogger records log messages
+type testLogger struct {
+	m        sync.Mutex
+	messages []string
+}
+
+func (l *testLogger) log(level, msg string) error {
+	l.m.Lock()
+	defer l.m.Unlock()
+	l.messages = append(l.messages, level+": "+msg)
+	return nil
+}
+
+// logger logs to the test logger through a service handler, at every level
+func (l *testLogger) logger() *slog.Logger {
+	return slog.New(newServiceHandler(l, "", &slog.HandlerOptions{Level: slog.LevelDebug}))
+}
+
+func (l *testLogger) Error(v ...interface{}) error   { return l.log("error", fmt.Sprint(v...)) }
+func (l *testLogger) Warning(v ...interface{}) error { return l.log("warning", fmt.Sprint(v...)) }
+func (l *testLogger) Info(v ...interface{}) error    { return l.log("info", fmt.Sprint(v...)) }
+func (l *testLogger) Errorf(format string, a ...interface{}) error {
+	return l.log("error", fmt.Sprintf(format, a...))
+}
+func (l *testLogger) Warningf(format string, a ...interface{}) error {
+	return l.log("warning", fmt.Sprintf(format, a...))
+}
+func (l *testLogger) Infof(format string, a ...interface{}) error {
+	return l.log("info", fmt.Sprintf(format, a...))
+}
+
+func TestConfigReloader(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "gh-shorthand.yml")
+	modTime := time.Now().Add(-time.Hour)
+	write := func(yml string) {
+		require.NoError(t, os.WriteFile(path, []byte(yml), 0o600))
+		modTime = modTime.Add(time.Second)
+		require.NoError(t, os.Chtimes(path, modTime, modTime))
+	}
+
+	write("api_token: abc\n")
+	cfg, err := config.LoadFromFile(path)
+	require.NoError(t, err)
+
+	var applied []config.Config
+	logger := &testLogger{}
+	r := configReloader{
+		cfg:    cfg,
+		apply:  func(cfg config.Config) { applied = append(applied, cfg) },
+		logger: logger.logger(),
+	}
+
+	r.check(false)
+	assert.Empty(t, applied, "unchanged")
+
+	write("api_token: def\ncache_ttl: 1h\nsocket_path: /tmp/other.sock\n")
+	r.check(false)
+	require.Len(t, applied, 1)
+	assert.Equal(t, "def", applied[0].APIToken)
+	assert.Equal(t, time.Hour, applied[0].CacheTTL)
+	assert.Equal(t, "/tmp/gh-shorthand.sock", applied[0].SocketPath, "keeps the socket path")
+	assert.Contains(t, logger.messages,
+		`warning: level=WARN msg="socket_path changed, which takes effect after a restart" socket_path=/tmp/other.sock`)
+
+	write("api_token: ghi\ndefault_repo: nope\n")
+	r.check(false)
+	assert.Len(t, applied, 1, "keeps the current config")
+	assert.Equal(t, "def", r.cfg.APIToken)
+	assert.Contains(t, logger.messages[len(logger.messages)-1], "keeping the current config")
+
+	write("repos: {}\n")
+	r.check(false)
+	assert.Len(t, applied, 1)
+	assert.Contains(t, logger.messages[len(logger.messages)-1], "no api_token configured")
+
+	r.check(false)
+	assert.Len(t, applied, 1, "doesn't retry an invalid config until it changes")
+
+	write("api_token: jkl\n")
+	r.check(false)
+	r.check(true)
+	require.Len(t, applied, 3, "reloads when forced")
+	assert.Equal(t, "jkl", applied[2].APIToken)
+}
@@ -2,8 +2,10 @@ package server
 
 import (
 	"context"
+	"crypto/subtle"
 	"fmt"
 	"log"
+	"log/slog"
 	"net"
 	"net/http"
 	"os"
@@ -12,12 +14,13 @@ import (
 	"time"
 
 	"github.com/go-chi/chi"
-	"github.com/go-chi/chi/middleware"
 	"github.com/kardianos/service"
 	"github.com/zerowidth/gh-shorthand/pkg/config"
 	"github.com/zerowidth/gh-shorthand/pkg/rpc"
 )
 
+const description = "GitHub autocompletion tools for Alfred"
+
 type server struct {
 	cfg  config.Config
 	stop chan interface{} // external "stop the server" signal
@@ -33,9 +36,9 @@ func Service(cfg config.Config) service.Service {
 	}
 
 	sc := service.Config{
-		Name:        "gh-shorthand",
-		DisplayName: "gh-shorthand",
-		Description: "GitHub autocompletion tools for Alfred",
+		Name:        serviceName,
+		DisplayName: serviceName,
+		Description: description,
 		Arguments:   []string{"server", "run"},
 		Option: service.KeyValue{
 			"UserService": true, // run as current user, not root
@@ -54,6 +57,19 @@ func Service(cfg config.Config) service.Service {
 		},
 	}
 
+	// a user unit, rather than the service library's system unit
+	if service.Platform() == systemdPlatform {
+		execPath, err := os.Executable()
+		if err != nil {
+			log.Fatalf("couldn't find executable: %s", err.Error())
+		}
+		unit, err := renderUnit(serviceUnitTemplate, newUnitData(description, execPath, sc.Arguments, cfg))
+		if err != nil {
+			log.Fatalf("couldn't render systemd unit: %s", err.Error())
+		}
+		sc.Option["SystemdScript"] = unit
+	}
+
 	svc, err := service.New(&server, &sc)
 	if err != nil {
 		log.Fatalf("couldn't create daemon: %s", err.Error())
@@ -87,65 +103,142 @@ func (s *server) Stop(service.Service) error {
 	return nil
 }
 
-// struct to wrap a service logger with an interface for middleware to use
-type mwLogger struct {
-	logger service.Logger
+// listeners for the RPC server
+type listeners struct {
+	sock      net.Listener
+	ownSocket bool         // whether to remove the socket file on shutdown
+	tcp       net.Listener // only if listen_addr is configured
 }
 
-func (ml mwLogger) Print(v ...interface{}) {
-	_ = ml.logger.Info(v...)
+// run the gh-shorthand RPC server on the configured unix socket path, and on
+// the TCP listen address if there is one
+func (s *server) run(svcLogger service.Logger) error {
+	// the level can be changed by reloading the config
+	level := new(slog.LevelVar)
+	level.Set(s.cfg.Level())
+	logger, closeLog, err := newLogger(s.cfg, svcLogger, level)
+	if err != nil {
+		_ = svcLogger.Error(err)
+		return err
+	}
+	defer closeLog()
+
+	ls, err := s.listen(logger)
+	if err != nil {
+		logger.Error("couldn't start server", "error", err)
+		return err
+	}
+	return s.serve(logger, level, ls)
 }
 
-// run the gh-shorthand RPC server on the configured unix socket path
-func (s *server) run(logger service.Logger) error {
-	r := chi.NewRouter()
+func (s *server) listen(logger *slog.Logger) (listeners, error) {
+	var ls listeners
+
+	// use the socket from systemd if it started the server, otherwise make one
+	sock, err := systemdListener()
+	if err != nil {
+		return ls, err
+	}
+	if sock != nil {
+		logger.Info("using socket from systemd")
+	} else {
+		sock, err = net.Listen("unix", s.cfg.SocketPath)
+		if err != nil {
+			return ls, err
+		}
+		ls.ownSocket = true
+	}
+	ls.sock = sock
 
-	formatter := middleware.DefaultLogFormatter{
-		Logger:  mwLogger{logger: logger},
-		NoColor: !service.Interactive(), // double negative! interactive means color.
+	if len(s.cfg.ListenAddr) > 0 {
+		ls.tcp, err = net.Listen("tcp", s.cfg.ListenAddr)
+		if err != nil {
+			sock.Close()
+			return ls, err
+		}
 	}
-	r.Use(middleware.RequestLogger(&formatter))
 
+	return ls, nil
+}
+
+// serve RPC requests on the listeners until the server is stopped
+func (s *server) serve(logger *slog.Logger, level *slog.LevelVar, ls listeners) error {
+	r := chi.NewRouter()
 	h := rpc.NewHandler(s.cfg, logger)
 	h.Mount(r)
 
-	server := &http.Server{
-		Handler:           r,
-		ReadTimeout:       time.Second,
-		ReadHeaderTimeout: time.Second,
-		WriteTimeout:      time.Second,
+	apply := func(cfg config.Config) {
+		h.Reconfigure(cfg)
+		level.Set(cfg.Level())
 	}
+	reloader := configReloader{cfg: s.cfg, apply: apply, logger: logger}
+	go reloader.watch(s.stop)
 
-	sock, err := net.Listen("unix", s.cfg.SocketPath)
-	if err != nil {
-		_ = logger.Error(err)
-		return err
+	if ls.ownSocket {
+		defer func() {
+			os.Remove(s.cfg.SocketPath)
+		}()
 	}
-	defer func() {
-		os.Remove(s.cfg.SocketPath)
-	}()
 
+	server := newHTTPServer(r)
 	go func() {
-		_ = logger.Infof("server started on %s\n", s.cfg.SocketPath)
-		if err := server.Serve(sock); err != nil {
+		logger.Info("server started", "socket_path", s.cfg.SocketPath)
+		if err := server.Serve(ls.sock); err != nil {
 			if err != http.ErrServerClosed {
-				_ = logger.Error("server error", err)
+				logger.Error("server error", "error", err)
 				return
 			}
 		}
 		defer close(s.done)
 	}()
 
+	servers := []*http.Server{server}
+	if ls.tcp != nil {
+		tcpServer := newHTTPServer(requireSecret(s.cfg.RPCSecret, logger, r))
+		servers = append(servers, tcpServer)
+		go func() {
+			logger.Info("listening on tcp", "addr", ls.tcp.Addr().String())
+			if err := tcpServer.Serve(ls.tcp); err != nil && err != http.ErrServerClosed {
+				logger.Error("tcp server error", "error", err)
+			}
+		}()
+	}
+
 	// wait for service to be stopped
 	<-s.stop
 
-	_ = logger.Infof("shutting down server")
+	logger.Info("shutting down server")
 	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
 	defer cancel()
 
-	if err := server.Shutdown(ctx); err != nil {
-		_ = logger.Error("server shutdown error", err)
+	for _, server := range servers {
+		if err := server.Shutdown(ctx); err != nil {
+			logger.Error("server shutdown error", "error", err)
+		}
 	}
 
 	return nil
 }
+
+func newHTTPServer(handler http.Handler) *http.Server {
+	return &http.Server{
+		Handler:           handler,
+		ReadTimeout:       time.Second,
+		ReadHeaderTimeout: time.Second,
+		WriteTimeout:      time.Second,
+	}
+}
+
+// requireSecret rejects requests that don't have the shared secret. Unlike the
+// socket, a TCP port can be reached by any local user or process.
+func requireSecret(secret string, logger *slog.Logger, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		given := r.Header.Get(rpc.SecretHeader)
+		if subtle.ConstantTimeCompare([]byte(given), []byte(secret)) != 1 {
+			logger.Warn("rejected request without the rpc secret", "remote_addr", r.RemoteAddr)
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,61 @@
+package server
+
+import (
+	"log/slog"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zerowidth/gh-shorthand/pkg/config"
+	"github.com/zerowidth/gh-shorthand/pkg/rpc"
+)
+
+func TestServerTransports(t *testing.T) {
+	cfg := config.Config{
+		APIToken:   "token",
+		SocketPath: filepath.Join(t.TempDir(), "gh-shorthand.sock"),
+		ListenAddr: "127.0.0.1:0",
+		RPCSecret:  "hunter2",
+	}
+	s := &server{cfg: cfg, stop: make(chan interface{}), done: make(chan interface{})}
+	logger := (&testLogger{}).logger()
+
+	ls, err := s.listen(logger)
+	require.NoError(t, err)
+	require.NotNil(t, ls.tcp)
+	stopped := make(chan error)
+	go func() { stopped <- s.serve(logger, new(slog.LevelVar), ls) }()
+	defer func() {
+		close(s.stop)
+		assert.NoError(t, <-stopped)
+		assert.NoFileExists(t, cfg.SocketPath, "removes the socket")
+	}()
+
+	tcpAddr := ls.tcp.Addr().String()
+	for name, client := range map[string]rpc.Client{
+		"unix": rpc.NewClient(cfg),
+		"tcp":  rpc.NewClient(config.Config{ServerAddr: tcpAddr, RPCSecret: "hunter2"}),
+	} {
+		t.Run(name, func(t *testing.T) {
+			// an unsupported action fails without needing the GitHub API
+			key := rpc.BatchKey("user", name)
+			var res rpc.Result
+			require.Eventually(t, func() bool {
+				res = client.Batch([]string{key})[key]
+				return res.Complete
+			}, time.Second, 10*time.Millisecond)
+			assert.Equal(t, "unsupported batch action: user", res.Error)
+		})
+	}
+
+	t.Run("tcp without the secret", func(t *testing.T) {
+		for _, secret := range []string{"", "hunter3"} {
+			client := rpc.NewClient(config.Config{ServerAddr: tcpAddr, RPCSecret: secret})
+			res := client.Query("/repo", "zerowidth/gh-shorthand")
+			assert.True(t, res.Complete)
+			assert.Equal(t, "RPC service error: 401 Unauthorized", res.Error)
+		}
+	})
+}
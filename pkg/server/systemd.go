@@ -0,0 +1,249 @@
+package server
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/kardianos/service"
+	"github.com/zerowidth/gh-shorthand/pkg/config"
+)
+
+// the service platform for systemd, as reported by service.Platform()
+const systemdPlatform = "linux-systemd"
+
+const (
+	serviceName = "gh-shorthand"
+	socketUnit  = serviceName + ".socket"
+	serviceUnit = serviceName + ".service"
+)
+
+// the first file descriptor passed by systemd socket activation
+const listenFdsStart = 3
+
+// unitData fills in the systemd unit templates
+type unitData struct {
+	Description string
+	ExecStart   string
+	SocketPath  string
+	Path        string // $PATH for the service
+}
+
+// The user service. It's started by its socket, or at login, and reloads its
+// config on SIGHUP. $PATH is kept from when it was installed, and any other
+// environment, e.g. for commands that fetch a token, can be set in
+// ~/.config/gh-shorthand/environment.
+var serviceUnitTemplate = template.Must(template.New("service").Parse(`[Unit]
+Description={{.Description}}
+Requires=` + socketUnit + `
+After=` + socketUnit + `
+
+[Service]
+ExecStart={{.ExecStart}}
+ExecReload=/bin/kill -HUP $MAINPID
+Restart=on-failure
+RestartSec=5
+Environment={{.Path}}
+EnvironmentFile=-%h/.config/gh-shorthand/environment
+
+[Install]
+WantedBy=default.target
+`))
+
+// The socket for the user service, created by systemd so the service can be
+// started on demand.
+var socketUnitTemplate = template.Must(template.New("socket").Parse(`[Unit]
+Description={{.Description}} socket
+
+[Socket]
+ListenStream={{.SocketPath}}
+SocketMode=0600
+RemoveOnStop=true
+
+[Install]
+WantedBy=sockets.target
+`))
+
+func newUnitData(description, execPath string, args []string, cfg config.Config) unitData {
+	execStart := []string{systemdQuote(execPath)}
+	for _, arg := range args {
+		execStart = append(execStart, systemdQuote(arg))
+	}
+	return unitData{
+		Description: description,
+		ExecStart:   strings.Join(execStart, " "),
+		SocketPath:  systemdEscape(cfg.SocketPath),
+		Path:        systemdQuote("PATH=" + os.Getenv("PATH")),
+	}
+}
+
+func renderUnit(t *template.Template, data unitData) (string, error) {
+	var b strings.Builder
+	if err := t.Execute(&b, data); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// systemdEscape escapes the specifiers and variables systemd expands in unit
+// files.
+func systemdEscape(s string) string {
+	s = strings.ReplaceAll(s, "%", "%%")
+	return strings.ReplaceAll(s, "$", "$$")
+}
+
+// systemdQuote escapes a word for a command line or an assignment in a unit
+// file, quoting it if it contains whitespace or quotes.
+func systemdQuote(s string) string {
+	s = systemdEscape(s)
+	if !strings.ContainsAny(s, " \t\"'\\") {
+		return s
+	}
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	return `"` + s + `"`
+}
+
+// systemdListener returns the socket passed by systemd socket activation, or
+// nil if the server wasn't started that way.
+func systemdListener() (net.Listener, error) {
+	return activationListener(listenFdsStart)
+}
+
+// activationListener returns a listener for the first socket passed by
+// systemd, at the given file descriptor.
+func activationListener(fd uintptr) (net.Listener, error) {
+	pid, err := strconv.Atoi(os.Getenv("LISTEN_PID"))
+	if err != nil || pid != os.Getpid() {
+		return nil, nil
+	}
+	fds, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || fds < 1 {
+		return nil, fmt.Errorf("socket activation: invalid LISTEN_FDS %q", os.Getenv("LISTEN_FDS"))
+	}
+	// these are only meant for this process, not its children
+	os.Unsetenv("LISTEN_PID")
+	os.Unsetenv("LISTEN_FDS")
+	os.Unsetenv("LISTEN_FDNAMES")
+
+	f := os.NewFile(fd, "systemd socket")
+	defer f.Close()
+	return net.FileListener(f)
+}
+
+// systemdUnitPath returns the path to a user unit file, where the service
+// library also installs the service unit.
+func systemdUnitPath(name string) (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".config", "systemd", "user", name), nil
+}
+
+func systemctl(args ...string) error {
+	out, err := exec.Command("systemctl", append([]string{"--user"}, args...)...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("systemctl %s: %w: %s", strings.Join(args, " "), err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// Control runs a service control action: install, uninstall, start, stop or
+// restart. With systemd, the socket unit is managed along with the service.
+func Control(cfg config.Config, action string) error {
+	svc := Service(cfg)
+	if service.Platform() != systemdPlatform {
+		return service.Control(svc, action)
+	}
+
+	switch action {
+	case "install":
+		if err := service.Control(svc, action); err != nil {
+			return err
+		}
+		return installSocket(cfg)
+	case "uninstall":
+		if err := removeSocket(); err != nil {
+			return err
+		}
+		return service.Control(svc, action)
+	case "start":
+		if err := systemctl("start", socketUnit); err != nil {
+			return err
+		}
+		return systemctl("start", serviceUnit)
+	case "stop":
+		// stop the socket first, so a request can't start the service again
+		if err := systemctl("stop", socketUnit); err != nil {
+			return err
+		}
+		return systemctl("stop", serviceUnit)
+	default:
+		return service.Control(svc, action)
+	}
+}
+
+func installSocket(cfg config.Config) error {
+	path, err := systemdUnitPath(socketUnit)
+	if err != nil {
+		return err
+	}
+	unit, err := renderUnit(socketUnitTemplate, newUnitData(description, "", nil, cfg))
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, []byte(unit), 0o644); err != nil {
+		return err
+	}
+	if err := systemctl("daemon-reload"); err != nil {
+		return err
+	}
+	return systemctl("enable", socketUnit)
+}
+
+func removeSocket() error {
+	path, err := systemdUnitPath(socketUnit)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return nil
+	}
+	if err := systemctl("disable", "--now", socketUnit); err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
+
+// Status describes the status of the installed service
+func Status(cfg config.Config) (string, error) {
+	if service.Platform() == systemdPlatform {
+		// the service library doesn't check user services
+		var lines []string
+		for _, unit := range []string{serviceUnit, socketUnit} {
+			out, _ := exec.Command("systemctl", "--user", "is-active", unit).Output()
+			lines = append(lines, fmt.Sprintf("%s: %s", unit, strings.TrimSpace(string(out))))
+		}
+		return strings.Join(lines, "\n"), nil
+	}
+
+	status, err := Service(cfg).Status()
+	switch {
+	case err == service.ErrNotInstalled:
+		return "not installed", nil
+	case err != nil:
+		return "", err
+	case status == service.StatusRunning:
+		return "running", nil
+	case status == service.StatusStopped:
+		return "stopped", nil
+	default:
+		return "unknown", nil
+	}
+}
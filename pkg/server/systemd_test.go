@@ -0,0 +1,111 @@
+package server
+
+import (
+	"net"
+	"os"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zerowidth/gh-shorthand/pkg/config"
+)
+
+func TestServiceUnit(t *testing.T) {
+	t.Setenv("PATH", "/usr/local/bin:/usr/bin")
+	cfg := config.Config{SocketPath: "/tmp/gh-shorthand.sock"}
+	data := newUnitData(description, "/home/me/go/bin/gh-shorthand", []string{"server", "run"}, cfg)
+
+	unit, err := renderUnit(serviceUnitTemplate, data)
+	require.NoError(t, err)
+	assert.Equal(t, `[Unit]
+Description=GitHub autocompletion tools for Alfred
+Requires=gh-shorthand.socket
+After=gh-shorthand.socket
+
+[Service]
+ExecStart=/home/me/go/bin/gh-shorthand server run
+ExecReload=/bin/kill -HUP $MAINPID
+Restart=on-failure
+RestartSec=5
+Environment=PATH=/usr/local/bin:/usr/bin
+EnvironmentFile=-%h/.config/gh-shorthand/environment
+
+[Install]
+WantedBy=default.target
+`, unit)
+}
+
+func TestSocketUnit(t *testing.T) {
+	cfg := config.Config{SocketPath: "/run/user/1000/gh-shorthand.sock"}
+	unit, err := renderUnit(socketUnitTemplate, newUnitData(description, "", nil, cfg))
+	require.NoError(t, err)
+	assert.Equal(t, `[Unit]
+Description=GitHub autocompletion tools for Alfred socket
+
+[Socket]
+ListenStream=/run/user/1000/gh-shorthand.sock
+SocketMode=0600
+RemoveOnStop=true
+
+[Install]
+WantedBy=sockets.target
+`, unit)
+}
+
+func TestSystemdQuote(t *testing.T) {
+	for input, quoted := range map[string]string{
+		"/usr/bin/gh-shorthand":     "/usr/bin/gh-shorthand",
+		"/home/me/my apps/gh":       `"/home/me/my apps/gh"`,
+		`/tmp/"quoted"`:             `"/tmp/\"quoted\""`,
+		"PATH=/bin:/opt/100%/bin":   "PATH=/bin:/opt/100%%/bin",
+		"PATH=$HOME/bin:/usr/bin":   "PATH=$$HOME/bin:/usr/bin",
+		`PATH=C:\bin with spaces`:   `"PATH=C:\\bin with spaces"`,
+		"ListenStream=/tmp/%i.sock": "ListenStream=/tmp/%%i.sock",
+	} {
+		assert.Equal(t, quoted, systemdQuote(input), input)
+	}
+}
+
+func TestSystemdListener(t *testing.T) {
+	t.Run("not socket activated", func(t *testing.T) {
+		t.Setenv("LISTEN_PID", "")
+		t.Setenv("LISTEN_FDS", "")
+		l, err := systemdListener()
+		assert.NoError(t, err)
+		assert.Nil(t, l)
+	})
+
+	t.Run("activated for another process", func(t *testing.T) {
+		t.Setenv("LISTEN_PID", strconv.Itoa(os.Getpid()+1))
+		t.Setenv("LISTEN_FDS", "1")
+		l, err := systemdListener()
+		assert.NoError(t, err)
+		assert.Nil(t, l)
+	})
+
+	t.Run("invalid fds", func(t *testing.T) {
+		t.Setenv("LISTEN_PID", strconv.Itoa(os.Getpid()))
+		t.Setenv("LISTEN_FDS", "0")
+		_, err := systemdListener()
+		assert.EqualError(t, err, `socket activation: invalid LISTEN_FDS "0"`)
+	})
+
+	t.Run("socket activated", func(t *testing.T) {
+		// stand in for systemd by passing a listening socket
+		path := t.TempDir() + "/test.sock"
+		orig, err := net.Listen("unix", path)
+		require.NoError(t, err)
+		defer orig.Close()
+		f, err := orig.(*net.UnixListener).File()
+		require.NoError(t, err)
+
+		t.Setenv("LISTEN_PID", strconv.Itoa(os.Getpid()))
+		t.Setenv("LISTEN_FDS", "1")
+		l, err := activationListener(f.Fd())
+		require.NoError(t, err)
+		defer l.Close()
+		assert.Equal(t, path, l.Addr().String())
+		assert.Empty(t, os.Getenv("LISTEN_PID"), "unsets the environment")
+	})
+}
@@ -0,0 +1,245 @@
+package snippets
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/zerowidth/gh-shorthand/pkg/config"
+	"github.com/zerowidth/gh-shorthand/pkg/parser"
+	"github.com/zerowidth/gh-shorthand/pkg/rpc"
+)
+
+// rangeRegex matches a range of commits, e.g. v1.0...main, with an optional
+// repo before an @.
+var rangeRegex = regexp.MustCompile(`^(?:(\S+)@)?(\S+?)\.\.\.(\S+)$`)
+
+// OtherChangesTitle is the title of the section for PRs without any of the
+// labels of the configured changelog sections.
+const OtherChangesTitle = "Other changes"
+
+// How long to wait for the PRs in a range, which takes a few API calls
+const rangeTimeout = 30 * time.Second
+
+// Changelog writes release notes in markdown for a list of PRs, given as URLs
+// or references such as owner/name#123 or shorthand. A range of commits, e.g.
+// v1.0...v1.1, with an optional repo as in gs@v1.0...v1.1, adds the PRs
+// merged in that range.
+//
+// The PRs' titles, authors and labels are looked up over RPC, and the PRs are
+// grouped into the configured changelog sections by label. Each PR is rendered
+// with the changelog link template.
+func Changelog(rpcClient rpc.Client, cfg config.Config, refs []string, w io.Writer) error {
+	templates, err := NewTemplates(cfg)
+	if err != nil {
+		return err
+	}
+
+	var queries []changelogQuery
+	for _, ref := range refs {
+		q, err := parseChangelogRef(cfg, ref)
+		if err != nil {
+			return err
+		}
+		queries = append(queries, q)
+	}
+
+	// look up the PRs in a single batch, and the ranges alongside it, keeping
+	// the results in order
+	results := make([][]LinkData, len(queries))
+	errs := make([]error, len(queries))
+	var wg sync.WaitGroup
+	var keys []string
+	for i, q := range queries {
+		if len(q.rangeQuery) == 0 {
+			keys = append(keys, q.key())
+			continue
+		}
+		wg.Add(1)
+		go func(i int, q changelogQuery) {
+			defer wg.Done()
+			results[i], errs[i] = q.lookupRange(rpcClient)
+		}(i, q)
+	}
+	if len(keys) > 0 {
+		batch, _ := fetchBatch(rpcClient, keys, rpcTimeout)
+		for i, q := range queries {
+			if len(q.rangeQuery) == 0 {
+				results[i], errs[i] = q.fromResult(batch[q.key()])
+			}
+		}
+	}
+	wg.Wait()
+
+	var entries []LinkData
+	seen := map[string]bool{}
+	for i, result := range results {
+		if errs[i] != nil {
+			return errs[i]
+		}
+		for _, data := range result {
+			if key := strings.ToLower(data.Ref()); !seen[key] {
+				seen[key] = true
+				entries = append(entries, data)
+			}
+		}
+	}
+
+	for i, section := range groupChangelog(cfg.ChangelogSections, entries) {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		if len(section.title) > 0 {
+			fmt.Fprintf(w, "### %s\n\n", section.title)
+		}
+		for _, data := range section.entries {
+			line, err := templates.Render(ChangelogTemplate, data)
+			if err != nil {
+				return err
+			}
+			if _, err := fmt.Fprintln(w, line); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// changelogQuery is a single PR, or a range of commits, to look up
+type changelogQuery struct {
+	ref        string
+	rangeQuery string   // for a range
+	data       LinkData // for a single PR
+}
+
+func parseChangelogRef(cfg config.Config, ref string) (changelogQuery, error) {
+	if m := rangeRegex.FindStringSubmatch(ref); m != nil {
+		repo := cfg.DefaultRepo
+		if len(m[1]) > 0 {
+			parsed := parser.NewParser(cfg.RepoMap, cfg.UserMap, "", parser.RequireRepo).Parse(m[1])
+			repo = parsed.Repo()
+		}
+		if len(repo) == 0 {
+			return changelogQuery{}, fmt.Errorf("no repo for range %s", ref)
+		}
+		return changelogQuery{
+			ref:        ref,
+			rangeQuery: fmt.Sprintf("%s %s...%s", repo, m[2], m[3]),
+		}, nil
+	}
+
+	var data LinkData
+	if m := issueRegex.FindStringSubmatch(ref); m != nil {
+		data = LinkData{Repo: m[2] + "/" + m[3], Number: m[5], URL: m[1]}
+	} else {
+		p := parser.NewParser(cfg.RepoMap, cfg.UserMap, cfg.DefaultRepo, parser.RequireRepo, parser.RequireIssue)
+		parsed := p.Parse(ref)
+		if !parsed.HasIssue() {
+			return changelogQuery{}, fmt.Errorf("not a PR or range: %s", ref)
+		}
+		data = LinkData{
+			Repo:   parsed.Repo(),
+			Number: parsed.Issue,
+			URL:    fmt.Sprintf("https://github.com/%s/pull/%s", parsed.Repo(), parsed.Issue),
+		}
+	}
+	return changelogQuery{ref: ref, data: data}, nil
+}
+
+// key is the batch key for a single PR
+func (q changelogQuery) key() string {
+	return rpc.BatchKey("issue", q.data.Ref())
+}
+
+func (q changelogQuery) fromResult(res rpc.Result) ([]LinkData, error) {
+	switch {
+	case !res.Complete:
+		return nil, fmt.Errorf("%s: rpc timed out", q.ref)
+	case len(res.Error) > 0:
+		return nil, fmt.Errorf("%s: rpc error: %s", q.ref, res.Error)
+	case len(res.Issues) == 0:
+		return nil, fmt.Errorf("%s: rpc error: no data returned", q.ref)
+	}
+	return []LinkData{q.data.WithIssue(res.Issues[0])}, nil
+}
+
+func (q changelogQuery) lookupRange(rpcClient rpc.Client) ([]LinkData, error) {
+	res, ok := fetchWithin(rpcClient, "/merged", q.rangeQuery, rangeTimeout)
+	switch {
+	case !ok:
+		return nil, fmt.Errorf("%s: rpc timed out", q.ref)
+	case len(res.Error) > 0:
+		return nil, fmt.Errorf("%s: rpc error: %s", q.ref, res.Error)
+	}
+
+	var prs []LinkData
+	for _, issue := range res.Issues {
+		data := LinkData{
+			Repo:   issue.Repo,
+			Number: issue.Number,
+			URL:    fmt.Sprintf("https://github.com/%s/pull/%s", issue.Repo, issue.Number),
+		}
+		prs = append(prs, data.WithIssue(issue))
+	}
+	return prs, nil
+}
+
+type changelogSection struct {
+	title   string
+	entries []LinkData
+}
+
+// groupChangelog puts each entry in the first section with one of its labels,
+// or the section for other changes. Empty sections are left out. Without any
+// configured sections, there's a single section without a title.
+func groupChangelog(sections []config.ChangelogSection, entries []LinkData) []changelogSection {
+	if len(sections) == 0 {
+		return []changelogSection{{entries: entries}}
+	}
+
+	grouped := make([]changelogSection, len(sections))
+	other := -1
+	for i, section := range sections {
+		grouped[i].title = section.Title
+		if len(section.Labels) == 0 && other < 0 {
+			other = i
+		}
+	}
+	if other < 0 {
+		grouped = append(grouped, changelogSection{title: OtherChangesTitle})
+		other = len(grouped) - 1
+	}
+
+	for _, data := range entries {
+		i := sectionFor(sections, data.Labels)
+		if i < 0 {
+			i = other
+		}
+		grouped[i].entries = append(grouped[i].entries, data)
+	}
+
+	var nonEmpty []changelogSection
+	for _, section := range grouped {
+		if len(section.entries) > 0 {
+			nonEmpty = append(nonEmpty, section)
+		}
+	}
+	return nonEmpty
+}
+
+func sectionFor(sections []config.ChangelogSection, labels []string) int {
+	for i, section := range sections {
+		for _, want := range section.Labels {
+			for _, label := range labels {
+				if strings.EqualFold(want, label) {
+					return i
+				}
+			}
+		}
+	}
+	return -1
+}
@@ -0,0 +1,100 @@
+package snippets
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zerowidth/gh-shorthand/pkg/config"
+	"github.com/zerowidth/gh-shorthand/pkg/rpc"
+)
+
+func changelogClient() *mapClient {
+	return &mapClient{
+		results: map[string]rpc.Result{
+			"issue:zw/df#1": {Complete: true, Issues: []rpc.Issue{
+				{Repo: "zw/df", Number: "1", Title: "Fix a bug", Author: "alice", Labels: []string{"Bug"}}}},
+			"issue:zw/df#2": {Complete: true, Issues: []rpc.Issue{
+				{Repo: "zw/df", Number: "2", Title: "Add a feature", Author: "bob", Labels: []string{"enhancement"}}}},
+			"merged:zw/df v1.0...main": {Complete: true, Issues: []rpc.Issue{
+				{Repo: "zw/df", Number: "2", Title: "Add a feature", Author: "bob", Labels: []string{"enhancement"}},
+				{Repo: "zw/df", Number: "3", Title: "Update docs", Author: "carol"},
+			}},
+		},
+		lookups: map[string]int{},
+	}
+}
+
+func changelog(t *testing.T, cfg config.Config, refs ...string) string {
+	var out bytes.Buffer
+	client := changelogClient()
+	require.NoError(t, Changelog(client, cfg, refs, &out))
+	assert.LessOrEqual(t, client.batches, 1, "looks up PRs in a single batch")
+	return out.String()
+}
+
+func TestChangelog(t *testing.T) {
+	cfg := config.Config{
+		DefaultRepo: "zw/df",
+		RepoMap:     map[string]string{"df": "zw/df"},
+	}
+
+	t.Run("lists PRs without sections", func(t *testing.T) {
+		assert.Equal(t,
+			"* Fix a bug ([zw/df#1](https://github.com/zw/df/pull/1)) by @alice\n"+
+				"* Add a feature ([zw/df#2](https://github.com/zw/df/pull/2)) by @bob\n",
+			changelog(t, cfg, "df#1", "https://github.com/zw/df/pull/2"))
+	})
+
+	t.Run("adds merged PRs from a range, without duplicates", func(t *testing.T) {
+		assert.Equal(t,
+			"* Add a feature ([zw/df#2](https://github.com/zw/df/pull/2)) by @bob\n"+
+				"* Update docs ([zw/df#3](https://github.com/zw/df/pull/3)) by @carol\n",
+			changelog(t, cfg, "#2", "df@v1.0...main"))
+	})
+
+	t.Run("groups PRs into sections by label", func(t *testing.T) {
+		cfg := cfg
+		cfg.ChangelogSections = []config.ChangelogSection{
+			{Title: "Features", Labels: []string{"enhancement"}},
+			{Title: "Fixes", Labels: []string{"bug"}},
+			{Title: "Security", Labels: []string{"security"}},
+		}
+		assert.Equal(t,
+			"### Features\n\n"+
+				"* Add a feature ([zw/df#2](https://github.com/zw/df/pull/2)) by @bob\n"+
+				"\n### Fixes\n\n"+
+				"* Fix a bug ([zw/df#1](https://github.com/zw/df/pull/1)) by @alice\n"+
+				"\n### Other changes\n\n"+
+				"* Update docs ([zw/df#3](https://github.com/zw/df/pull/3)) by @carol\n",
+			changelog(t, cfg, "1", "v1.0...main"))
+	})
+
+	t.Run("uses a section without labels for other changes", func(t *testing.T) {
+		cfg := cfg
+		cfg.ChangelogSections = []config.ChangelogSection{
+			{Title: "Changes"},
+			{Title: "Fixes", Labels: []string{"bug"}},
+		}
+		assert.Equal(t,
+			"### Changes\n\n"+
+				"* Add a feature ([zw/df#2](https://github.com/zw/df/pull/2)) by @bob\n"+
+				"\n### Fixes\n\n"+
+				"* Fix a bug ([zw/df#1](https://github.com/zw/df/pull/1)) by @alice\n",
+			changelog(t, cfg, "1", "2"))
+	})
+
+	t.Run("returns an error for a failed lookup", func(t *testing.T) {
+		var out bytes.Buffer
+		err := Changelog(changelogClient(), cfg, []string{"1", "9"}, &out)
+		assert.EqualError(t, err, "9: rpc error: not found")
+		assert.Empty(t, out.String())
+	})
+
+	t.Run("returns an error for an invalid ref", func(t *testing.T) {
+		var out bytes.Buffer
+		err := Changelog(changelogClient(), cfg, []string{"foo bar"}, &out)
+		assert.EqualError(t, err, "not a PR or range: foo bar")
+	})
+}
@@ -0,0 +1,73 @@
+package snippets
+
+import (
+	"fmt"
+	"html"
+	"sort"
+	"strings"
+)
+
+// Format renders links in a particular kind of markup
+type Format struct {
+	Name  string // the name used in flags and config, e.g. "slack"
+	Title string // a readable name, e.g. "Slack"
+	link  func(text, url string) string
+}
+
+// Link renders a link to a URL with the given text
+func (f Format) Link(text, url string) string {
+	return f.link(text, url)
+}
+
+// Markdown is the default link format
+var Markdown = Format{"markdown", "Markdown", func(text, url string) string {
+	return fmt.Sprintf("[%s](%s)", friendlierMarkdown(text), url)
+}}
+
+var formats = map[string]Format{
+	"markdown": Markdown,
+	"html": {"html", "HTML", func(text, url string) string {
+		return fmt.Sprintf(`<a href="%s">%s</a>`, html.EscapeString(url), html.EscapeString(text))
+	}},
+	"slack": {"slack", "Slack", func(text, url string) string {
+		return fmt.Sprintf("<%s|%s>", url, slackEscaper.Replace(text))
+	}},
+	"org": {"org", "Org", func(text, url string) string {
+		return fmt.Sprintf("[[%s][%s]]", url, bracketReplacer.Replace(text))
+	}},
+	"rst": {"rst", "reStructuredText", func(text, url string) string {
+		// a trailing __ makes an anonymous link, so the same text can be used
+		// for more than one link in a document
+		return fmt.Sprintf("`%s <%s>`__", rstEscaper.Replace(text), url)
+	}},
+	"asciidoc": {"asciidoc", "AsciiDoc", func(text, url string) string {
+		return fmt.Sprintf("%s[%s]", url, strings.ReplaceAll(text, "]", `\]`))
+	}},
+}
+
+var (
+	slackEscaper    = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+	bracketReplacer = strings.NewReplacer("[", "(", "]", ")")
+	rstEscaper      = strings.NewReplacer("\\", "\\\\", "`", "\\`", "<", "\\<")
+)
+
+// LookupFormat returns the link format with the given name
+func LookupFormat(name string) (Format, error) {
+	if f, ok := formats[name]; ok {
+		return f, nil
+	}
+	return Format{}, fmt.Errorf("unknown link format %q, must be one of: %s",
+		name, strings.Join(Formats(), ", "))
+}
+
+// Formats lists the names of the link formats, markdown first
+func Formats() []string {
+	names := []string{}
+	for name := range formats {
+		if name != Markdown.Name {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return append([]string{Markdown.Name}, names...)
+}
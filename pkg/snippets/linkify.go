@@ -0,0 +1,365 @@
+package snippets
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/zerowidth/gh-shorthand/pkg/rpc"
+)
+
+// candidateRegex finds anything that might be linkified: a github URL, up to
+// but not including trailing punctuation, or an owner/repo#123 reference.
+var candidateRegex = regexp.MustCompile(`https://github\.com/[^\s<>()\[\]]*[^\s<>()\[\].,;:!?'"]|[A-Za-z0-9][-A-Za-z0-9]*/[\w.-]*\w#[1-9]\d*`)
+
+var (
+	linkIssueRegex          = regexp.MustCompile(`^https://github\.com/([^/]+)/([^/]+)/(issues|pull|discussions)/(\d+)(#\S*)?$`)
+	linkTeamDiscussionRegex = regexp.MustCompile(`^https://github\.com/orgs/([^/]+)/teams/([^/]+)/discussions/(\d+)(#\S*)?$`)
+	linkRepoRegex           = regexp.MustCompile(`^https://github\.com/([^/]+)/([^/#?]+)/?$`)
+	linkReferenceRegex      = regexp.MustCompile(`^([A-Za-z0-9][-A-Za-z0-9]*/[\w.-]+)#(\d+)$`)
+)
+
+// existingLinkRegex matches markdown and html links, and urls in angle
+// brackets, which are all left as they are.
+var existingLinkRegex = regexp.MustCompile(`!?\[[^\]]*\]\([^)]*\)|\[[^\]]*\]\[[^\]]*\]|<https?://[^>]*>|<a\s[^>]*>.*?</a>`)
+
+// linkDefinitionRegex matches a reference link definition, e.g. "[1]: url"
+var linkDefinitionRegex = regexp.MustCompile(`^ {0,3}\[[^\]]+\]:`)
+
+// fenceRegex matches the start or end of a fenced code block, along with any
+// info string after the fence, e.g. the language
+var fenceRegex = regexp.MustCompile("^ {0,3}(`{3,}|~{3,})(.*)")
+
+// How many lines can be waiting on RPC lookups at once
+const linkifyBuffer = 64
+
+// Linkify reads text or markdown and writes it back out with every github
+// issue, PR, discussion and repo URL, and every owner/repo#123 reference,
+// converted to a markdown link. Fenced and indented code blocks, inline code
+// and existing links are left alone.
+//
+// If includeDesc is set, titles and descriptions are looked up over RPC. The
+// lookups are made together in batches, and the output is written in order as
+// it's ready.
+func Linkify(rpcClient rpc.Client, r io.Reader, w io.Writer, includeDesc bool) error {
+	l := &linkifier{
+		rpcClient:   rpcClient,
+		includeDesc: includeDesc,
+		lookups:     map[string]*lookup{},
+		wake:        make(chan struct{}, 1),
+	}
+
+	if includeDesc {
+		stop := make(chan struct{})
+		defer close(stop)
+		go l.lookupAll(stop)
+	}
+
+	lines := make(chan chan string, linkifyBuffer)
+	readErr := make(chan error, 1)
+	go func() {
+		defer close(lines)
+		readErr <- l.read(bufio.NewReader(r), lines)
+	}()
+
+	var writeErr error
+	for line := range lines {
+		if writeErr == nil {
+			_, writeErr = io.WriteString(w, <-line)
+		}
+	}
+	if err := <-readErr; err != nil {
+		return err
+	}
+	return writeErr
+}
+
+type linkifier struct {
+	rpcClient   rpc.Client
+	includeDesc bool
+
+	mu      sync.Mutex
+	lookups map[string]*lookup // by batch key
+	waiting []string           // batch keys of the lookups that aren't done
+	wake    chan struct{}
+}
+
+// lookup is a single RPC lookup, shared by every link to the same thing
+type lookup struct {
+	done     chan struct{}
+	desc     string
+	deadline time.Time
+}
+
+// read splits the input into lines, keeping track of code blocks, and sends
+// each line to be linkified in order.
+//
+// A fenced code block is closed by a fence of the same kind that's at least as
+// long and has no info string. An indented code block starts with a line
+// indented by four spaces or a tab after a blank line or a closed fence, and
+// continues until a line that isn't indented.
+func (l *linkifier) read(r *bufio.Reader, lines chan<- chan string) error {
+	fence := ""
+	indented := false
+	blank := true // the start of the input can begin an indented block too
+	for {
+		text, err := r.ReadString('\n')
+		if len(text) > 0 {
+			out := make(chan string, 1)
+			lines <- out
+
+			wasBlank := blank
+			blank = len(strings.TrimSpace(text)) == 0
+			if fence == "" && !blank {
+				indented = (indented || wasBlank) &&
+					(strings.HasPrefix(text, "    ") || strings.HasPrefix(text, "\t"))
+			}
+
+			if m := fenceRegex.FindStringSubmatch(text); m != nil {
+				switch {
+				case fence == "":
+					fence = m[1]
+				case m[1][0] == fence[0] && len(m[1]) >= len(fence) && len(strings.TrimSpace(m[2])) == 0:
+					fence = ""
+					blank = true // there's no paragraph for indentation to continue
+				}
+				out <- text
+			} else if fence != "" || indented || linkDefinitionRegex.MatchString(text) {
+				out <- text
+			} else if l.includeDesc {
+				go func(text string) { out <- l.line(text) }(text)
+			} else {
+				out <- l.line(text)
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// line linkifies everything in a line of text outside of inline code and
+// existing links.
+func (l *linkifier) line(text string) string {
+	skip := codeSpans(text)
+	for _, m := range existingLinkRegex.FindAllStringIndex(text, -1) {
+		if !within(skip, m[0]) {
+			skip = append(skip, m)
+		}
+	}
+
+	// every lookup in the line is started before waiting on any of them, so
+	// they can all be made in the same batch
+	type linked struct {
+		start, end int
+		render     func() string
+	}
+	var links []linked
+	for _, m := range candidateRegex.FindAllStringIndex(text, -1) {
+		if within(skip, m[0]) {
+			continue
+		}
+		// references need to stand on their own, not be part of a path or url
+		if m[0] > 0 && !strings.HasPrefix(text[m[0]:], "https://") && strings.ContainsAny(text[m[0]-1:m[0]], "/.-_@#:") {
+			continue
+		}
+		if render, ok := l.link(text[m[0]:m[1]]); ok {
+			links = append(links, linked{m[0], m[1], render})
+		}
+	}
+
+	var b strings.Builder
+	last := 0
+	for _, link := range links {
+		b.WriteString(text[last:link.start])
+		b.WriteString(link.render())
+		last = link.end
+	}
+	b.WriteString(text[last:])
+	return b.String()
+}
+
+// link converts a url or reference into a markdown link, if it's something
+// that can be linked. Any lookup for the link starts right away, and the
+// returned function waits for it to render the link.
+func (l *linkifier) link(s string) (func() string, bool) {
+	if m := linkTeamDiscussionRegex.FindStringSubmatch(s); m != nil {
+		return rendered(fmt.Sprintf("[@%s/%s#%s](%s)", m[1], m[2], m[3], s)), true
+	}
+
+	if m := linkIssueRegex.FindStringSubmatch(s); m != nil {
+		text := fmt.Sprintf("%s/%s#%s", m[1], m[2], m[4])
+		if m[3] == "discussions" {
+			return rendered(fmt.Sprintf("[%s](%s)", text, s)), true
+		}
+		return l.describe(text, "issue", text, s), true
+	}
+
+	if m := linkReferenceRegex.FindStringSubmatch(s); m != nil {
+		url := fmt.Sprintf("https://github.com/%s/issues/%s", m[1], m[2])
+		return l.describe(s, "issue", s, url), true
+	}
+
+	if m := linkRepoRegex.FindStringSubmatch(s); m != nil && m[1] != "orgs" {
+		repo := m[1] + "/" + m[2]
+		return l.describe(repo, "repo", repo, s), true
+	}
+
+	return nil, false
+}
+
+// rendered returns a link that doesn't need a lookup
+func rendered(link string) func() string {
+	return func() string { return link }
+}
+
+// describe starts a lookup for a link's title or description, and returns a
+// function that waits for it and renders the link with it. Failed lookups
+// leave the text as it is, rather than cluttering the output with errors.
+func (l *linkifier) describe(text, action, query, url string) func() string {
+	if !l.includeDesc {
+		return rendered(fmt.Sprintf("[%s](%s)", text, url))
+	}
+
+	key := rpc.BatchKey(action, query)
+	l.mu.Lock()
+	lu, ok := l.lookups[key]
+	if !ok {
+		lu = &lookup{done: make(chan struct{}), deadline: time.Now().Add(rpcTimeout)}
+		l.lookups[key] = lu
+		l.waiting = append(l.waiting, key)
+		select {
+		case l.wake <- struct{}{}:
+		default:
+		}
+	}
+	l.mu.Unlock()
+
+	return func() string {
+		<-lu.done
+		if lu.desc != "" {
+			text += ": " + friendlierMarkdown(lu.desc)
+		}
+		return fmt.Sprintf("[%s](%s)", text, url)
+	}
+}
+
+// lookupAll makes the RPC lookups for every line, polling with a single batch
+// request for everything that's waiting, until stop is closed.
+func (l *linkifier) lookupAll(stop <-chan struct{}) {
+	for {
+		select {
+		case <-l.wake:
+		case <-stop:
+			return
+		}
+		for l.poll() {
+			time.Sleep(rpcInterval)
+		}
+	}
+}
+
+// poll makes one batch request for the lookups that are waiting, and finishes
+// the ones that are complete or have timed out. Returns true if any are still
+// waiting.
+func (l *linkifier) poll() bool {
+	l.mu.Lock()
+	keys := l.waiting
+	l.mu.Unlock()
+	if len(keys) == 0 {
+		return false
+	}
+
+	results := l.rpcClient.Batch(keys)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var waiting []string
+	for _, key := range keys {
+		lu, res := l.lookups[key], results[key]
+		switch {
+		case res.Complete:
+			if len(res.Error) == 0 {
+				lu.desc = description(res)
+			}
+			close(lu.done)
+		case time.Now().Add(rpcInterval).After(lu.deadline):
+			close(lu.done)
+		default:
+			waiting = append(waiting, key)
+		}
+	}
+	// anything added while the request was being made is still waiting too
+	l.waiting = append(waiting, l.waiting[len(keys):]...)
+	return len(l.waiting) > 0
+}
+
+// description returns the title of an issue or the description of a repo
+func description(res rpc.Result) string {
+	switch {
+	case len(res.Issues) > 0:
+		return res.Issues[0].Title
+	case len(res.Repos) > 0:
+		return res.Repos[0].Description
+	}
+	return ""
+}
+
+// codeSpans finds the inline code in a line: text between runs of the same
+// number of backticks.
+func codeSpans(text string) [][]int {
+	var spans [][]int
+	for i := 0; i < len(text); {
+		if text[i] != '`' {
+			i++
+			continue
+		}
+		n := 0
+		for i+n < len(text) && text[i+n] == '`' {
+			n++
+		}
+		closing := findBackticks(text, i+n, n)
+		if closing < 0 {
+			i += n
+			continue
+		}
+		spans = append(spans, []int{i, closing + n})
+		i = closing + n
+	}
+	return spans
+}
+
+// findBackticks finds a run of exactly n backticks, starting from an offset
+func findBackticks(text string, from, n int) int {
+	for i := from; i < len(text); {
+		if text[i] != '`' {
+			i++
+			continue
+		}
+		run := 0
+		for i+run < len(text) && text[i+run] == '`' {
+			run++
+		}
+		if run == n {
+			return i
+		}
+		i += run
+	}
+	return -1
+}
+
+func within(spans [][]int, i int) bool {
+	for _, s := range spans {
+		if i >= s[0] && i < s[1] {
+			return true
+		}
+	}
+	return false
+}
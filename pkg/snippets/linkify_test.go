@@ -0,0 +1,202 @@
+package snippets
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zerowidth/gh-shorthand/pkg/config"
+	"github.com/zerowidth/gh-shorthand/pkg/rpc"
+)
+
+// mapClient returns results by batch key, counting the lookups and round trips
+// made
+type mapClient struct {
+	mu      sync.Mutex
+	results map[string]rpc.Result
+	lookups map[string]int
+	queries int
+	batches int
+}
+
+func (mc *mapClient) Query(endpoint, query string) rpc.Result {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	mc.queries++
+	return mc.lookup(rpc.BatchKey(strings.TrimPrefix(endpoint, "/"), query))
+}
+
+func (mc *mapClient) Batch(keys []string) map[string]rpc.Result {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	mc.batches++
+	results := map[string]rpc.Result{}
+	for _, key := range keys {
+		results[key] = mc.lookup(key)
+	}
+	return results
+}
+
+func (mc *mapClient) lookup(key string) rpc.Result {
+	mc.lookups[key]++
+	if res, ok := mc.results[key]; ok {
+		return res
+	}
+	return rpc.Result{Complete: true, Error: "not found"}
+}
+
+func linkify(t *testing.T, client rpc.Client, input string, includeDesc bool) string {
+	var out bytes.Buffer
+	require.NoError(t, Linkify(client, strings.NewReader(input), &out, includeDesc))
+	return out.String()
+}
+
+func TestLinkify(t *testing.T) {
+	tests := map[string]urlTestCase{
+		"repo url": {
+			input:  "see https://github.com/zw/df.\n",
+			output: "see [zw/df](https://github.com/zw/df).\n",
+		},
+		"issue and pull request urls": {
+			input:  "fixes https://github.com/zw/df/issues/1 and https://github.com/zw/df/pull/2",
+			output: "fixes [zw/df#1](https://github.com/zw/df/issues/1) and [zw/df#2](https://github.com/zw/df/pull/2)",
+		},
+		"keeps anchors": {
+			input:  "https://github.com/zw/df/issues/1#issuecomment-2",
+			output: "[zw/df#1](https://github.com/zw/df/issues/1#issuecomment-2)",
+		},
+		"discussion urls": {
+			input:  "https://github.com/zw/df/discussions/3 (https://github.com/orgs/gh/teams/foo/discussions/1)",
+			output: "[zw/df#3](https://github.com/zw/df/discussions/3) ([@gh/foo#1](https://github.com/orgs/gh/teams/foo/discussions/1))",
+		},
+		"issue references": {
+			input:  "- zw/df#1, zw/df.vim#2\n",
+			output: "- [zw/df#1](https://github.com/zw/df/issues/1), [zw/df.vim#2](https://github.com/zw/df.vim/issues/2)\n",
+		},
+		"other github urls": {
+			input:  "https://github.com/zw/df/blob/main/README.md https://github.com/zw https://github.com/zw/df/pull/1/files",
+			output: "https://github.com/zw/df/blob/main/README.md https://github.com/zw https://github.com/zw/df/pull/1/files",
+		},
+		"references in other urls": {
+			input:  "https://example.com/zw/df#1",
+			output: "https://example.com/zw/df#1",
+		},
+		"existing links": {
+			input:  "[zw/df#1](https://github.com/zw/df/issues/1) [df][1] <https://github.com/zw/df> <a href=\"https://github.com/zw/df\">zw/df#1</a>",
+			output: "[zw/df#1](https://github.com/zw/df/issues/1) [df][1] <https://github.com/zw/df> <a href=\"https://github.com/zw/df\">zw/df#1</a>",
+		},
+		"link definitions": {
+			input:  "[1]: https://github.com/zw/df/issues/1\n",
+			output: "[1]: https://github.com/zw/df/issues/1\n",
+		},
+		"inline code": {
+			input:  "`zw/df#1` and ``https://github.com/zw/df`` but zw/df#2",
+			output: "`zw/df#1` and ``https://github.com/zw/df`` but [zw/df#2](https://github.com/zw/df/issues/2)",
+		},
+		"code blocks": {
+			input:  "zw/df#1\n```\nzw/df#2\n~~~\n```\nzw/df#3\n",
+			output: "[zw/df#1](https://github.com/zw/df/issues/1)\n```\nzw/df#2\n~~~\n```\n[zw/df#3](https://github.com/zw/df/issues/3)\n",
+		},
+		"fences with info strings only open code blocks": {
+			input:  "```go\nzw/df#1\n```go\nzw/df#2\n```\nzw/df#3\n",
+			output: "```go\nzw/df#1\n```go\nzw/df#2\n```\n[zw/df#3](https://github.com/zw/df/issues/3)\n",
+		},
+		"indented code blocks": {
+			input:  "    zw/df#1\n\nzw/df#2\n\n    zw/df#3\n\n\tzw/df#4\nzw/df#5\n",
+			output: "    zw/df#1\n\n[zw/df#2](https://github.com/zw/df/issues/2)\n\n    zw/df#3\n\n\tzw/df#4\n[zw/df#5](https://github.com/zw/df/issues/5)\n",
+		},
+		"indented code blocks right after a fence": {
+			input:  "```\nzw/df#1\n```\n    zw/df#2\nzw/df#3\n",
+			output: "```\nzw/df#1\n```\n    zw/df#2\n[zw/df#3](https://github.com/zw/df/issues/3)\n",
+		},
+		"indented lines continuing a paragraph": {
+			input:  "zw/df#1\n    zw/df#2\n",
+			output: "[zw/df#1](https://github.com/zw/df/issues/1)\n    [zw/df#2](https://github.com/zw/df/issues/2)\n",
+		},
+	}
+
+	for desc, tc := range tests {
+		t.Run(desc, func(t *testing.T) {
+			assert.Equal(t, tc.output, linkify(t, rpc.NewClient(config.Config{}), tc.input, false))
+		})
+	}
+}
+
+func TestLinkifyWithDescription(t *testing.T) {
+	client := &mapClient{
+		results: map[string]rpc.Result{
+			"issue:zw/df#1": {Complete: true, Issues: []rpc.Issue{{Title: "an [issue]"}}},
+			"issue:zw/df#2": {Complete: true, Issues: []rpc.Issue{{Title: "a patch"}}},
+			"repo:zw/df":    {Complete: true, Repos: []rpc.Repo{{Description: "dotfiles"}}},
+		},
+		lookups: map[string]int{},
+	}
+
+	var input, expected strings.Builder
+	for i := 0; i < 100; i++ {
+		input.WriteString("zw/df#1 https://github.com/zw/df/pull/2 https://github.com/zw/df zw/df#3\n")
+		expected.WriteString("[zw/df#1: an (issue)](https://github.com/zw/df/issues/1) " +
+			"[zw/df#2: a patch](https://github.com/zw/df/pull/2) " +
+			"[zw/df: dotfiles](https://github.com/zw/df) " +
+			"[zw/df#3](https://github.com/zw/df/issues/3)\n")
+	}
+
+	assert.Equal(t, expected.String(), linkify(t, client, input.String(), true))
+	assert.Equal(t, map[string]int{
+		"issue:zw/df#1": 1,
+		"issue:zw/df#2": 1,
+		"issue:zw/df#3": 1,
+		"repo:zw/df":    1,
+	}, client.lookups, "each thing is looked up once")
+	assert.Zero(t, client.queries, "no single queries")
+	assert.Less(t, client.batches, len(client.lookups), "lookups are batched together")
+}
+
+// pendingClient answers each batch key as incomplete until it's been asked for
+// a number of times, counting the batches made
+type pendingClient struct {
+	mu      sync.Mutex
+	polls   int
+	asked   map[string]int
+	batches [][]string
+}
+
+func (pc *pendingClient) Query(endpoint, query string) rpc.Result {
+	panic("unexpected single query")
+}
+
+func (pc *pendingClient) Batch(keys []string) map[string]rpc.Result {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	pc.batches = append(pc.batches, keys)
+	results := map[string]rpc.Result{}
+	for _, key := range keys {
+		pc.asked[key]++
+		if pc.asked[key] < pc.polls {
+			results[key] = rpc.Result{}
+			continue
+		}
+		results[key] = rpc.Result{Complete: true, Repos: []rpc.Repo{{Description: key}}}
+	}
+	return results
+}
+
+func TestLinkifyPollsIncompleteLookupsTogether(t *testing.T) {
+	client := &pendingClient{polls: 3, asked: map[string]int{}}
+	input := "https://github.com/zw/a\nhttps://github.com/zw/b\nhttps://github.com/zw/c\n"
+	assert.Equal(t,
+		"[zw/a: repo:zw/a](https://github.com/zw/a)\n"+
+			"[zw/b: repo:zw/b](https://github.com/zw/b)\n"+
+			"[zw/c: repo:zw/c](https://github.com/zw/c)\n",
+		linkify(t, client, input, true))
+
+	for key, n := range client.asked {
+		assert.Equal(t, client.polls, n, "%s is polled until it's complete, and no more", key)
+	}
+	// every key is asked for three times, so batching them means fewer round
+	// trips than the nine it'd take to poll them one by one
+	assert.Less(t, len(client.batches), 3*len(client.asked), "batches: %v", client.batches)
+}
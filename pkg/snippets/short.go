@@ -0,0 +1,85 @@
+package snippets
+
+import (
+	"strings"
+
+	"github.com/zerowidth/gh-shorthand/pkg/config"
+	"github.com/zerowidth/gh-shorthand/pkg/rpc"
+)
+
+// Shortener shortens references to repos and issues for writing in a
+// particular repo, the context repo: issues in the context repo are just #123,
+// and other repos use their shorthand keys from the repo map.
+type Shortener struct {
+	contextRepo string
+	shorthand   map[string]string
+}
+
+// NewShortener returns a Shortener for the given context repo, or the default
+// repo if none is given.
+func NewShortener(cfg config.Config, contextRepo string) *Shortener {
+	if len(contextRepo) == 0 {
+		contextRepo = cfg.DefaultRepo
+	}
+	return &Shortener{
+		contextRepo: contextRepo,
+		shorthand:   repoShorthand(cfg.RepoMap),
+	}
+}
+
+// Ref returns the shortest reference for a link. GitHub only autolinks #123
+// and owner/name#123, so if autolink is set, shorthand keys aren't used.
+func (s *Shortener) Ref(data LinkData, autolink bool) string {
+	key, hasKey := s.shorthand[strings.ToLower(data.Repo)]
+	if len(data.Number) == 0 {
+		if hasKey && !autolink {
+			return key
+		}
+		return data.Repo
+	}
+
+	if len(s.contextRepo) > 0 && strings.EqualFold(data.Repo, s.contextRepo) {
+		return "#" + data.Number
+	}
+	if hasKey && !autolink {
+		return key + "#" + data.Number
+	}
+	return data.Ref()
+}
+
+// ShortIssueReference is like IssueReference, but shortens the reference.
+func ShortIssueReference(input string, s *Shortener) string {
+	matches := issueRegex.FindStringSubmatch(input)
+	if matches == nil {
+		return input
+	}
+	data := LinkData{Repo: matches[2] + "/" + matches[3], Number: matches[5]}
+	return s.Ref(data, true)
+}
+
+// ShortLink is like Link, but shortens the link text.
+func ShortLink(rpcClient rpc.Client, input string, format Format, includeDesc bool, s *Shortener) string {
+	link, _ := renderLink(rpcClient, input, includeDesc, func(data LinkData, described bool) (string, error) {
+		text := s.Ref(data, false)
+		if described {
+			text += ": " + data.Title
+		}
+		return format.Link(text, data.URL), nil
+	})
+	return link
+}
+
+// repoShorthand maps repos to their shorthand keys. Where a repo has more than
+// one, the shortest is used, then the first alphabetically. Repos are
+// lowercased, as GitHub's are case-insensitive.
+func repoShorthand(repoMap map[string]string) map[string]string {
+	shorthand := map[string]string{}
+	for key, repo := range repoMap {
+		repo = strings.ToLower(repo)
+		if existing, ok := shorthand[repo]; !ok ||
+			len(key) < len(existing) || len(key) == len(existing) && key < existing {
+			shorthand[repo] = key
+		}
+	}
+	return shorthand
+}
@@ -0,0 +1,85 @@
+package snippets
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/zerowidth/gh-shorthand/pkg/config"
+	"github.com/zerowidth/gh-shorthand/pkg/rpc"
+)
+
+var shortCfg = config.Config{
+	RepoMap: map[string]string{
+		"gs":  "zerowidth/gh-shorthand",
+		"ghs": "zerowidth/gh-shorthand",
+		"df":  "zerowidth/dotfiles",
+	},
+	DefaultRepo: "zerowidth/dotfiles",
+}
+
+func TestShortIssueReference(t *testing.T) {
+	s := NewShortener(shortCfg, "zerowidth/gh-shorthand")
+	tests := map[string]urlTestCase{
+		"context repo": {
+			input:  "https://github.com/zerowidth/gh-shorthand/issues/12",
+			output: "#12",
+		},
+		"context repo in a different case": {
+			input:  "https://github.com/Zerowidth/GH-Shorthand/pull/12",
+			output: "#12",
+		},
+		"shorthand isn't autolinked": {
+			input:  "https://github.com/zerowidth/dotfiles/issues/3",
+			output: "zerowidth/dotfiles#3",
+		},
+		"other repo": {
+			input:  "https://github.com/zw/df/issues/1",
+			output: "zw/df#1",
+		},
+		"not an issue": {
+			input:  "https://github.com/zw/df",
+			output: "https://github.com/zw/df",
+		},
+	}
+	for desc, tc := range tests {
+		t.Run(desc, func(t *testing.T) {
+			assert.Equal(t, tc.output, ShortIssueReference(tc.input, s))
+		})
+	}
+
+	s = NewShortener(shortCfg, "")
+	assert.Equal(t, "#3", ShortIssueReference("https://github.com/zerowidth/dotfiles/issues/3", s),
+		"default repo is the context")
+}
+
+func TestShortLink(t *testing.T) {
+	s := NewShortener(shortCfg, "zerowidth/gh-shorthand")
+	tests := map[string]urlTestCase{
+		"context repo": {
+			input:  "https://github.com/zerowidth/gh-shorthand/issues/12",
+			output: "[#12](https://github.com/zerowidth/gh-shorthand/issues/12)",
+		},
+		"shortest shorthand": {
+			input:  "https://github.com/zerowidth/dotfiles/pull/3",
+			output: "[df#3](https://github.com/zerowidth/dotfiles/pull/3)",
+		},
+		"shorthand repo": {
+			input:  "https://github.com/zerowidth/gh-shorthand",
+			output: "[gs](https://github.com/zerowidth/gh-shorthand)",
+		},
+		"other repo": {
+			input:  "zw/df#1",
+			output: "[zw/df#1](https://github.com/zw/df/issues/1)",
+		},
+	}
+	for desc, tc := range tests {
+		t.Run(desc, func(t *testing.T) {
+			assert.Equal(t, tc.output, ShortLink(rpc.NewClient(config.Config{}), tc.input, Markdown, false, s))
+		})
+	}
+
+	client := &fakeClient{issue: &rpc.Issue{Title: "a patch"}}
+	assert.Equal(t, "[df#3: a patch](https://github.com/zerowidth/dotfiles/issues/3)",
+		ShortLink(client, "zerowidth/dotfiles#3", Markdown, true, s))
+	assert.Equal(t, "zerowidth/dotfiles#3", client.query, "queries the full reference")
+}
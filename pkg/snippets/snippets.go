@@ -6,6 +6,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/zerowidth/gh-shorthand/pkg/config"
 	"github.com/zerowidth/gh-shorthand/pkg/parser"
 	"github.com/zerowidth/gh-shorthand/pkg/rpc"
 )
@@ -19,6 +20,38 @@ var discussionRegex = regexp.MustCompile(`(https://github\.com/orgs/([^/]+)/team
 // "https://github.com/zerowidth/camper_van/issues/1" becomes a markdown link
 // with link text "zerowidth/camper_van#1".
 func MarkdownLink(rpcClient rpc.Client, input string, includeDesc bool) string {
+	return Link(rpcClient, input, Markdown, includeDesc)
+}
+
+// Link is like MarkdownLink, but renders the link in any format.
+func Link(rpcClient rpc.Client, input string, format Format, includeDesc bool) string {
+	link, _ := renderLink(rpcClient, input, includeDesc, func(data LinkData, described bool) (string, error) {
+		text := data.Ref()
+		if described {
+			text += ": " + data.Title
+		}
+		return format.Link(text, data.URL), nil
+	})
+	return link
+}
+
+// TemplateLink is like MarkdownLink, but renders the link with a named link
+// template. If a description can't be retrieved for the description link
+// template, the plain link template is used instead.
+func TemplateLink(rpcClient rpc.Client, input string, templates *Templates, name string, includeDesc bool) (string, error) {
+	return renderLink(rpcClient, input, includeDesc, func(data LinkData, described bool) (string, error) {
+		if name == DescriptionLinkTemplate && !described {
+			return templates.Render(LinkTemplate, data)
+		}
+		return templates.Render(name, data)
+	})
+}
+
+// renderer renders a link from its data. described is set if the title or
+// description was retrieved.
+type renderer func(data LinkData, described bool) (string, error)
+
+func renderLink(rpcClient rpc.Client, input string, includeDesc bool, render renderer) (string, error) {
 	parser := parser.NewIssueReferenceParser()
 	issueReference := parser.Parse(input)
 	issueMatches := issueRegex.FindStringSubmatch(input)
@@ -27,32 +60,32 @@ func MarkdownLink(rpcClient rpc.Client, input string, includeDesc bool) string {
 
 	if issueReference.HasIssue() {
 		url := fmt.Sprintf("https://github.com/%s/issues/%s", issueReference.Repo(), issueReference.Issue)
-		return formatIssue(rpcClient, url, issueReference.Repo(), issueReference.Issue, includeDesc)
+		data := LinkData{Repo: issueReference.Repo(), Number: issueReference.Issue, URL: url}
+		return formatIssue(rpcClient, data, includeDesc, render)
 	}
 
 	if issueMatches != nil {
-		url := issueMatches[1]
 		repo := fmt.Sprintf("%s/%s", issueMatches[2], issueMatches[3])
-		issue := issueMatches[5]
-		return formatIssue(rpcClient, url, repo, issue, includeDesc)
+		data := LinkData{Repo: repo, Number: issueMatches[5], URL: issueMatches[1]}
+		return formatIssue(rpcClient, data, includeDesc, render)
 	}
 
 	if discussionMatches != nil {
-		template := "[@$2/$3#$4]($1)"
-		result := []byte{}
-		result = discussionRegex.ExpandString(result, template, input, discussionMatches)
-		return string(result)
+		url := input[discussionMatches[2]:discussionMatches[3]]
+		team := discussionRegex.ExpandString(nil, "@$2/$3", input, discussionMatches)
+		number := discussionRegex.ExpandString(nil, "$4", input, discussionMatches)
+		return render(LinkData{Repo: string(team), Number: string(number), URL: url}, false)
 	}
 
 	// Don't want to match a repo url with anything after it, but can't do a
 	// negative lookahead to ignore a trailing /. Capture and check here instead.
 	if repoMatches != nil && repoMatches[4] != "/" {
-		url := repoMatches[1]
 		repo := fmt.Sprintf("%s/%s", repoMatches[2], repoMatches[3])
-		return formatRepo(rpcClient, url, repo, includeDesc)
+		data := LinkData{Repo: repo, URL: repoMatches[1]}
+		return formatRepo(rpcClient, data, includeDesc, render)
 	}
 
-	return input
+	return input, nil
 }
 
 // IssueReference looks for a github issue and converts it to an issue reference.
@@ -71,76 +104,116 @@ func IssueReference(input string) string {
 	return string(result)
 }
 
-func formatIssue(rpcClient rpc.Client, url, repo, issue string, includeDesc bool) string {
-	mdLink := fmt.Sprintf("[%s#%s](%s)", repo, issue, url)
+// URL expands shorthand for a repo, issue, commit, or path into a GitHub URL,
+// using the repo and user shorthand from the config. Returns false if the
+// input isn't something that can be expanded.
+//
+// With "gs" as shorthand for zerowidth/gh-shorthand, "gs 12" becomes
+// "https://github.com/zerowidth/gh-shorthand/issues/12".
+func URL(cfg config.Config, input string) (string, bool) {
+	p := parser.NewURLParser(cfg.RepoMap, cfg.UserMap, cfg.DefaultRepo)
+	url := p.Parse(strings.TrimSpace(input)).URL()
+	return url, len(url) > 0
+}
 
-	if includeDesc {
-		resultChan := make(chan (rpc.Result), 1)
+func formatIssue(rpcClient rpc.Client, data LinkData, includeDesc bool, render renderer) (string, error) {
+	if !includeDesc {
+		return render(data, false)
+	}
 
-		go func() {
-			for {
-				res := rpcClient.Query("/issue", fmt.Sprintf("%s#%s", repo, issue))
-				if res.Complete {
-					resultChan <- res
-					return
-				}
-				<-time.After(100 * time.Millisecond)
-			}
-		}()
-
-		select {
-		case res := <-resultChan:
-			switch {
-			case len(res.Error) > 0:
-				mdLink = fmt.Sprintf("%s (rpc error: %s)", mdLink, res.Error)
-			case len(res.Issues) > 0:
-				desc := friendlierMarkdown(res.Issues[0].Title)
-				mdLink = fmt.Sprintf("[%s#%s: %s](%s)", repo, issue, desc, url)
-			default:
-				mdLink += " (rpc error: no data returned)"
-			}
-		case <-time.After(5 * time.Second):
-			mdLink += " (rpc timed out)"
-		}
+	var suffix string
+	res, ok := fetch(rpcClient, rpc.BatchKey("issue", data.Ref()))
+	switch {
+	case !ok:
+		suffix = " (rpc timed out)"
+	case len(res.Error) > 0:
+		suffix = fmt.Sprintf(" (rpc error: %s)", res.Error)
+	case len(res.Issues) > 0:
+		return render(data.WithIssue(res.Issues[0]), true)
+	default:
+		suffix = " (rpc error: no data returned)"
 	}
 
-	return mdLink
+	link, err := render(data, false)
+	return link + suffix, err
 }
 
-func formatRepo(rpcClient rpc.Client, url, repo string, includeDesc bool) string {
-	mdLink := fmt.Sprintf("[%s](%s)", repo, url)
+func formatRepo(rpcClient rpc.Client, data LinkData, includeDesc bool, render renderer) (string, error) {
+	if !includeDesc {
+		return render(data, false)
+	}
 
-	if includeDesc {
-		resultChan := make(chan (rpc.Result), 1)
+	var suffix string
+	res, ok := fetch(rpcClient, rpc.BatchKey("repo", data.Repo))
+	switch {
+	case !ok:
+		suffix = " (rpc timed out)"
+	case len(res.Error) > 0:
+		suffix = fmt.Sprintf(" (rpc error: %s)", res.Error)
+	case len(res.Repos) > 0:
+		data.Title = res.Repos[0].Description
+		return render(data, true)
+	default:
+		suffix = " (rpc error: no data returned)"
+	}
 
-		go func() {
-			for {
-				res := rpcClient.Query("/repo", repo)
-				if res.Complete {
-					resultChan <- res
-					return
-				}
-				<-time.After(100 * time.Millisecond)
-			}
-		}()
-
-		select {
-		case res := <-resultChan:
-			switch {
-			case len(res.Error) > 0:
-				mdLink = fmt.Sprintf("%s (rpc error: %s)", mdLink, res.Error)
-			case len(res.Repos) > 0:
-				desc := friendlierMarkdown(res.Repos[0].Description)
-				mdLink = fmt.Sprintf("[%s: %s](%s)", repo, desc, url)
-			default:
-				mdLink += " (rpc error: no data returned)"
-			}
-		case <-time.After(5 * time.Second):
-			mdLink += " (rpc timed out)"
+	link, err := render(data, false)
+	return link + suffix, err
+}
+
+// How long to wait for an RPC query to complete, and how often to check
+const (
+	rpcTimeout  = 5 * time.Second
+	rpcInterval = 100 * time.Millisecond
+)
+
+// fetch polls the RPC server until a single lookup, given its batch key, is
+// complete. Returns false if it didn't complete in time.
+func fetch(rpcClient rpc.Client, key string) (rpc.Result, bool) {
+	results, ok := fetchBatch(rpcClient, []string{key}, rpcTimeout)
+	return results[key], ok
+}
+
+func fetchWithin(rpcClient rpc.Client, endpoint, query string, timeout time.Duration) (rpc.Result, bool) {
+	deadline := time.Now().Add(timeout)
+	for {
+		res := rpcClient.Query(endpoint, query)
+		if res.Complete {
+			return res, true
 		}
+		if time.Now().Add(rpcInterval).After(deadline) {
+			return res, false
+		}
+		time.Sleep(rpcInterval)
 	}
+}
 
-	return mdLink
+// fetchBatch polls the RPC server until a batch of lookups are all complete,
+// asking only for the ones that aren't. Returns false if they didn't all
+// complete in time.
+func fetchBatch(rpcClient rpc.Client, keys []string, timeout time.Duration) (map[string]rpc.Result, bool) {
+	results := make(map[string]rpc.Result, len(keys))
+	deadline := time.Now().Add(timeout)
+	pending := keys
+	for {
+		for key, res := range rpcClient.Batch(pending) {
+			results[key] = res
+		}
+		var incomplete []string
+		for _, key := range keys {
+			if !results[key].Complete {
+				incomplete = append(incomplete, key)
+			}
+		}
+		if len(incomplete) == 0 {
+			return results, true
+		}
+		if time.Now().Add(rpcInterval).After(deadline) {
+			return results, false
+		}
+		pending = incomplete
+		time.Sleep(rpcInterval)
+	}
 }
 
 // make markdown more parse-able and look better in apps like Bear.app
@@ -1,9 +1,11 @@
 package snippets
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/zerowidth/gh-shorthand/pkg/config"
 	"github.com/zerowidth/gh-shorthand/pkg/rpc"
 )
 
@@ -24,11 +26,28 @@ type rpcTestCase struct {
 type fakeClient struct {
 	endpoint string // to record the endpoint
 	query    string // to record the query used
+	queries  int    // to count single queries
+	batches  int    // to count batch requests
 	repo     *rpc.Repo
 	issue    *rpc.Issue
 }
 
 func (fc *fakeClient) Query(endpoint, query string) rpc.Result {
+	fc.queries++
+	return fc.result(endpoint, query)
+}
+
+func (fc *fakeClient) Batch(keys []string) map[string]rpc.Result {
+	fc.batches++
+	results := map[string]rpc.Result{}
+	for _, key := range keys {
+		action, query, _ := strings.Cut(key, ":")
+		results[key] = fc.result("/"+action, query)
+	}
+	return results
+}
+
+func (fc *fakeClient) result(endpoint, query string) rpc.Result {
 	// record the input
 	fc.endpoint = endpoint
 	fc.query = query
@@ -101,7 +120,7 @@ func TestMarkdownLink(t *testing.T) {
 
 	for desc, tc := range tests {
 		t.Run(desc, func(t *testing.T) {
-			rpcClient := rpc.NewClient("")
+			rpcClient := rpc.NewClient(config.Config{})
 			assert.Equal(t, tc.output, MarkdownLink(rpcClient, tc.input, false))
 		})
 	}
@@ -190,6 +209,10 @@ func TestMarkdownLinkWithDescription(t *testing.T) {
 			assert.Equal(t, tc.output, MarkdownLink(client, tc.input, true))
 			assert.Equal(t, tc.endpoint, client.endpoint)
 			assert.Equal(t, tc.query, client.query)
+			if len(tc.endpoint) > 0 {
+				assert.Equal(t, 1, client.batches, "one batch request")
+				assert.Zero(t, client.queries, "no single queries")
+			}
 		})
 	}
 }
@@ -224,3 +247,61 @@ func TestIssueReference(t *testing.T) {
 		})
 	}
 }
+
+func TestLink(t *testing.T) {
+	issue := "https://github.com/zw/df/issues/1"
+	tests := map[string]urlTestCase{
+		"markdown": {input: issue, output: "[zw/df#1: a <b> & (c)](https://github.com/zw/df/issues/1)"},
+		"html":     {input: issue, output: `<a href="https://github.com/zw/df/issues/1">zw/df#1: a &lt;b&gt; &amp; [c]</a>`},
+		"slack":    {input: issue, output: "<https://github.com/zw/df/issues/1|zw/df#1: a &lt;b&gt; &amp; [c]>"},
+		"org":      {input: issue, output: "[[https://github.com/zw/df/issues/1][zw/df#1: a <b> & (c)]]"},
+		"rst":      {input: issue, output: "`zw/df#1: a \\<b> & [c] <https://github.com/zw/df/issues/1>`__"},
+		"asciidoc": {input: issue, output: "https://github.com/zw/df/issues/1[zw/df#1: a <b> & [c\\]]"},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			format, err := LookupFormat(name)
+			assert.NoError(t, err)
+			client := &fakeClient{issue: &rpc.Issue{Title: "a <b> & [c]"}}
+			assert.Equal(t, tc.output, Link(client, tc.input, format, true))
+		})
+	}
+
+	format, err := LookupFormat("slack")
+	assert.NoError(t, err)
+	assert.Equal(t, "<https://github.com/orgs/gh/teams/foo/discussions/1|@gh/foo#1>",
+		Link(rpc.NewClient(config.Config{}), "https://github.com/orgs/gh/teams/foo/discussions/1", format, false))
+	assert.Equal(t, "<https://github.com/zw/df|zw/df>",
+		Link(rpc.NewClient(config.Config{}), "https://github.com/zw/df", format, false))
+
+	_, err = LookupFormat("wiki")
+	assert.Error(t, err)
+	assert.Equal(t, []string{"markdown", "asciidoc", "html", "org", "rst", "slack"}, Formats())
+}
+
+func TestURL(t *testing.T) {
+	cfg := config.Config{
+		RepoMap:     map[string]string{"gs": "zerowidth/gh-shorthand"},
+		UserMap:     map[string]string{"z": "zerowidth"},
+		DefaultRepo: "zerowidth/default",
+	}
+	tests := map[string]urlTestCase{
+		"repo shorthand with issue": {input: "gs 12", output: "https://github.com/zerowidth/gh-shorthand/issues/12"},
+		"user shorthand with issue": {input: "z/repo#4", output: "https://github.com/zerowidth/repo/issues/4"},
+		"default repo issue":        {input: "#7", output: "https://github.com/zerowidth/default/issues/7"},
+		"commit":                    {input: "gs@abc1234", output: "https://github.com/zerowidth/gh-shorthand/commit/abc1234"},
+		"path":                      {input: "gs /pulls", output: "https://github.com/zerowidth/gh-shorthand/pulls"},
+		"user":                      {input: "z", output: "https://github.com/zerowidth"},
+		"surrounding whitespace":    {input: " gs 12\n", output: "https://github.com/zerowidth/gh-shorthand/issues/12"},
+		"invalid":                   {input: "gs 12 and more", output: ""},
+	}
+
+	for desc, tc := range tests {
+		t.Run(desc, func(t *testing.T) {
+			url, ok := URL(cfg, tc.input)
+			assert.Equal(t, tc.output, url)
+			assert.Equal(t, len(tc.output) > 0, ok)
+		})
+	}
+}
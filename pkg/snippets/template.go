@@ -0,0 +1,125 @@
+package snippets
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/zerowidth/gh-shorthand/pkg/config"
+	"github.com/zerowidth/gh-shorthand/pkg/rpc"
+)
+
+// LinkData is what's available to a link template
+type LinkData struct {
+	Repo   string // owner/name
+	Short  string // the shorthand key for the repo, or the repo if it has none
+	Number string // the issue or PR number, if it's an issue or PR
+	Title  string // the issue or PR title, or the repo description
+	State  string // the issue or PR state: open, closed, or merged
+	Author string // the login of the issue or PR author
+	Type   string // issue or pullrequest, if known
+	Labels []string
+	URL    string
+}
+
+// Ref is the reference for the link: owner/name#123 for issues and PRs, or
+// the repo.
+func (d LinkData) Ref() string {
+	if len(d.Number) > 0 {
+		return d.Repo + "#" + d.Number
+	}
+	return d.Repo
+}
+
+// WithIssue adds the details of an issue or PR retrieved over RPC
+func (d LinkData) WithIssue(issue rpc.Issue) LinkData {
+	d.Title = issue.Title
+	d.State = strings.ToLower(issue.State)
+	d.Author = issue.Author
+	d.Type = strings.ToLower(issue.Type)
+	d.Labels = issue.Labels
+	return d
+}
+
+// The link templates used for the mods in completion results, and by the
+// markdown-link, issue-reference and changelog commands.
+const (
+	LinkTemplate            = "link"
+	DescriptionLinkTemplate = "link_description"
+	ReferenceTemplate       = "reference"
+	ChangelogTemplate       = "changelog"
+)
+
+var defaultTemplates = map[string]string{
+	LinkTemplate:            "[{{.Ref}}]({{.URL}})",
+	DescriptionLinkTemplate: "[{{.Ref}}: {{.Title}}]({{.URL}})",
+	ReferenceTemplate:       "{{.Ref}}",
+	ChangelogTemplate:       "* {{.Title}} ([{{.Ref}}]({{.URL}})){{with .Author}} by @{{.}}{{end}}",
+}
+
+// Templates are the default and configured link templates, by name
+type Templates struct {
+	templates map[string]*template.Template
+	shorthand map[string]string // repo shorthand keys, by repo
+}
+
+// NewTemplates parses the configured link templates, which add to or replace
+// the default templates.
+//
+// Returns the default templates along with an error if any of the configured
+// templates are invalid.
+func NewTemplates(cfg config.Config) (*Templates, error) {
+	t := &Templates{
+		templates: map[string]*template.Template{},
+		shorthand: repoShorthand(cfg.RepoMap),
+	}
+	for name, text := range defaultTemplates {
+		t.templates[name] = template.Must(template.New(name).Parse(text))
+	}
+
+	custom := map[string]*template.Template{}
+	for name, text := range cfg.LinkTemplates {
+		tmpl, err := template.New(name).Parse(text)
+		if err != nil {
+			return t, fmt.Errorf("link template %q: %w", name, err)
+		}
+		custom[name] = tmpl
+	}
+	for name, tmpl := range custom {
+		t.templates[name] = tmpl
+	}
+
+	return t, nil
+}
+
+// Render renders the named template with the given link data
+func (t *Templates) Render(name string, data LinkData) (string, error) {
+	tmpl, ok := t.templates[name]
+	if !ok {
+		return "", fmt.Errorf("unknown link template %q, must be one of: %s",
+			name, strings.Join(t.Names(), ", "))
+	}
+	if len(data.Short) == 0 {
+		data.Short = data.Repo
+		if key, ok := t.shorthand[strings.ToLower(data.Repo)]; ok {
+			data.Short = key
+		}
+	}
+
+	var b strings.Builder
+	if err := tmpl.Execute(&b, data); err != nil {
+		return "", fmt.Errorf("link template %q: %w", name, err)
+	}
+	return b.String(), nil
+}
+
+// Names lists the names of the templates
+func (t *Templates) Names() []string {
+	names := []string{}
+	for name := range t.templates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
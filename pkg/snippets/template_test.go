@@ -0,0 +1,71 @@
+package snippets
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zerowidth/gh-shorthand/pkg/config"
+	"github.com/zerowidth/gh-shorthand/pkg/rpc"
+)
+
+func TestTemplates(t *testing.T) {
+	templates, err := NewTemplates(config.Config{
+		RepoMap: map[string]string{"gs": "zw/gh-shorthand", "ghs": "zw/gh-shorthand"},
+		LinkTemplates: map[string]string{
+			"short":     "{{.Short}}#{{.Number}}{{with .State}} ({{.}}){{end}}: {{.Title}}",
+			"reference": "{{.Short}}#{{.Number}}",
+		},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"changelog", "link", "link_description", "reference", "short"}, templates.Names())
+
+	data := LinkData{Repo: "zw/gh-shorthand", Number: "12", URL: "https://github.com/zw/gh-shorthand/issues/12"}
+	data = data.WithIssue(rpc.Issue{Type: "PullRequest", State: "MERGED", Title: "a patch", Author: "zw"})
+	assert.Equal(t, "pullrequest", data.Type)
+
+	out, err := templates.Render("short", data)
+	require.NoError(t, err)
+	assert.Equal(t, "gs#12 (merged): a patch", out, "uses the shortest shorthand key")
+
+	out, err = templates.Render("reference", data)
+	require.NoError(t, err)
+	assert.Equal(t, "gs#12", out, "replaces the default")
+
+	out, err = templates.Render("link", LinkData{Repo: "zw/df", URL: "https://github.com/zw/df"})
+	require.NoError(t, err)
+	assert.Equal(t, "[zw/df](https://github.com/zw/df)", out)
+
+	out, err = templates.Render("reference", LinkData{Repo: "zw/df", Number: "1"})
+	require.NoError(t, err)
+	assert.Equal(t, "zw/df#1", out, "no shorthand key")
+
+	_, err = templates.Render("missing", data)
+	assert.Error(t, err)
+
+	_, err = NewTemplates(config.Config{LinkTemplates: map[string]string{"bad": "{{.Title"}})
+	assert.Error(t, err)
+}
+
+func TestTemplateLink(t *testing.T) {
+	templates, err := NewTemplates(config.Config{
+		LinkTemplates: map[string]string{"link_description": "{{.Ref}} by {{.Author}}: {{.Title}}"},
+	})
+	require.NoError(t, err)
+
+	client := &fakeClient{issue: &rpc.Issue{Title: "a patch", Author: "zw"}}
+	out, err := TemplateLink(client, "https://github.com/zw/df/pull/1", templates, DescriptionLinkTemplate, true)
+	require.NoError(t, err)
+	assert.Equal(t, "zw/df#1 by zw: a patch", out)
+	assert.Equal(t, "/issue", client.endpoint)
+
+	client = &fakeClient{}
+	out, err = TemplateLink(client, "https://github.com/zw/df/pull/1", templates, DescriptionLinkTemplate, true)
+	require.NoError(t, err)
+	assert.Equal(t, "[zw/df#1](https://github.com/zw/df/pull/1) (rpc error: no data returned)", out,
+		"falls back to the plain link")
+
+	out, err = TemplateLink(rpc.NewClient(config.Config{}), "https://github.com/zw/df/issues/1", templates, ReferenceTemplate, false)
+	require.NoError(t, err)
+	assert.Equal(t, "zw/df#1", out)
+}